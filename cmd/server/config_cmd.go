@@ -0,0 +1,40 @@
+package main
+
+import (
+	"book-lending-api/internal/config"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+const configUsage = `usage: server config <command>
+
+commands:
+  print [--redacted]    print the effective configuration as YAML,
+                        optionally with secrets masked`
+
+// runConfig implements the "config" subcommand.
+func runConfig(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing config command\n%s", configUsage)
+	}
+	switch args[0] {
+	case "print":
+		out := cfg
+		for _, arg := range args[1:] {
+			if arg != "--redacted" {
+				return fmt.Errorf("unknown flag %q\n%s", arg, configUsage)
+			}
+			out = cfg.Redacted()
+		}
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(out); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		return fmt.Errorf("unknown config command %q\n%s", args[0], configUsage)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"book-lending-api/internal/migrate"
+	"book-lending-api/migrations"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"gorm.io/gorm"
+)
+
+const migrateUsage = `usage: server migrate <command>
+
+commands:
+  up              apply all pending migrations
+  down [n]        revert the last n migrations (default 1)
+  to <version>    migrate up or down to the given version (0 reverts all)
+  status          list migrations and whether they are applied`
+
+// newMigrator returns a migrator using the script set for driver.
+func newMigrator(db *gorm.DB, driver string) (*migrate.Migrator, error) {
+	scripts, err := migrations.For(driver)
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(db, scripts)
+}
+
+// runMigrate implements the "migrate" subcommand.
+func runMigrate(db *gorm.DB, driver string, args []string) error {
+	m, err := newMigrator(db, driver)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("missing migrate command\n%s", migrateUsage)
+	}
+	switch args[0] {
+	case "up":
+		return m.Up()
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid step count %q", args[1])
+			}
+		}
+		return m.Down(steps)
+	case "to":
+		if len(args) < 2 {
+			return fmt.Errorf("missing target version\n%s", migrateUsage)
+		}
+		version, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		return m.To(uint(version))
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", args[0], migrateUsage)
+	}
+}
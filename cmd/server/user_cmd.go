@@ -0,0 +1,48 @@
+package main
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/repository"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const userUsage = `usage: server user <command>
+
+commands:
+  role <email> <role>    set a user's role (member, librarian or admin)`
+
+// runUser implements the "user" subcommand, which lets operators
+// appoint the first administrator.
+func runUser(db *gorm.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing user command\n%s", userUsage)
+	}
+	switch args[0] {
+	case "role":
+		if len(args) != 3 {
+			return fmt.Errorf("role needs an email and a role\n%s", userUsage)
+		}
+		email, role := args[1], args[2]
+		if !slices.Contains(domain.Roles, role) {
+			return fmt.Errorf("unknown role %q (want %s)", role, strings.Join(domain.Roles, ", "))
+		}
+		ctx := context.Background()
+		users := repository.NewUserRepository(db)
+		user, err := users.GetByEmail(ctx, email)
+		if err != nil {
+			return fmt.Errorf("find user %s: %w", email, err)
+		}
+		if err := users.UpdateRole(ctx, user.ID, role); err != nil {
+			return err
+		}
+		fmt.Printf("%s is now %s\n", email, role)
+		return nil
+	default:
+		return fmt.Errorf("unknown user command %q\n%s", args[0], userUsage)
+	}
+}
@@ -1,55 +1,779 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"book-lending-api/internal/domain"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Settings are resolved in three layers: built-in defaults, then an
+// optional YAML or TOML file named by CONFIG_FILE, then environment
+// variables.  Later layers only override values they actually set.
+
+// Environments recognised by Validate.
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+// Insecure defaults that must be overridden in production.
+const (
+	defaultJWTSecret  = "supersecretkey"
+	defaultDBPassword = "password"
+	redacted          = "[REDACTED]"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
+	Environment   string              `yaml:"environment" toml:"environment"`
+	Server        ServerConfig        `yaml:"server" toml:"server"`
+	Database      DatabaseConfig      `yaml:"database" toml:"database"`
+	JWT           JWTConfig           `yaml:"jwt" toml:"jwt"`
+	Login         LoginConfig         `yaml:"login" toml:"login"`
+	MFA           MFAConfig           `yaml:"mfa" toml:"mfa"`
+	OIDC          OIDCConfig          `yaml:"oidc" toml:"oidc"`
+	Mail          MailConfig          `yaml:"mail" toml:"mail"`
+	PasswordReset PasswordResetConfig `yaml:"password_reset" toml:"password_reset"`
+	Verification  VerificationConfig  `yaml:"email_verification" toml:"email_verification"`
+	RateLimit     RateLimitConfig     `yaml:"rate_limit" toml:"rate_limit"`
+	Redis         RedisConfig         `yaml:"redis" toml:"redis"`
+	Loans         LoanConfig          `yaml:"loans" toml:"loans"`
+	CORS          CORSConfig          `yaml:"cors" toml:"cors"`
+	Metrics       MetricsConfig       `yaml:"metrics" toml:"metrics"`
+	Tracing       TracingConfig       `yaml:"tracing" toml:"tracing"`
+	Log           LogConfig           `yaml:"log" toml:"log"`
 }
 
 // ServerConfig controls the HTTP server.
 type ServerConfig struct {
-	Port string
+	Port         string   `yaml:"port" toml:"port"`
+	ReadTimeout  Duration `yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout  Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	// ShutdownTimeout bounds how long in-flight requests may take to
+	// finish once a termination signal arrives.
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	// RequestTimeout is the deadline for the database work of a single
+	// API request.
+	RequestTimeout Duration `yaml:"request_timeout" toml:"request_timeout"`
+	// HealthCheckTimeout bounds each dependency check run by /readyz.
+	HealthCheckTimeout Duration `yaml:"health_check_timeout" toml:"health_check_timeout"`
+	// TrustedProxies lists the addresses or CIDR ranges of reverse
+	// proxies whose X-Forwarded-For header is believed.  With none,
+	// the client IP used for rate limits and login throttles is always
+	// the connection's peer address, so clients cannot pick their own.
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies"`
 }
 
-// DatabaseConfig describes the connection to MySQL.
+// DatabaseConfig describes the database connection.  Driver is one of
+// mysql, postgres or sqlite.  Path is only used by sqlite and may be a
+// file name or ":memory:"; SSLMode is only used by postgres.
+// AutoMigrate applies pending migrations at startup, which in-memory
+// SQLite databases always do.
 type DatabaseConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	Name     string
+	Driver      string `yaml:"driver" toml:"driver"`
+	Host        string `yaml:"host" toml:"host"`
+	Port        string `yaml:"port" toml:"port"`
+	User        string `yaml:"user" toml:"user"`
+	Password    string `yaml:"password" toml:"password"`
+	Name        string `yaml:"name" toml:"name"`
+	Path        string `yaml:"path" toml:"path"`
+	SSLMode     string `yaml:"sslmode" toml:"sslmode"`
+	AutoMigrate bool   `yaml:"auto_migrate" toml:"auto_migrate"`
 }
 
 // JWTConfig holds the signing key used for JSON web tokens.
 type JWTConfig struct {
-	Secret string
+	Secret string `yaml:"secret" toml:"secret"`
+}
+
+// LoginConfig throttles password guessing.  After MaxFailures
+// consecutive failed logins for an account, or IPMaxFailures from one
+// address, logins are refused for Lockout, doubling with every further
+// failure up to MaxLockout.  Failures are forgotten after ResetAfter.
+type LoginConfig struct {
+	MaxFailures   int      `yaml:"max_failures" toml:"max_failures"`
+	IPMaxFailures int      `yaml:"ip_max_failures" toml:"ip_max_failures"`
+	Lockout       Duration `yaml:"lockout" toml:"lockout"`
+	MaxLockout    Duration `yaml:"max_lockout" toml:"max_lockout"`
+	ResetAfter    Duration `yaml:"reset_after" toml:"reset_after"`
+}
+
+// MFAConfig controls TOTP two-factor authentication.  Issuer names the
+// service in authenticator apps.  Users with one of RequiredRoles must
+// log in with a second factor to use privileged endpoints and may not
+// turn it off.  ChallengeTTL is how long a user has to enter their code
+// after the password.
+type MFAConfig struct {
+	Issuer        string   `yaml:"issuer" toml:"issuer"`
+	RequiredRoles []string `yaml:"required_roles" toml:"required_roles"`
+	ChallengeTTL  Duration `yaml:"challenge_ttl" toml:"challenge_ttl"`
+}
+
+// OIDCConfig enables single sign-on through an OpenID Connect
+// provider, which is off unless IssuerURL is set.  RedirectURL is the
+// API's /api/v1/auth/oidc/callback as registered with the provider.
+// ClientSecret may be empty for public clients, which rely on PKCE
+// alone.  LoginTTL is how long a user has to sign in at the provider.
+type OIDCConfig struct {
+	IssuerURL    string   `yaml:"issuer_url" toml:"issuer_url"`
+	ClientID     string   `yaml:"client_id" toml:"client_id"`
+	ClientSecret string   `yaml:"client_secret" toml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url" toml:"redirect_url"`
+	Scopes       []string `yaml:"scopes" toml:"scopes"`
+	LoginTTL     Duration `yaml:"login_ttl" toml:"login_ttl"`
+}
+
+// Enabled reports whether single sign-on is configured.
+func (c OIDCConfig) Enabled() bool {
+	return c.IssuerURL != ""
+}
+
+// Mail drivers accepted by MailConfig.Driver.
+const (
+	MailSMTP   = "smtp"
+	MailFile   = "file"
+	MailStdout = "stdout"
+)
+
+// MailConfig selects how outgoing email is delivered.  The file and
+// stdout drivers write messages out instead of sending them, so that
+// mail can be read locally without a mail server.
+type MailConfig struct {
+	Driver string     `yaml:"driver" toml:"driver"`
+	From   string     `yaml:"from" toml:"from"`
+	File   string     `yaml:"file" toml:"file"`
+	SMTP   SMTPConfig `yaml:"smtp" toml:"smtp"`
+}
+
+// SMTPConfig describes the SMTP relay used by the smtp mail driver.
+// STARTTLS is used whenever the server offers it.
+type SMTPConfig struct {
+	Host     string `yaml:"host" toml:"host"`
+	Port     int    `yaml:"port" toml:"port"`
+	Username string `yaml:"username" toml:"username"`
+	Password string `yaml:"password" toml:"password"`
+}
+
+// PasswordResetConfig controls password reset tokens.  URL, when set,
+// is a link template in which {token} is replaced, typically a front
+// end page that posts to /api/v1/auth/password/reset; otherwise the
+// email carries only the token.
+type PasswordResetConfig struct {
+	TokenTTL Duration `yaml:"token_ttl" toml:"token_ttl"`
+	URL      string   `yaml:"url" toml:"url"`
+}
+
+// VerificationConfig controls email verification tokens.  URL, when
+// set, is a link template in which {token} is replaced, such as
+// https://api.example.com/api/v1/auth/verify?token={token}; otherwise
+// the email carries only the token.
+type VerificationConfig struct {
+	TokenTTL Duration `yaml:"token_ttl" toml:"token_ttl"`
+	URL      string   `yaml:"url" toml:"url"`
+}
+
+// Rate limit keys accepted by RateLimitPolicy.Key.
+const (
+	RateLimitByIP     = "ip"
+	RateLimitByUser   = "user"
+	RateLimitByAPIKey = "api_key"
+)
+
+// RateLimitRoutes lists the route groups that may have their own rate
+// limit policy.  The ip policy is not a route group: it applies to
+// every API request before its credentials are checked, and so always
+// counts per client IP.
+var RateLimitRoutes = []string{"ip", "auth", "books", "lending"}
+
+// RateLimitPolicy is a token bucket refilled at RequestsPerMinute that
+// holds up to Burst requests.  Key selects how clients are told apart:
+// by IP address, by authenticated user (falling back to IP) or by API
+// key (falling back to user, then IP).
+type RateLimitPolicy struct {
+	RequestsPerMinute int    `yaml:"requests_per_minute" toml:"requests_per_minute"`
+	Burst             int    `yaml:"burst" toml:"burst"`
+	Key               string `yaml:"key" toml:"key"`
+}
+
+// Rate limit stores accepted by RateLimitConfig.Store.
+const (
+	RateLimitMemory = "memory"
+	RateLimitRedis  = "redis"
+)
+
+// RateLimitConfig configures request rate limits.  The embedded policy
+// applies to every API request; Routes adds policies for individual
+// route groups, enforced on top of it.  Store is memory, which limits
+// each instance separately, or redis, which shares the limits between
+// every instance.
+type RateLimitConfig struct {
+	RateLimitPolicy `yaml:",inline"`
+	Routes          map[string]RateLimitPolicy `yaml:"routes" toml:"routes"`
+	Store           string                     `yaml:"store" toml:"store"`
+}
+
+// RedisConfig describes the Redis server used for shared state.
+type RedisConfig struct {
+	Addr     string `yaml:"addr" toml:"addr"`
+	Password string `yaml:"password" toml:"password"`
+	DB       int    `yaml:"db" toml:"db"`
 }
 
-func Load() *Config {
+// LoanConfig holds the borrowing rules: at most MaxBorrows books in any
+// rolling Window, each due back within LoanPeriod.
+type LoanConfig struct {
+	MaxBorrows int      `yaml:"max_borrows" toml:"max_borrows"`
+	Window     Duration `yaml:"window" toml:"window"`
+	LoanPeriod Duration `yaml:"loan_period" toml:"loan_period"`
+}
+
+// CORSConfig lists what cross-origin requests are allowed.
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins" toml:"allowed_origins"`
+	AllowedMethods []string `yaml:"allowed_methods" toml:"allowed_methods"`
+	AllowedHeaders []string `yaml:"allowed_headers" toml:"allowed_headers"`
+}
+
+// MetricsConfig controls the Prometheus endpoint.  Metrics reveal
+// traffic by route and library activity, so they are served at
+// /metrics on their own listener at Addr, such as "127.0.0.1:9090", for
+// the scraper to reach on a private network.  They are not served when
+// Addr is empty.
+type MetricsConfig struct {
+	Addr string `yaml:"addr" toml:"addr"`
+}
+
+// Tracing exporters accepted by TracingConfig.Exporter.
+const (
+	TracingNone   = "none"
+	TracingOTLP   = "otlp"
+	TracingStdout = "stdout"
+	TracingFile   = "file"
+)
+
+// TracingConfig selects where OpenTelemetry spans are sent.  Endpoint
+// is the OTLP/HTTP collector address (host:port); File is the output
+// path for the file exporter.  SampleRatio is the fraction of new
+// traces recorded; incoming sampled traces are always followed.
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" toml:"exporter"`
+	ServiceName string  `yaml:"service_name" toml:"service_name"`
+	Endpoint    string  `yaml:"endpoint" toml:"endpoint"`
+	Insecure    bool    `yaml:"insecure" toml:"insecure"`
+	File        string  `yaml:"file" toml:"file"`
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio"`
+}
+
+// Log formats accepted by LogConfig.Format.
+const (
+	LogJSON = "json"
+	LogText = "text"
+)
+
+// LogConfig controls the application logger.  Level is one of debug,
+// info, warn or error.
+type LogConfig struct {
+	Level  string `yaml:"level" toml:"level"`
+	Format string `yaml:"format" toml:"format"`
+}
+
+// Duration is a time.Duration that reads and writes Go duration
+// strings such as "15s" or "168h" in config files.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Std returns the value as a time.Duration.
+func (d Duration) Std() time.Duration { return time.Duration(d) }
+
+// Defaults returns the built-in configuration.
+func Defaults() *Config {
 	return &Config{
+		Environment: EnvDevelopment,
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
+			Port:               "8080",
+			ReadTimeout:        Duration(15 * time.Second),
+			WriteTimeout:       Duration(30 * time.Second),
+			IdleTimeout:        Duration(60 * time.Second),
+			ShutdownTimeout:    Duration(30 * time.Second),
+			RequestTimeout:     Duration(10 * time.Second),
+			HealthCheckTimeout: Duration(2 * time.Second),
 		},
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "db"),
-			Port:     getEnv("DB_PORT", "3306"),
-			User:     getEnv("DB_USER", "root"),
-			Password: getEnv("DB_PASSWORD", "password"),
-			Name:     getEnv("DB_NAME", "book_lending"),
+			Driver:   "mysql",
+			Host:     "db",
+			User:     "root",
+			Password: defaultDBPassword,
+			Name:     "book_lending",
+			Path:     "book_lending.db",
+			SSLMode:  "disable",
 		},
 		JWT: JWTConfig{
-			Secret: getEnv("JWT_SECRET", "supersecretkey"),
+			Secret: defaultJWTSecret,
+		},
+		Login: LoginConfig{
+			MaxFailures:   5,
+			IPMaxFailures: 20,
+			Lockout:       Duration(time.Minute),
+			MaxLockout:    Duration(time.Hour),
+			ResetAfter:    Duration(15 * time.Minute),
+		},
+		MFA: MFAConfig{
+			Issuer:        "Book Lending",
+			RequiredRoles: []string{domain.RoleLibrarian, domain.RoleAdmin},
+			ChallengeTTL:  Duration(5 * time.Minute),
+		},
+		OIDC: OIDCConfig{
+			Scopes:   []string{"openid", "email", "profile"},
+			LoginTTL: Duration(10 * time.Minute),
+		},
+		Mail: MailConfig{
+			Driver: MailStdout,
+			From:   "Book Lending <no-reply@localhost>",
+			File:   "mail.log",
+			SMTP:   SMTPConfig{Port: 587},
+		},
+		PasswordReset: PasswordResetConfig{
+			TokenTTL: Duration(time.Hour),
+		},
+		Verification: VerificationConfig{
+			TokenTTL: Duration(48 * time.Hour),
 		},
+		RateLimit: RateLimitConfig{
+			RateLimitPolicy: RateLimitPolicy{
+				RequestsPerMinute: 100,
+				Burst:             200,
+				Key:               RateLimitByUser,
+			},
+			Routes: map[string]RateLimitPolicy{
+				"ip":   {RequestsPerMinute: 600, Burst: 600, Key: RateLimitByIP},
+				"auth": {RequestsPerMinute: 20, Burst: 10, Key: RateLimitByIP},
+			},
+			Store: RateLimitMemory,
+		},
+		Redis: RedisConfig{
+			Addr: "localhost:6379",
+		},
+		Loans: LoanConfig{
+			MaxBorrows: 5,
+			Window:     Duration(7 * 24 * time.Hour),
+			LoanPeriod: Duration(14 * 24 * time.Hour),
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"*"},
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			AllowedHeaders: []string{"Content-Type", "Authorization", "X-API-Key", "X-Request-ID"},
+		},
+		Tracing: TracingConfig{
+			Exporter:    TracingNone,
+			ServiceName: "book-lending-api",
+			Endpoint:    "localhost:4318",
+			File:        "traces.jsonl",
+			SampleRatio: 1,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: LogJSON,
+		},
+	}
+}
+
+// Load resolves the configuration from defaults, the file named by
+// CONFIG_FILE (if any) and environment variables.  It does not
+// validate the result; call Validate before serving.
+func Load() (*Config, error) {
+	cfg := Defaults()
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if cfg.Database.Port == "" {
+		cfg.Database.Port = "3306"
+		if cfg.Database.Driver == "postgres" {
+			cfg.Database.Port = "5432"
+		}
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(raw, c)
+	case ".toml":
+		err = toml.Unmarshal(raw, c)
+	default:
+		return fmt.Errorf("config file %s: unsupported format (want .yaml, .yml or .toml)", path)
+	}
+	if err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) applyEnv() error {
+	setString(&c.Environment, "APP_ENV")
+	setString(&c.Server.Port, "SERVER_PORT")
+	setList(&c.Server.TrustedProxies, "SERVER_TRUSTED_PROXIES")
+	setString(&c.Database.Driver, "DB_DRIVER")
+	setString(&c.Database.Host, "DB_HOST")
+	setString(&c.Database.Port, "DB_PORT")
+	setString(&c.Database.User, "DB_USER")
+	setString(&c.Database.Password, "DB_PASSWORD")
+	setString(&c.Database.Name, "DB_NAME")
+	setString(&c.Database.Path, "DB_PATH")
+	setString(&c.Database.SSLMode, "DB_SSLMODE")
+	setString(&c.JWT.Secret, "JWT_SECRET")
+	setList(&c.CORS.AllowedOrigins, "CORS_ALLOWED_ORIGINS")
+	setString(&c.Metrics.Addr, "METRICS_ADDR")
+	setString(&c.Tracing.Exporter, "TRACING_EXPORTER")
+	setString(&c.Tracing.ServiceName, "TRACING_SERVICE_NAME")
+	setString(&c.Tracing.Endpoint, "TRACING_OTLP_ENDPOINT")
+	setString(&c.Tracing.File, "TRACING_FILE")
+	setString(&c.Log.Level, "LOG_LEVEL")
+	setString(&c.Log.Format, "LOG_FORMAT")
+	setString(&c.MFA.Issuer, "MFA_ISSUER")
+	setList(&c.MFA.RequiredRoles, "MFA_REQUIRED_ROLES")
+	setString(&c.OIDC.IssuerURL, "OIDC_ISSUER_URL")
+	setString(&c.OIDC.ClientID, "OIDC_CLIENT_ID")
+	setString(&c.OIDC.ClientSecret, "OIDC_CLIENT_SECRET")
+	setString(&c.OIDC.RedirectURL, "OIDC_REDIRECT_URL")
+	setList(&c.OIDC.Scopes, "OIDC_SCOPES")
+	setString(&c.Mail.Driver, "MAIL_DRIVER")
+	setString(&c.Mail.From, "MAIL_FROM")
+	setString(&c.Mail.File, "MAIL_FILE")
+	setString(&c.Mail.SMTP.Host, "SMTP_HOST")
+	setString(&c.Mail.SMTP.Username, "SMTP_USERNAME")
+	setString(&c.Mail.SMTP.Password, "SMTP_PASSWORD")
+	setString(&c.PasswordReset.URL, "PASSWORD_RESET_URL")
+	setString(&c.Verification.URL, "EMAIL_VERIFICATION_URL")
+	setString(&c.RateLimit.Key, "RATE_LIMIT_KEY")
+	setString(&c.RateLimit.Store, "RATE_LIMIT_STORE")
+	setString(&c.Redis.Addr, "REDIS_ADDR")
+	setString(&c.Redis.Password, "REDIS_PASSWORD")
+	return errors.Join(
+		c.applyRouteRateLimitEnv(),
+		setDuration(&c.Server.ReadTimeout, "SERVER_READ_TIMEOUT"),
+		setDuration(&c.Server.WriteTimeout, "SERVER_WRITE_TIMEOUT"),
+		setDuration(&c.Server.IdleTimeout, "SERVER_IDLE_TIMEOUT"),
+		setDuration(&c.Server.ShutdownTimeout, "SERVER_SHUTDOWN_TIMEOUT"),
+		setDuration(&c.Server.RequestTimeout, "SERVER_REQUEST_TIMEOUT"),
+		setDuration(&c.Server.HealthCheckTimeout, "SERVER_HEALTH_CHECK_TIMEOUT"),
+		setBool(&c.Database.AutoMigrate, "DB_AUTO_MIGRATE"),
+		setInt(&c.RateLimit.RequestsPerMinute, "RATE_LIMIT_PER_MINUTE"),
+		setInt(&c.RateLimit.Burst, "RATE_LIMIT_BURST"),
+		setInt(&c.Login.MaxFailures, "LOGIN_MAX_FAILURES"),
+		setInt(&c.Login.IPMaxFailures, "LOGIN_IP_MAX_FAILURES"),
+		setDuration(&c.Login.Lockout, "LOGIN_LOCKOUT"),
+		setDuration(&c.Login.MaxLockout, "LOGIN_MAX_LOCKOUT"),
+		setDuration(&c.Login.ResetAfter, "LOGIN_RESET_AFTER"),
+		setDuration(&c.MFA.ChallengeTTL, "MFA_CHALLENGE_TTL"),
+		setDuration(&c.OIDC.LoginTTL, "OIDC_LOGIN_TTL"),
+		setInt(&c.Redis.DB, "REDIS_DB"),
+		setInt(&c.Mail.SMTP.Port, "SMTP_PORT"),
+		setDuration(&c.PasswordReset.TokenTTL, "PASSWORD_RESET_TTL"),
+		setDuration(&c.Verification.TokenTTL, "EMAIL_VERIFICATION_TTL"),
+		setInt(&c.Loans.MaxBorrows, "LOAN_MAX_BORROWS"),
+		setDuration(&c.Loans.Window, "LOAN_WINDOW"),
+		setDuration(&c.Loans.LoanPeriod, "LOAN_PERIOD"),
+		setBool(&c.Tracing.Insecure, "TRACING_OTLP_INSECURE"),
+		setFloat(&c.Tracing.SampleRatio, "TRACING_SAMPLE_RATIO"),
+	)
+}
+
+// applyRouteRateLimitEnv reads RATE_LIMIT_<ROUTE>_PER_MINUTE, _BURST
+// and _KEY for every known route group.
+func (c *Config) applyRouteRateLimitEnv() error {
+	var errs []error
+	for _, route := range RateLimitRoutes {
+		prefix := "RATE_LIMIT_" + strings.ToUpper(route) + "_"
+		policy := c.RateLimit.Routes[route]
+		setString(&policy.Key, prefix+"KEY")
+		errs = append(errs,
+			setInt(&policy.RequestsPerMinute, prefix+"PER_MINUTE"),
+			setInt(&policy.Burst, prefix+"BURST"),
+		)
+		if policy == (RateLimitPolicy{}) {
+			continue
+		}
+		if c.RateLimit.Routes == nil {
+			c.RateLimit.Routes = make(map[string]RateLimitPolicy)
+		}
+		c.RateLimit.Routes[route] = policy
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks that the configuration is usable.  In production it
+// additionally refuses the insecure built-in secrets.
+func (c *Config) Validate() error {
+	var errs []error
+	switch c.Environment {
+	case EnvDevelopment, EnvProduction:
+	default:
+		errs = append(errs, fmt.Errorf("environment must be %q or %q, got %q", EnvDevelopment, EnvProduction, c.Environment))
+	}
+	switch c.Database.Driver {
+	case "mysql", "postgres", "sqlite":
+	default:
+		errs = append(errs, fmt.Errorf("database.driver must be mysql, postgres or sqlite, got %q", c.Database.Driver))
+	}
+	if c.Server.Port == "" {
+		errs = append(errs, errors.New("server.port is required"))
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			errs = append(errs, fmt.Errorf("server.trusted_proxies: %q is not an IP address or CIDR range", proxy))
+		}
+	}
+	if c.JWT.Secret == "" {
+		errs = append(errs, errors.New("jwt.secret is required"))
+	}
+	if c.Login.MaxFailures <= 0 || c.Login.IPMaxFailures <= 0 {
+		errs = append(errs, errors.New("login.max_failures and login.ip_max_failures must be positive"))
+	}
+	if c.Login.Lockout <= 0 || c.Login.MaxLockout < c.Login.Lockout || c.Login.ResetAfter <= 0 {
+		errs = append(errs, errors.New("login.lockout and login.reset_after must be positive and login.max_lockout at least login.lockout"))
+	}
+	if c.MFA.Issuer == "" || c.MFA.ChallengeTTL <= 0 {
+		errs = append(errs, errors.New("mfa.issuer is required and mfa.challenge_ttl must be positive"))
+	}
+	for _, role := range c.MFA.RequiredRoles {
+		if !slices.Contains(domain.Roles, role) {
+			errs = append(errs, fmt.Errorf("mfa.required_roles: unknown role %q", role))
+		}
+	}
+	if c.OIDC.Enabled() {
+		if c.OIDC.ClientID == "" || c.OIDC.RedirectURL == "" {
+			errs = append(errs, errors.New("oidc.client_id and oidc.redirect_url are required when oidc.issuer_url is set"))
+		}
+		if !slices.Contains(c.OIDC.Scopes, "openid") {
+			errs = append(errs, errors.New("oidc.scopes must include openid"))
+		}
+		if c.OIDC.LoginTTL <= 0 {
+			errs = append(errs, errors.New("oidc.login_ttl must be positive"))
+		}
+	}
+	if _, err := mail.ParseAddress(c.Mail.From); err != nil {
+		errs = append(errs, fmt.Errorf("mail.from: %w", err))
+	}
+	switch c.Mail.Driver {
+	case MailStdout:
+	case MailFile:
+		if c.Mail.File == "" {
+			errs = append(errs, errors.New("mail.file is required for the file mail driver"))
+		}
+	case MailSMTP:
+		if c.Mail.SMTP.Host == "" || c.Mail.SMTP.Port <= 0 {
+			errs = append(errs, errors.New("mail.smtp.host and mail.smtp.port are required for the smtp mail driver"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("mail.driver must be smtp, file or stdout, got %q", c.Mail.Driver))
+	}
+	if c.PasswordReset.TokenTTL <= 0 {
+		errs = append(errs, errors.New("password_reset.token_ttl must be positive"))
+	}
+	if c.Verification.TokenTTL <= 0 {
+		errs = append(errs, errors.New("email_verification.token_ttl must be positive"))
+	}
+	errs = append(errs, c.RateLimit.RateLimitPolicy.validate("rate_limit"))
+	for route, policy := range c.RateLimit.Routes {
+		if !slices.Contains(RateLimitRoutes, route) {
+			errs = append(errs, fmt.Errorf("rate_limit.routes: unknown route group %q (want one of %s)", route, strings.Join(RateLimitRoutes, ", ")))
+			continue
+		}
+		errs = append(errs, policy.validate("rate_limit.routes."+route))
+	}
+	switch c.RateLimit.Store {
+	case RateLimitMemory:
+	case RateLimitRedis:
+		if c.Redis.Addr == "" {
+			errs = append(errs, errors.New("redis.addr is required for the redis rate limit store"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("rate_limit.store must be memory or redis, got %q", c.RateLimit.Store))
+	}
+	if c.Loans.MaxBorrows <= 0 {
+		errs = append(errs, errors.New("loans.max_borrows must be positive"))
+	}
+	if c.Loans.Window.Std() < 24*time.Hour {
+		errs = append(errs, errors.New("loans.window must be at least 24h"))
+	}
+	if c.Loans.LoanPeriod <= 0 {
+		errs = append(errs, errors.New("loans.loan_period must be positive"))
+	}
+	if c.Server.ReadTimeout <= 0 || c.Server.WriteTimeout <= 0 || c.Server.IdleTimeout <= 0 || c.Server.ShutdownTimeout <= 0 ||
+		c.Server.RequestTimeout <= 0 || c.Server.HealthCheckTimeout <= 0 {
+		errs = append(errs, errors.New("server timeouts must be positive"))
+	}
+	if c.Metrics.Addr != "" {
+		if _, port, err := net.SplitHostPort(c.Metrics.Addr); err != nil || port == "" {
+			errs = append(errs, fmt.Errorf("metrics.addr must be host:port or :port, got %q", c.Metrics.Addr))
+		} else if port == c.Server.Port {
+			errs = append(errs, errors.New("metrics.addr must not use server.port; metrics are served on a separate listener"))
+		}
+	}
+	switch c.Tracing.Exporter {
+	case TracingNone, TracingStdout:
+	case TracingOTLP:
+		if c.Tracing.Endpoint == "" {
+			errs = append(errs, errors.New("tracing.endpoint is required for the otlp exporter"))
+		}
+	case TracingFile:
+		if c.Tracing.File == "" {
+			errs = append(errs, errors.New("tracing.file is required for the file exporter"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter must be none, otlp, stdout or file, got %q", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sample_ratio must be between 0 and 1"))
+	}
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log.level must be debug, info, warn or error, got %q", c.Log.Level))
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case LogJSON, LogText:
+	default:
+		errs = append(errs, fmt.Errorf("log.format must be json or text, got %q", c.Log.Format))
+	}
+	if c.Environment == EnvProduction {
+		if c.JWT.Secret == defaultJWTSecret || len(c.JWT.Secret) < 32 {
+			errs = append(errs, errors.New("jwt.secret must be changed from the default and be at least 32 characters in production"))
+		}
+		if c.Database.Driver != "sqlite" && c.Database.Password == defaultDBPassword {
+			errs = append(errs, errors.New("database.password must be changed from the default in production"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p RateLimitPolicy) validate(name string) error {
+	var errs []error
+	if p.RequestsPerMinute <= 0 || p.Burst <= 0 {
+		errs = append(errs, fmt.Errorf("%[1]s.requests_per_minute and %[1]s.burst must be positive", name))
+	}
+	switch p.Key {
+	case RateLimitByIP, RateLimitByUser, RateLimitByAPIKey:
+	default:
+		errs = append(errs, fmt.Errorf("%s.key must be ip, user or api_key, got %q", name, p.Key))
+	}
+	return errors.Join(errs...)
+}
+
+// Redacted returns a copy of the configuration with secrets masked,
+// suitable for printing or logging.
+func (c *Config) Redacted() *Config {
+	cp := *c
+	if cp.Database.Password != "" {
+		cp.Database.Password = redacted
+	}
+	if cp.JWT.Secret != "" {
+		cp.JWT.Secret = redacted
+	}
+	if cp.Redis.Password != "" {
+		cp.Redis.Password = redacted
+	}
+	if cp.Mail.SMTP.Password != "" {
+		cp.Mail.SMTP.Password = redacted
+	}
+	if cp.OIDC.ClientSecret != "" {
+		cp.OIDC.ClientSecret = redacted
+	}
+	return &cp
+}
+
+func setString(dst *string, key string) {
+	if val := os.Getenv(key); val != "" {
+		*dst = val
+	}
+}
+
+func setList(dst *[]string, key string) {
+	if val := os.Getenv(key); val != "" {
+		var out []string
+		for _, v := range strings.Split(val, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				out = append(out, v)
+			}
+		}
+		*dst = out
+	}
+}
+
+func setInt(dst *int, key string) error {
+	if val := os.Getenv(key); val != "" {
+		v, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		*dst = v
+	}
+	return nil
+}
+
+func setBool(dst *bool, key string) error {
+	if val := os.Getenv(key); val != "" {
+		v, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		*dst = v
+	}
+	return nil
+}
+
+func setDuration(dst *Duration, key string) error {
+	if val := os.Getenv(key); val != "" {
+		v, err := time.ParseDuration(val)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		*dst = Duration(v)
 	}
+	return nil
 }
 
-func getEnv(key, defaultValue string) string {
+func setFloat(dst *float64, key string) error {
 	if val := os.Getenv(key); val != "" {
-		return val
+		v, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		*dst = v
 	}
-	return defaultValue
+	return nil
 }
@@ -0,0 +1,208 @@
+// Unit tests for configuration loading and validation
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoadLayersFileUnderEnv(t *testing.T) {
+	files := map[string]string{
+		"app.yaml": "server:\n  port: \"9000\"\n  read_timeout: 5s\nloans:\n  max_borrows: 3\ncors:\n  allowed_origins: [\"https://example.com\"]\n",
+		"app.toml": "[server]\nport = \"9000\"\nread_timeout = \"5s\"\n[loans]\nmax_borrows = 3\n[cors]\nallowed_origins = [\"https://example.com\"]\n",
+	}
+	for name, body := range files {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", writeFile(t, name, body))
+			t.Setenv("LOAN_MAX_BORROWS", "2")
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if cfg.Server.Port != "9000" || cfg.Server.ReadTimeout.Std() != 5*time.Second {
+				t.Errorf("file values not applied: %+v", cfg.Server)
+			}
+			if cfg.Server.WriteTimeout.Std() != 30*time.Second {
+				t.Errorf("unset file value should keep default, got %v", cfg.Server.WriteTimeout.Std())
+			}
+			if cfg.Loans.MaxBorrows != 2 {
+				t.Errorf("env should override file, got %d", cfg.Loans.MaxBorrows)
+			}
+			if len(cfg.CORS.AllowedOrigins) != 1 || cfg.CORS.AllowedOrigins[0] != "https://example.com" {
+				t.Errorf("unexpected origins %v", cfg.CORS.AllowedOrigins)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsBadValues(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeFile(t, "app.json", "{}"))
+	if _, err := Load(); err == nil {
+		t.Fatal("expected unsupported format error")
+	}
+
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("RATE_LIMIT_BURST", "lots")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "RATE_LIMIT_BURST") {
+		t.Fatalf("expected RATE_LIMIT_BURST error, got %v", err)
+	}
+}
+
+func TestValidateRefusesDefaultSecretsInProduction(t *testing.T) {
+	cfg := Defaults()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("defaults should be valid in development: %v", err)
+	}
+
+	cfg.Environment = EnvProduction
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "jwt.secret") || !strings.Contains(err.Error(), "database.password") {
+		t.Fatalf("expected secret errors, got %v", err)
+	}
+
+	cfg.JWT.Secret = strings.Repeat("x", 32)
+	cfg.Database.Password = "s3cret"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Defaults()
+	cfg.Redis.Password = "s3cret"
+	cfg.Mail.SMTP.Password = "s3cret"
+	cfg.OIDC.ClientSecret = "s3cret"
+	red := cfg.Redacted()
+	if red.JWT.Secret != redacted || red.Database.Password != redacted || red.Redis.Password != redacted || red.Mail.SMTP.Password != redacted ||
+		red.OIDC.ClientSecret != redacted {
+		t.Fatalf("secrets not masked: %+v %+v", red.JWT, red.Database)
+	}
+	if cfg.JWT.Secret != defaultJWTSecret {
+		t.Fatal("Redacted must not modify the original")
+	}
+}
+
+func TestLoadRateLimitPolicies(t *testing.T) {
+	files := map[string]string{
+		"app.yaml": "rate_limit:\n  requests_per_minute: 50\n  burst: 60\n  key: ip\n  routes:\n    lending:\n      requests_per_minute: 10\n      burst: 2\n      key: user\n",
+		"app.toml": "[rate_limit]\nrequests_per_minute = 50\nburst = 60\nkey = \"ip\"\n[rate_limit.routes.lending]\nrequests_per_minute = 10\nburst = 2\nkey = \"user\"\n",
+	}
+	for name, body := range files {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", writeFile(t, name, body))
+			t.Setenv("RATE_LIMIT_AUTH_BURST", "3")
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if want := (RateLimitPolicy{RequestsPerMinute: 50, Burst: 60, Key: RateLimitByIP}); cfg.RateLimit.RateLimitPolicy != want {
+				t.Errorf("unexpected default policy %+v", cfg.RateLimit.RateLimitPolicy)
+			}
+			if want := (RateLimitPolicy{RequestsPerMinute: 10, Burst: 2, Key: RateLimitByUser}); cfg.RateLimit.Routes["lending"] != want {
+				t.Errorf("unexpected lending policy %+v", cfg.RateLimit.Routes["lending"])
+			}
+			if auth := cfg.RateLimit.Routes["auth"]; auth.Burst != 3 || auth.RequestsPerMinute != 20 {
+				t.Errorf("env should override the auth policy, got %+v", auth)
+			}
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateRateLimitPolicies(t *testing.T) {
+	cfg := Defaults()
+	cfg.RateLimit.Key = "cookie"
+	cfg.RateLimit.Routes["search"] = RateLimitPolicy{RequestsPerMinute: 1, Burst: 1, Key: RateLimitByIP}
+	cfg.RateLimit.Routes["auth"] = RateLimitPolicy{RequestsPerMinute: 1, Key: RateLimitByIP}
+	cfg.RateLimit.Store = RateLimitRedis
+	cfg.Redis.Addr = ""
+	err := cfg.Validate()
+	for _, want := range []string{"rate_limit.key", `unknown route group "search"`, "rate_limit.routes.auth.burst", "redis.addr"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in error, got %v", want, err)
+		}
+	}
+}
+
+func TestValidateMail(t *testing.T) {
+	cfg := Defaults()
+	cfg.Mail.Driver = MailSMTP
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "mail.smtp.host") {
+		t.Fatalf("expected the SMTP host to be required, got %v", err)
+	}
+	cfg.Mail.SMTP.Host = "smtp.example.com"
+	cfg.Mail.From = "not an address"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "mail.from") {
+		t.Fatalf("expected a bad sender to be rejected, got %v", err)
+	}
+	cfg.Mail.From = "Library <library@example.com>"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestValidateOIDC(t *testing.T) {
+	cfg := Defaults()
+	cfg.OIDC.IssuerURL = "https://idp.example.com"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "oidc.client_id") {
+		t.Fatalf("expected the client ID to be required, got %v", err)
+	}
+	cfg.OIDC.ClientID = "library"
+	cfg.OIDC.RedirectURL = "https://api.example.com/api/v1/auth/oidc/callback"
+	cfg.OIDC.Scopes = []string{"email"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "openid") {
+		t.Fatalf("expected the openid scope to be required, got %v", err)
+	}
+	cfg.OIDC.Scopes = []string{"openid", "email"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestValidateTrustedProxies(t *testing.T) {
+	cfg := Defaults()
+	cfg.Server.TrustedProxies = []string{"10.0.0.1", "172.16.0.0/12", "::1"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	cfg.Server.TrustedProxies = []string{"proxy.internal"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "server.trusted_proxies") {
+		t.Fatalf("expected a host name to be rejected, got %v", err)
+	}
+}
+
+func TestValidateMetrics(t *testing.T) {
+	cfg := Defaults()
+	for addr, want := range map[string]string{
+		"":               "",
+		"127.0.0.1:9090": "",
+		":9090":          "",
+		"9090":           "host:port",
+		":8080":          "server.port",
+	} {
+		cfg.Metrics.Addr = addr
+		err := cfg.Validate()
+		if want == "" && err != nil {
+			t.Errorf("%q: unexpected error %v", addr, err)
+		}
+		if want != "" && (err == nil || !strings.Contains(err.Error(), want)) {
+			t.Errorf("%q: expected an error about %s, got %v", addr, want, err)
+		}
+	}
+}
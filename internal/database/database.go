@@ -0,0 +1,95 @@
+// Package database opens the GORM connection for the configured
+// driver.
+package database
+
+import (
+	"book-lending-api/internal/config"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Open connects to the database described by cfg, retrying a few
+// times so that the API can start alongside a database container that
+// is still booting.
+func Open(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	dialector, err := dialectorFor(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var db *gorm.DB
+	maxRetries := 3
+	for i := 0; i < maxRetries; i++ {
+		// TranslateError reports constraint violations as gorm's own
+		// errors, such as gorm.ErrDuplicatedKey, whatever the driver,
+		// so that the repositories can recognise a lost race for a
+		// unique email or ISBN.
+		db, err = gorm.Open(dialector, &gorm.Config{TranslateError: true})
+		if err == nil {
+			sqlDB, err := db.DB()
+			if err != nil {
+				return nil, err
+			}
+			if IsInMemory(cfg) {
+				// Every connection to :memory: is a separate database, so
+				// the pool must never open a second one.
+				sqlDB.SetMaxOpenConns(1)
+				return db, nil
+			}
+			sqlDB.SetMaxIdleConns(10)
+			sqlDB.SetMaxOpenConns(100)
+			sqlDB.SetConnMaxLifetime(time.Hour)
+			return db, nil
+		}
+		time.Sleep(2 * time.Second)
+	}
+	return nil, fmt.Errorf("failed to connect to database after %d attempts: %w", maxRetries, err)
+}
+
+// IsInMemory reports whether cfg points at a transient in-memory
+// SQLite database, which must be migrated by the serving process
+// itself.
+func IsInMemory(cfg config.DatabaseConfig) bool {
+	return cfg.Driver == "sqlite" && strings.Contains(cfg.Path, ":memory:")
+}
+
+func dialectorFor(cfg config.DatabaseConfig) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case "mysql":
+		// clientFoundRows makes updates report the rows they matched,
+		// as PostgreSQL and SQLite do, rather than only those they
+		// changed, so that saving unchanged values is not mistaken for
+		// a missing row.
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local&clientFoundRows=true",
+			cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Name)
+		return mysql.Open(dsn), nil
+	case "postgres":
+		dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+			cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Name, cfg.SSLMode)
+		return postgres.Open(dsn), nil
+	case "sqlite":
+		// Foreign keys are off by default in SQLite.
+		sep := "?"
+		if strings.Contains(cfg.Path, "?") {
+			sep = "&"
+		}
+		return sqlite.Open(cfg.Path + sep + "_pragma=foreign_keys(1)"), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q (want mysql, postgres or sqlite)", cfg.Driver)
+	}
+}
+
+// Close releases the connection pool behind db.
+func Close(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
@@ -1,8 +1,13 @@
 package domain
 
+import "time"
+
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
+	// IP is the client address, filled in by the handler so that
+	// failed logins can be throttled per address.
+	IP string `json:"-"`
 }
 
 type RegisterRequest struct {
@@ -10,11 +15,107 @@ type RegisterRequest struct {
 	Password string `json:"password" binding:"required,min=6"`
 }
 
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+// UpdateProfileRequest changes the authenticated user's profile.
+// Omitted fields are left as they are; an empty name or phone clears
+// it.  Preferences, when given, replace the stored preferences as a
+// whole.
+type UpdateProfileRequest struct {
+	Name        *string          `json:"name" binding:"omitempty,max=255"`
+	Phone       *string          `json:"phone" binding:"omitempty,phone"`
+	Preferences *UserPreferences `json:"preferences"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+// ChangeEmailRequest moves the account to a new address, which must be
+// verified again.  The current password is required so that a stolen
+// token cannot be used to take the account over.
+type ChangeEmailRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// ListUsersRequest carries the staff user search parameters.  Q
+// matches part of the email or name.
+type ListUsersRequest struct {
+	Page   int    `form:"page,default=1" binding:"min=1"`
+	Limit  int    `form:"limit,default=10" binding:"min=1,max=100"`
+	Q      string `form:"q" binding:"max=255"`
+	Role   string `form:"role" binding:"omitempty,oneof=member librarian admin"`
+	Status string `form:"status" binding:"omitempty,oneof=active suspended unverified"`
+}
+
+type UpdateRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=member librarian admin"`
+}
+
 type AuthResponse struct {
 	Token string `json:"token"`
 	User  User   `json:"user"`
 }
 
+// MFAChallengeResponse is returned by login in place of AuthResponse
+// when the user has two-factor authentication enabled.  The challenge
+// token is exchanged, together with a code, for an access token.
+type MFAChallengeResponse struct {
+	MFARequired    bool   `json:"mfa_required"`
+	ChallengeToken string `json:"challenge_token"`
+	ExpiresIn      int    `json:"expires_in"`
+}
+
+type MFALoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// MFACodeRequest carries a TOTP code, or a recovery code where those
+// are accepted.
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// MFAEnrollment is a new, not yet active TOTP secret.  URI is the
+// otpauth:// form of the secret, for rendering as a QR code.
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// CreateAPIKeyRequest names a new API key and the scopes it is granted.
+// Keys without ExpiresAt last until revoked.
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1,dive,oneof=books:read books:write lending:read lending:write users:read users:write"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreatedAPIKey is a new API key together with the key itself, which
+// is shown only once.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
 type CreateBookRequest struct {
 	Title    string `json:"title" binding:"required"`
 	Author   string `json:"author" binding:"required"`
@@ -35,9 +136,13 @@ type BorrowBookRequest struct {
 	BookID uint `json:"book_id" binding:"required"`
 }
 
+// PaginationRequest carries either offset (page/limit) or keyset
+// (cursor/limit) pagination parameters.  Handlers switch to keyset
+// pagination whenever the cursor parameter is present, even if empty.
 type PaginationRequest struct {
-	Page  int `form:"page,default=1" binding:"min=1"`
-	Limit int `form:"limit,default=10" binding:"min=1,max=100"`
+	Page   int    `form:"page,default=1" binding:"min=1"`
+	Limit  int    `form:"limit,default=10" binding:"min=1,max=100"`
+	Cursor string `form:"cursor"`
 }
 
 type PaginatedResponse struct {
@@ -48,9 +153,70 @@ type PaginatedResponse struct {
 	TotalPages int         `json:"total_pages"`
 }
 
+// Cursor marks a position in a keyset‑ordered listing.  Scope names the
+// listing the cursor was issued for, so that it cannot be replayed
+// against another.  ID is always set; Timestamp is only used by
+// listings ordered by a time column.  Backward indicates the cursor
+// points towards the previous page.
+type Cursor struct {
+	Scope     string    `json:"scope"`
+	ID        uint      `json:"id"`
+	Timestamp time.Time `json:"ts,omitempty"`
+	Backward  bool      `json:"back,omitempty"`
+}
+
+// CursorPaginatedResponse is the envelope returned by keyset paginated
+// listings.  Cursors are opaque and omitted when there is no page in
+// that direction.
+type CursorPaginatedResponse struct {
+	Data       interface{} `json:"data"`
+	Limit      int         `json:"limit"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	PrevCursor string      `json:"prev_cursor,omitempty"`
+}
+
+// Allow-lists for the ?fields= and ?include= query parameters, keyed by
+// JSON field name.  Anything not listed is rejected with 400.
+var (
+	BookFields = []string{
+		"id", "title", "author", "isbn", "quantity", "category", "created_at", "updated_at",
+	}
+	LendingRecordFields = []string{
+		"id", "book_id", "user_id", "borrow_date", "return_date", "created_at", "updated_at", "book", "user",
+	}
+	LendingRecordIncludes = []string{"book", "user"}
+)
+
+// ErrorResponse is the body of every error reply.  Code is a stable
+// machine‑readable identifier taken from the domain error.
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message,omitempty"`
+	Error     string `json:"error"`
+	Code      string `json:"code,omitempty"`
+	Message   string `json:"message,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// ProblemDetails is an RFC 7807 application/problem+json body.  It is
+// returned instead of ErrorResponse when the client asks for it in the
+// Accept header.  Code, RequestID and Errors are extension members.
+type ProblemDetails struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes a single invalid request field.  Rule is the
+// failed validation tag (e.g. "required", "min") and Param its argument.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
 }
 
 type SuccessResponse struct {
@@ -0,0 +1,200 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrorKind classifies a domain error so that transports can map it to
+// an appropriate status without inspecting messages.
+type ErrorKind int
+
+const (
+	KindInternal ErrorKind = iota
+	KindInvalid
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindTooManyRequests
+	// KindTimeout means the request ran out of time, typically while
+	// waiting for the database.
+	KindTimeout
+	// KindCanceled means the client went away before the request
+	// finished.
+	KindCanceled
+	// KindLocked means the resource is temporarily locked, such as an
+	// account after too many failed logins.
+	KindLocked
+)
+
+// Error is a domain error with a stable machine‑readable code.  Two
+// errors are considered equal by errors.Is when their codes match, so
+// derived copies created with WithParams or WithCause still match the
+// sentinel they came from.  Message is the English text; it may carry
+// {name} placeholders that are filled from Params so translations can
+// reuse the same arguments.
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+	Params  map[string]string
+	Err     error
+}
+
+// NewError constructs a domain error.
+func NewError(kind ErrorKind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Is reports whether target is a domain error with the same code.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithParams returns a copy of the error with its message placeholders
+// filled in from params.
+func (e *Error) WithParams(params map[string]string) *Error {
+	cp := *e
+	cp.Params = params
+	cp.Message = Interpolate(e.Message, params)
+	return &cp
+}
+
+// WithCause returns a copy of the error wrapping cause.  The cause's
+// message replaces the default one.
+func (e *Error) WithCause(cause error) *Error {
+	cp := *e
+	cp.Message = cause.Error()
+	cp.Err = cause
+	return &cp
+}
+
+// Interpolate replaces {name} placeholders in template with values
+// from params.
+func Interpolate(template string, params map[string]string) string {
+	for k, v := range params {
+		template = strings.ReplaceAll(template, "{"+k+"}", v)
+	}
+	return template
+}
+
+// AsError extracts a domain error from err.  Context cancellation and
+// deadline errors become ErrRequestCanceled and ErrTimeout; any other
+// error that is not a domain error is reported as an internal error
+// wrapping err.
+func AsError(err error) *Error {
+	var de *Error
+	switch {
+	case errors.As(err, &de):
+		return de
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrTimeout.WithCause(err)
+	case errors.Is(err, context.Canceled):
+		return ErrRequestCanceled.WithCause(err)
+	default:
+		return ErrInternal.WithCause(err)
+	}
+}
+
+// Generic errors used across resources.
+var (
+	ErrInternal        = NewError(KindInternal, "internal_error", "internal server error")
+	ErrInvalidRequest  = NewError(KindInvalid, "invalid_request", "invalid request")
+	ErrInvalidCursor   = NewError(KindInvalid, "invalid_cursor", "invalid pagination cursor")
+	ErrInvalidQuery    = NewError(KindInvalid, "unsupported_query_value", "unsupported {param} value {value}; allowed: {allowed}")
+	ErrUnauthorized    = NewError(KindUnauthorized, "unauthorized", "authentication required")
+	ErrForbidden       = NewError(KindForbidden, "forbidden", "you do not have permission to perform this action")
+	ErrNotFound        = NewError(KindNotFound, "not_found", "record not found")
+	ErrRateLimited     = NewError(KindTooManyRequests, "rate_limited", "too many requests, please try again later")
+	ErrTimeout         = NewError(KindTimeout, "request_timeout", "the request took too long to complete")
+	ErrRequestCanceled = NewError(KindCanceled, "request_canceled", "the request was canceled by the client")
+	ErrConflict        = NewError(KindConflict, "conflict", "the record conflicts with an existing one")
+)
+
+// Authentication errors.
+var (
+	ErrEmailTaken         = NewError(KindConflict, "email_taken", "user with this email already exists")
+	ErrInvalidCredentials = NewError(KindUnauthorized, "invalid_credentials", "invalid credentials")
+	ErrMissingAuthHeader  = NewError(KindUnauthorized, "missing_authorization", "Authorization header required")
+	ErrMalformedAuth      = NewError(KindUnauthorized, "malformed_authorization", "Invalid authorization header format")
+	ErrInvalidToken       = NewError(KindUnauthorized, "invalid_token", "Invalid or expired token")
+	ErrAccountLocked      = NewError(KindLocked, "account_locked", "account temporarily locked after too many failed logins, try again in {retry_after} seconds")
+	ErrTooManyLogins      = NewError(KindTooManyRequests, "too_many_login_attempts", "too many failed logins from this address, try again in {retry_after} seconds")
+	ErrInvalidResetToken  = NewError(KindInvalid, "invalid_reset_token", "password reset token is invalid or has expired")
+	ErrInvalidVerifyToken = NewError(KindInvalid, "invalid_verification_token", "email verification token is invalid or has expired")
+	ErrAlreadyVerified    = NewError(KindConflict, "email_already_verified", "email address is already verified")
+	ErrInvalidMFACode     = NewError(KindUnauthorized, "invalid_mfa_code", "invalid authentication code")
+	ErrMFARequired        = NewError(KindForbidden, "mfa_required", "two-factor authentication is required for your role; enable it and log in again")
+	ErrMFAMandatory       = NewError(KindForbidden, "mfa_mandatory", "two-factor authentication cannot be disabled for your role")
+	ErrMFAAlreadyEnabled  = NewError(KindConflict, "mfa_already_enabled", "two-factor authentication is already enabled")
+	ErrMFANotEnabled      = NewError(KindConflict, "mfa_not_enabled", "two-factor authentication is not enabled")
+	ErrMFANotEnrolled     = NewError(KindConflict, "mfa_not_enrolled", "start two-factor enrolment before activating it")
+)
+
+// Single sign-on errors.
+var (
+	ErrInvalidSSOState     = NewError(KindInvalid, "invalid_sso_state", "single sign-on attempt is invalid or has expired; please start again")
+	ErrSSOFailed           = NewError(KindUnauthorized, "sso_failed", "single sign-on failed; please try again")
+	ErrSSOEmailNotVerified = NewError(KindForbidden, "sso_email_not_verified", "your identity provider has not verified your email address")
+)
+
+// Account errors.
+var (
+	ErrIncorrectPassword = NewError(KindForbidden, "incorrect_password", "current password is incorrect")
+	ErrPasswordNotSet    = NewError(KindConflict, "password_not_set", "this account has no password yet; set one with a password reset first")
+	ErrActiveLoans       = NewError(KindConflict, "active_loans", "return your borrowed books before deleting your account")
+)
+
+// API key errors.
+var (
+	ErrInvalidAPIKey       = NewError(KindUnauthorized, "invalid_api_key", "Invalid, expired or revoked API key")
+	ErrAPIKeyNotAllowed    = NewError(KindForbidden, "api_key_not_allowed", "this endpoint cannot be used with an API key")
+	ErrInsufficientScope   = NewError(KindForbidden, "insufficient_scope", "this API key lacks the {scope} scope")
+	ErrInvalidAPIKeyID     = NewError(KindInvalid, "invalid_api_key_id", "Invalid API key ID")
+	ErrAPIKeyNotFound      = NewError(KindNotFound, "api_key_not_found", "API key not found")
+	ErrAPIKeyExpiryInPast  = NewError(KindInvalid, "api_key_expiry_in_past", "API key expiry must be in the future")
+	ErrAPIKeyLimitExceeded = NewError(KindConflict, "api_key_limit_exceeded", "you can have at most {max} API keys; revoke one first")
+)
+
+// Session errors.
+var (
+	ErrSessionRevoked   = NewError(KindUnauthorized, "session_revoked", "this session has been signed out; please log in again")
+	ErrInvalidSessionID = NewError(KindInvalid, "invalid_session_id", "Invalid session ID")
+	ErrSessionNotFound  = NewError(KindNotFound, "session_not_found", "session not found")
+)
+
+// User administration errors.
+var (
+	ErrInvalidUserID    = NewError(KindInvalid, "invalid_user_id", "Invalid user ID")
+	ErrUserNotFound     = NewError(KindNotFound, "user_not_found", "user not found")
+	ErrCannotModifySelf = NewError(KindForbidden, "cannot_modify_self", "you cannot change the role or status of your own account")
+	ErrAccountSuspended = NewError(KindForbidden, "account_suspended", "this account is suspended; please contact the library")
+	ErrAlreadySuspended = NewError(KindConflict, "account_already_suspended", "account is already suspended")
+	ErrNotSuspended     = NewError(KindConflict, "account_not_suspended", "account is not suspended")
+)
+
+// Catalogue errors.
+var (
+	ErrInvalidBookID = NewError(KindInvalid, "invalid_book_id", "Invalid book ID")
+	ErrBookNotFound  = NewError(KindNotFound, "book_not_found", "book not found")
+	ErrDuplicateISBN = NewError(KindConflict, "duplicate_isbn", "book with this ISBN already exists")
+)
+
+// Lending errors.
+var (
+	ErrInvalidRecordID       = NewError(KindInvalid, "invalid_record_id", "Invalid lending record ID")
+	ErrLendingRecordNotFound = NewError(KindNotFound, "lending_record_not_found", "lending record not found")
+	ErrNotRecordOwner        = NewError(KindForbidden, "not_record_owner", "unauthorized: this lending record does not belong to you")
+	ErrEmailNotVerified      = NewError(KindForbidden, "email_not_verified", "verify your email address before borrowing books")
+	ErrAlreadyBorrowed       = NewError(KindConflict, "already_borrowed", "you have already borrowed this book")
+	ErrBorrowLimitExceeded   = NewError(KindConflict, "borrow_limit_exceeded", "borrowing limit exceeded: maximum {max} books in {days} days")
+	ErrBookUnavailable       = NewError(KindConflict, "book_unavailable", "book is not available for borrowing")
+	ErrAlreadyReturned       = NewError(KindConflict, "already_returned", "book has already been returned")
+)
@@ -1,13 +1,93 @@
 package domain
 
-import "time"
+import (
+	"slices"
+	"strings"
+	"time"
+)
 
+// User roles.  Members borrow books; librarians and admins are staff.
+const (
+	RoleMember    = "member"
+	RoleLibrarian = "librarian"
+	RoleAdmin     = "admin"
+)
+
+// Roles lists every valid user role.
+var Roles = []string{RoleMember, RoleLibrarian, RoleAdmin}
+
+// User is an account.  BorrowLimitResetAt, when set by staff, starts
+// the borrowing window afresh: earlier borrows no longer count towards
+// the limit.
 type User struct {
-	ID           uint      `json:"id" gorm:"primaryKey"`
-	Email        string    `json:"email" gorm:"type:varchar(255);uniqueIndex;not null"`
-	PasswordHash string    `json:"-" gorm:"type:varchar(255);not null"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID                 uint            `json:"id" gorm:"primaryKey"`
+	Email              string          `json:"email" gorm:"type:varchar(255);uniqueIndex;not null"`
+	PasswordHash       string          `json:"-" gorm:"type:varchar(255);not null"`
+	Role               string          `json:"role" gorm:"type:varchar(20);not null;default:member"`
+	Name               string          `json:"name" gorm:"type:varchar(255);not null;default:''"`
+	Phone              string          `json:"phone" gorm:"type:varchar(32);not null;default:''"`
+	Preferences        UserPreferences `json:"preferences" gorm:"type:text;serializer:json"`
+	EmailVerifiedAt    *time.Time      `json:"email_verified_at"`
+	SuspendedAt        *time.Time      `json:"suspended_at"`
+	BorrowLimitResetAt *time.Time      `json:"borrow_limit_reset_at"`
+	CreatedAt          time.Time       `json:"created_at"`
+	UpdatedAt          time.Time       `json:"updated_at"`
+}
+
+// Suspended reports whether staff have suspended the account, which
+// stops the user logging in or borrowing.
+func (u *User) Suspended() bool {
+	return u.SuspendedAt != nil
+}
+
+// HasPassword reports whether the user can log in with a password.
+// Users provisioned by single sign-on, and users whose unverified
+// password was discarded when the address's owner signed in, have none
+// until they reset it.
+func (u *User) HasPassword() bool {
+	return u.PasswordHash != ""
+}
+
+// NormalizeEmail returns the form email addresses are stored and looked
+// up in, so that one address cannot belong to two accounts by being
+// written in different cases.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Account statuses that user listings can be filtered by.
+const (
+	UserStatusActive     = "active"
+	UserStatusSuspended  = "suspended"
+	UserStatusUnverified = "unverified"
+)
+
+// UserFilter narrows a user listing.  Query matches part of the email
+// or name, case-insensitively.  Empty fields match every user.
+type UserFilter struct {
+	Query  string
+	Role   string
+	Status string
+}
+
+// Actor is the authenticated user performing an administrative action.
+type Actor struct {
+	ID   uint
+	Role string
+}
+
+// UserPreferences are settings a user chooses for themselves.  They
+// are stored as a JSON document, so fields can be added without a
+// migration.  Empty fields mean the user has no preference.
+type UserPreferences struct {
+	Language string `json:"language" binding:"omitempty,oneof=en id"`
+	Timezone string `json:"timezone" binding:"omitempty,timezone"`
+}
+
+// EmailVerified reports whether the user has confirmed that they own
+// their email address.
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
 }
 
 type Book struct {
@@ -21,6 +101,12 @@ type Book struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// TimestampPrecision is the precision of keyset timestamp columns such
+// as borrow_date.  MySQL TIMESTAMP columns keep whole seconds, so
+// values are truncated to that everywhere: a cursor only finds its
+// place if it holds exactly what the database stored.
+const TimestampPrecision = time.Second
+
 type LendingRecord struct {
 	ID         uint       `json:"id" gorm:"primaryKey"`
 	BookID     uint       `json:"book_id" gorm:"not null"`
@@ -29,8 +115,241 @@ type LendingRecord struct {
 	ReturnDate *time.Time `json:"return_date"`
 	CreatedAt  time.Time  `json:"created_at"`
 	UpdatedAt  time.Time  `json:"updated_at"`
-	Book       Book       `json:"book" gorm:"foreignKey:BookID"`
-	User       User       `json:"user" gorm:"foreignKey:UserID"`
+	Book       *Book      `json:"book,omitempty" gorm:"foreignKey:BookID"`
+	User       *User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
 }
 
 func (LendingRecord) TableName() string { return "lending_records" }
+
+// LoanPolicy limits how many books a user may borrow within a rolling
+// window.  A loan still open after LoanPeriod is overdue.
+type LoanPolicy struct {
+	MaxBorrows int
+	Window     time.Duration
+	LoanPeriod time.Duration
+}
+
+// DefaultLoanPolicy allows five books in any seven days, each for up
+// to fourteen days.
+var DefaultLoanPolicy = LoanPolicy{MaxBorrows: 5, Window: 7 * 24 * time.Hour, LoanPeriod: 14 * 24 * time.Hour}
+
+// IsOverdue reports whether record was, or still is, kept longer than
+// the loan period as of now.
+func (p LoanPolicy) IsOverdue(record *LendingRecord, now time.Time) bool {
+	end := now
+	if record.ReturnDate != nil {
+		end = *record.ReturnDate
+	}
+	return end.Sub(record.BorrowDate) > p.LoanPeriod
+}
+
+// Purposes of single-use user tokens.
+const (
+	TokenPasswordReset     = "password_reset"
+	TokenEmailVerification = "email_verification"
+)
+
+// UserToken is a single-use secret sent to a user, such as a password
+// reset link.  Only the SHA-256 hash of the secret is stored.
+type UserToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null"`
+	Purpose   string    `gorm:"type:varchar(32);not null"`
+	TokenHash string    `gorm:"type:char(64);uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (UserToken) TableName() string { return "user_tokens" }
+
+// UserMFA holds a user's TOTP secret.  The secret is pending until the
+// user proves their authenticator app works by entering a first code,
+// which sets EnabledAt.  LastUsedStep is the time step of the last code
+// accepted; codes from that step or earlier are refused so that an
+// observed code cannot be replayed.
+type UserMFA struct {
+	UserID       uint   `gorm:"primaryKey;autoIncrement:false"`
+	Secret       string `gorm:"type:varchar(64);not null"`
+	EnabledAt    *time.Time
+	LastUsedStep int64 `gorm:"not null;default:0"`
+	CreatedAt    time.Time
+}
+
+func (UserMFA) TableName() string { return "user_mfa" }
+
+// Enabled reports whether the secret has been confirmed and is required
+// at login.
+func (m *UserMFA) Enabled() bool {
+	return m.EnabledAt != nil
+}
+
+// MFARecoveryCode is a single-use code that stands in for a TOTP code
+// when the user has lost their authenticator.  Only its SHA-256 hash is
+// stored.
+type MFARecoveryCode struct {
+	ID       uint   `gorm:"primaryKey"`
+	UserID   uint   `gorm:"not null;index"`
+	CodeHash string `gorm:"type:char(64);not null"`
+	UsedAt   *time.Time
+}
+
+func (MFARecoveryCode) TableName() string { return "mfa_recovery_codes" }
+
+// Scopes an API key can be granted.  A write scope also grants the
+// matching read scope.
+const (
+	ScopeBooksRead    = "books:read"
+	ScopeBooksWrite   = "books:write"
+	ScopeLendingRead  = "lending:read"
+	ScopeLendingWrite = "lending:write"
+	ScopeUsersRead    = "users:read"
+	ScopeUsersWrite   = "users:write"
+)
+
+// APIKeyScopes lists every scope in the order they are documented.
+var APIKeyScopes = []string{
+	ScopeBooksRead, ScopeBooksWrite,
+	ScopeLendingRead, ScopeLendingWrite,
+	ScopeUsersRead, ScopeUsersWrite,
+}
+
+// APIKey lets scripts act as a user without their password.  The key
+// acts with the user's current role, limited to its scopes.  Only the
+// SHA-256 hash of the key is stored; Prefix is the start of the key,
+// kept so that users can tell their keys apart.  MFA records whether the
+// key was created in a session that passed two-factor authentication,
+// so that a key only satisfies routes requiring a second factor if its
+// creator did.
+type APIKey struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"-" gorm:"not null;index"`
+	Name       string     `json:"name" gorm:"type:varchar(100);not null"`
+	Prefix     string     `json:"prefix" gorm:"type:varchar(16);not null"`
+	KeyHash    string     `json:"-" gorm:"type:char(64);uniqueIndex;not null"`
+	Scopes     []string   `json:"scopes" gorm:"type:text;serializer:json"`
+	MFA        bool       `json:"-" gorm:"not null;default:false"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (APIKey) TableName() string { return "api_keys" }
+
+// HasScope reports whether the key was granted scope, directly or
+// through the matching write scope.
+func (k *APIKey) HasScope(scope string) bool {
+	if slices.Contains(k.Scopes, scope) {
+		return true
+	}
+	resource, ok := strings.CutSuffix(scope, ":read")
+	return ok && slices.Contains(k.Scopes, resource+":write")
+}
+
+// Expired reports whether the key has passed its expiry as of now.
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// Session is a login on one device, created whenever an access token
+// is issued and named by the token's sid claim.  Tokens stop working as
+// soon as their session is revoked.  Device is a short description of
+// the client derived from UserAgent; IP and LastSeenAt are updated as
+// the session is used.  Current is set when listing sessions for the
+// one making the request.
+type Session struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	UserID     uint      `json:"-" gorm:"not null;index"`
+	Device     string    `json:"device" gorm:"type:varchar(100);not null"`
+	IP         string    `json:"ip" gorm:"type:varchar(45);not null"`
+	UserAgent  string    `json:"user_agent" gorm:"type:varchar(512);not null"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at" gorm:"not null"`
+	ExpiresAt  time.Time `json:"expires_at" gorm:"not null"`
+	Current    bool      `json:"current" gorm:"-"`
+}
+
+func (Session) TableName() string { return "sessions" }
+
+// UserIdentity links a user to an account at a single sign-on
+// provider, which names the account by its issuer and subject.
+type UserIdentity struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"not null;index"`
+	Issuer    string `gorm:"type:varchar(255);not null"`
+	Subject   string `gorm:"type:varchar(255);not null"`
+	CreatedAt time.Time
+}
+
+func (UserIdentity) TableName() string { return "user_identities" }
+
+// SSOLogin is a single sign-on attempt waiting for the provider to
+// send the user back.  The state handed to the provider is stored
+// hashed; the nonce and PKCE code verifier never leave the server, so
+// an intercepted authorization code cannot be redeemed elsewhere.
+type SSOLogin struct {
+	ID           uint      `gorm:"primaryKey"`
+	StateHash    string    `gorm:"type:char(64);uniqueIndex;not null"`
+	Nonce        string    `gorm:"type:varchar(64);not null"`
+	CodeVerifier string    `gorm:"type:varchar(128);not null"`
+	ExpiresAt    time.Time `gorm:"not null"`
+	CreatedAt    time.Time
+}
+
+func (SSOLogin) TableName() string { return "sso_logins" }
+
+// LoginResult is the outcome of checking a password.  When MFARequired
+// is set the password was right, but the user must still enter a code
+// from their authenticator app before being issued a token.
+type LoginResult struct {
+	User        *User
+	MFARequired bool
+}
+
+// LoginThrottle counts consecutive failed logins for one subject: an
+// account (by email) or a client address.
+type LoginThrottle struct {
+	Subject       string    `gorm:"primaryKey;type:varchar(255)"`
+	Failures      int       `gorm:"not null;default:0"`
+	LastFailureAt time.Time `gorm:"not null"`
+	LockedUntil   *time.Time
+}
+
+func (LoginThrottle) TableName() string { return "login_throttles" }
+
+// LoginPolicy throttles password guessing.  Once an account has
+// MaxFailures consecutive failed logins, or a client address
+// IPMaxFailures, further attempts are refused for Lockout, doubling
+// with every further failure up to MaxLockout.  Failures are forgotten
+// once ResetAfter has passed since the last failure or lockout.
+type LoginPolicy struct {
+	MaxFailures   int
+	IPMaxFailures int
+	Lockout       time.Duration
+	MaxLockout    time.Duration
+	ResetAfter    time.Duration
+}
+
+// DefaultLoginPolicy locks an account for a minute after five failures
+// and an address after twenty, for at most an hour.
+var DefaultLoginPolicy = LoginPolicy{
+	MaxFailures:   5,
+	IPMaxFailures: 20,
+	Lockout:       time.Minute,
+	MaxLockout:    time.Hour,
+	ResetAfter:    15 * time.Minute,
+}
+
+// LockoutFor returns how long to lock a subject after failures
+// consecutive failures when the threshold is limit, or zero while it is
+// below the threshold.
+func (p LoginPolicy) LockoutFor(failures, limit int) time.Duration {
+	if failures < limit {
+		return 0
+	}
+	d := p.Lockout
+	for i := limit; i < failures && d < p.MaxLockout; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxLockout)
+}
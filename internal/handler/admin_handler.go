@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/middleware"
+	"book-lending-api/internal/usecase"
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler exposes account administration to staff.
+type AdminHandler struct {
+	authUseCase      usecase.AuthUseCase
+	userAdminUseCase usecase.UserAdminUseCase
+	lendingUseCase   usecase.LendingUseCase
+}
+
+// NewAdminHandler constructs a new AdminHandler.
+func NewAdminHandler(authUseCase usecase.AuthUseCase, userAdminUseCase usecase.UserAdminUseCase, lendingUseCase usecase.LendingUseCase) *AdminHandler {
+	return &AdminHandler{
+		authUseCase:      authUseCase,
+		userAdminUseCase: userAdminUseCase,
+		lendingUseCase:   lendingUseCase,
+	}
+}
+
+// ListUsers returns a paginated list of users.  The optional q, role
+// and status query parameters narrow it down.
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	var req domain.ListUsersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		_ = c.Error(domain.ErrInvalidRequest.WithCause(err))
+		return
+	}
+	filter := domain.UserFilter{Query: req.Q, Role: req.Role, Status: req.Status}
+	result, err := h.userAdminUseCase.ListUsers(c.Request.Context(), filter, req.Page, req.Limit)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetUser returns the user named by the :id path parameter.
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	id, err := parseUserID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	user, err := h.userAdminUseCase.GetUser(c.Request.Context(), id)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// GetUserActiveBorrowings lists the books the user named by the :id
+// path parameter has not returned yet.  It accepts the same fields and
+// include parameters as the user's own listing.
+func (h *AdminHandler) GetUserActiveBorrowings(c *gin.Context) {
+	id, ok := h.existingUserID(c)
+	if !ok {
+		return
+	}
+	respondActiveBorrowings(c, h.lendingUseCase, id)
+}
+
+// GetUserBorrowingHistory returns the borrowing history of the user
+// named by the :id path parameter, paginated like the user's own.
+func (h *AdminHandler) GetUserBorrowingHistory(c *gin.Context) {
+	id, ok := h.existingUserID(c)
+	if !ok {
+		return
+	}
+	respondBorrowingHistory(c, h.lendingUseCase, id)
+}
+
+// ChangeRole gives the user named by the :id path parameter a new role
+// and returns the updated user.
+func (h *AdminHandler) ChangeRole(c *gin.Context) {
+	actor, id, ok := actorAndUserID(c)
+	if !ok {
+		return
+	}
+	var req domain.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(domain.ErrInvalidRequest.WithCause(err))
+		return
+	}
+	user, err := h.userAdminUseCase.ChangeRole(c.Request.Context(), actor, id, req.Role)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// SuspendUser suspends the user named by the :id path parameter and
+// returns the updated user.  Suspended users cannot log in or borrow.
+func (h *AdminHandler) SuspendUser(c *gin.Context) {
+	h.changeUser(c, h.userAdminUseCase.Suspend)
+}
+
+// ReactivateUser lifts the suspension of the user named by the :id
+// path parameter and returns the updated user.
+func (h *AdminHandler) ReactivateUser(c *gin.Context) {
+	h.changeUser(c, h.userAdminUseCase.Reactivate)
+}
+
+// ResetBorrowLimit lets the user named by the :id path parameter borrow
+// up to the full limit again and returns the updated user.
+func (h *AdminHandler) ResetBorrowLimit(c *gin.Context) {
+	h.changeUser(c, h.userAdminUseCase.ResetBorrowLimit)
+}
+
+// UnlockUser lifts a login lockout on the user named by the :id path
+// parameter.  Unknown users return 404.
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	id, err := parseUserID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if err := h.authUseCase.UnlockAccount(c.Request.Context(), id); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, domain.SuccessResponse{Message: "Account unlocked successfully"})
+}
+
+// changeUser runs an account change without a request body on the
+// user named by the :id path parameter and writes the updated user.
+func (h *AdminHandler) changeUser(c *gin.Context, change func(context.Context, domain.Actor, uint) (*domain.User, error)) {
+	actor, id, ok := actorAndUserID(c)
+	if !ok {
+		return
+	}
+	user, err := change(c.Request.Context(), actor, id)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// existingUserID reads the :id path parameter and checks that the user
+// exists, so that listings for unknown users return 404 rather than an
+// empty list.  On failure the error has been recorded.
+func (h *AdminHandler) existingUserID(c *gin.Context) (uint, bool) {
+	id, err := parseUserID(c)
+	if err == nil {
+		_, err = h.userAdminUseCase.GetUser(c.Request.Context(), id)
+	}
+	if err != nil {
+		_ = c.Error(err)
+		return 0, false
+	}
+	return id, true
+}
+
+// actorAndUserID returns the authenticated user and the :id path
+// parameter.  On failure the error has been recorded.
+func actorAndUserID(c *gin.Context) (domain.Actor, uint, bool) {
+	actorID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		_ = c.Error(domain.ErrUnauthorized)
+		return domain.Actor{}, 0, false
+	}
+	id, err := parseUserID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return domain.Actor{}, 0, false
+	}
+	return domain.Actor{ID: actorID, Role: middleware.GetUserRoleFromContext(c)}, id, true
+}
+
+// parseUserID reads the :id path parameter.
+func parseUserID(c *gin.Context) (uint, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return 0, domain.ErrInvalidUserID
+	}
+	return uint(id), nil
+}
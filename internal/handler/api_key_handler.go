@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/middleware"
+	"book-lending-api/internal/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHandler wires the authenticated user's API keys to HTTP
+// requests.
+type APIKeyHandler struct {
+	apiKeyUseCase usecase.APIKeyUseCase
+}
+
+// NewAPIKeyHandler constructs a new APIKeyHandler.
+func NewAPIKeyHandler(apiKeyUseCase usecase.APIKeyUseCase) *APIKeyHandler {
+	return &APIKeyHandler{apiKeyUseCase: apiKeyUseCase}
+}
+
+// CreateAPIKey issues a new key to the authenticated user.  The
+// response is the only place the key itself is shown.
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		_ = c.Error(domain.ErrUnauthorized)
+		return
+	}
+	var req domain.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(domain.ErrInvalidRequest.WithCause(err))
+		return
+	}
+	key, err := h.apiKeyUseCase.Create(c.Request.Context(), userID, c.GetBool("user_mfa"), req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, key)
+}
+
+// ListAPIKeys returns the authenticated user's keys, without the keys
+// themselves.
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		_ = c.Error(domain.ErrUnauthorized)
+		return
+	}
+	keys, err := h.apiKeyUseCase.List(c.Request.Context(), userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKey deletes the authenticated user's key named by the :id
+// path parameter.
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		_ = c.Error(domain.ErrUnauthorized)
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		_ = c.Error(domain.ErrInvalidAPIKeyID)
+		return
+	}
+	if err := h.apiKeyUseCase.Revoke(c.Request.Context(), userID, uint(id)); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, domain.SuccessResponse{Message: "API key revoked successfully"})
+}
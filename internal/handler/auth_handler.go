@@ -5,19 +5,24 @@ import (
 	"book-lending-api/internal/usecase"
 	"book-lending-api/pkg"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 // AuthHandler wires authentication use cases to HTTP requests.
 type AuthHandler struct {
-	authUseCase usecase.AuthUseCase
-	jwtUtil     *pkg.JWTUtil
+	authUseCase    usecase.AuthUseCase
+	sessionUseCase usecase.SessionUseCase
+	jwtUtil        *pkg.JWTUtil
+	challengeTTL   time.Duration
 }
 
-// NewAuthHandler constructs a new AuthHandler.
-func NewAuthHandler(authUseCase usecase.AuthUseCase, jwtUtil *pkg.JWTUtil) *AuthHandler {
-	return &AuthHandler{authUseCase: authUseCase, jwtUtil: jwtUtil}
+// NewAuthHandler constructs a new AuthHandler.  Every token it issues
+// belongs to a new session.  Users with two-factor authentication have
+// challengeTTL to enter their code after the password.
+func NewAuthHandler(authUseCase usecase.AuthUseCase, sessionUseCase usecase.SessionUseCase, jwtUtil *pkg.JWTUtil, challengeTTL time.Duration) *AuthHandler {
+	return &AuthHandler{authUseCase: authUseCase, sessionUseCase: sessionUseCase, jwtUtil: jwtUtil, challengeTTL: challengeTTL}
 }
 
 // Register handles user registration.  On success it returns a
@@ -26,61 +31,102 @@ func NewAuthHandler(authUseCase usecase.AuthUseCase, jwtUtil *pkg.JWTUtil) *Auth
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req domain.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, domain.ErrorResponse{
-			Error:   "Bad Request",
-			Message: err.Error(),
-		})
+		_ = c.Error(domain.ErrInvalidRequest.WithCause(err))
 		return
 	}
-	user, err := h.authUseCase.Register(req)
+	user, err := h.authUseCase.Register(c.Request.Context(), req)
 	if err != nil {
-		status := http.StatusInternalServerError
-		if err.Error() == "user with this email already exists" {
-			status = http.StatusConflict
-		}
-		c.JSON(status, domain.ErrorResponse{
-			Error:   "Registration failed",
-			Message: err.Error(),
-		})
+		_ = c.Error(err)
 		return
 	}
-	token, err := h.jwtUtil.GenerateToken(user)
+	token, err := issueToken(c, h.sessionUseCase, h.jwtUtil, user, false)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
-			Error:   "Token generation failed",
-			Message: err.Error(),
-		})
+		_ = c.Error(err)
 		return
 	}
 	c.JSON(http.StatusCreated, domain.AuthResponse{Token: token, User: *user})
 }
 
 // Login handles user authentication.  On success it returns a new
-// token and user object.  Invalid credentials return 401.
+// token and user object, or for users with two-factor authentication a
+// challenge token to pass to LoginMFA with their code.  Invalid
+// credentials return 401; a locked account returns 423 and an address
+// with too many failures 429, both with Retry-After.
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req domain.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, domain.ErrorResponse{
-			Error:   "Bad Request",
-			Message: err.Error(),
-		})
+		_ = c.Error(domain.ErrInvalidRequest.WithCause(err))
 		return
 	}
-	user, err := h.authUseCase.Login(req)
+	req.IP = c.ClientIP()
+	result, err := h.authUseCase.Login(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, domain.ErrorResponse{
-			Error:   "Login failed",
-			Message: err.Error(),
-		})
+		_ = c.Error(err)
+		return
+	}
+	respondLogin(c, h.sessionUseCase, h.jwtUtil, h.challengeTTL, result)
+}
+
+// LoginMFA completes a login by exchanging a challenge token from Login
+// and a TOTP or recovery code for an access token.  An expired or
+// invalid challenge returns 401, as does a wrong code; wrong codes
+// count towards the same lockouts as wrong passwords.
+func (h *AuthHandler) LoginMFA(c *gin.Context) {
+	var req domain.MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(domain.ErrInvalidRequest.WithCause(err))
 		return
 	}
-	token, err := h.jwtUtil.GenerateToken(user)
+	userID, err := h.jwtUtil.ValidateMFAChallenge(req.ChallengeToken)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
-			Error:   "Token generation failed",
-			Message: err.Error(),
-		})
+		_ = c.Error(domain.ErrInvalidToken)
+		return
+	}
+	user, err := h.authUseCase.LoginMFA(c.Request.Context(), userID, req.Code, c.ClientIP())
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	token, err := issueToken(c, h.sessionUseCase, h.jwtUtil, user, true)
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, domain.AuthResponse{Token: token, User: *user})
 }
+
+// respondLogin writes an access token for a successful login, or a
+// challenge token valid for challengeTTL if the user must still enter
+// a two-factor code.
+func respondLogin(c *gin.Context, sessions usecase.SessionUseCase, jwtUtil *pkg.JWTUtil, challengeTTL time.Duration, result *domain.LoginResult) {
+	if result.MFARequired {
+		challenge, err := jwtUtil.GenerateMFAChallenge(result.User, challengeTTL)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, domain.MFAChallengeResponse{
+			MFARequired:    true,
+			ChallengeToken: challenge,
+			ExpiresIn:      int(challengeTTL.Seconds()),
+		})
+		return
+	}
+	token, err := issueToken(c, sessions, jwtUtil, result.User, false)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, domain.AuthResponse{Token: token, User: *result.User})
+}
+
+// issueToken starts a session for user on the device making the request
+// and returns an access token for it.  mfa records whether the user
+// logged in with a second factor.
+func issueToken(c *gin.Context, sessions usecase.SessionUseCase, jwtUtil *pkg.JWTUtil, user *domain.User, mfa bool) (string, error) {
+	session, err := sessions.Create(c.Request.Context(), user.ID, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		return "", err
+	}
+	return jwtUtil.GenerateToken(user, mfa, session.ID)
+}
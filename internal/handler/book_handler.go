@@ -24,62 +24,60 @@ func NewBookHandler(uc usecase.BookUseCase) *BookHandler {
 func (h *BookHandler) CreateBook(c *gin.Context) {
 	var req domain.CreateBookRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Bad Request", Message: err.Error()})
+		_ = c.Error(domain.ErrInvalidRequest.WithCause(err))
 		return
 	}
-	book, err := h.bookUseCase.CreateBook(req)
+	book, err := h.bookUseCase.CreateBook(c.Request.Context(), req)
 	if err != nil {
-		status := http.StatusInternalServerError
-		if err.Error() == "book with this ISBN already exists" {
-			status = http.StatusConflict
-		}
-		c.JSON(status, domain.ErrorResponse{Error: "Failed to create book", Message: err.Error()})
+		_ = c.Error(err)
 		return
 	}
 	c.JSON(http.StatusCreated, book)
 }
 
 // GetBook retrieves a single book by id.  If the id is invalid or the
-// book is not found appropriate HTTP statuses are returned.
+// book is not found appropriate HTTP statuses are returned.  An
+// optional fields parameter limits the returned attributes.
 func (h *BookHandler) GetBook(c *gin.Context) {
-	idParam := c.Param("id")
-	id, err := strconv.ParseUint(idParam, 10, 32)
+	id, err := parseBookID(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Bad Request", Message: "Invalid book ID"})
+		_ = c.Error(err)
 		return
 	}
-	book, err := h.bookUseCase.GetBookByID(uint(id))
+	fields, err := parseListParam(c, "fields", domain.BookFields)
 	if err != nil {
-		c.JSON(http.StatusNotFound, domain.ErrorResponse{Error: "Not Found", Message: err.Error()})
+		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, book)
+	book, err := h.bookUseCase.GetBookByID(c.Request.Context(), id)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	body, err := selectFields(book, fields)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, body)
 }
 
 // UpdateBook updates an existing book by id.  Conflicts and not found
 // cases return 409 and 404 respectively.
 func (h *BookHandler) UpdateBook(c *gin.Context) {
-	idParam := c.Param("id")
-	id, err := strconv.ParseUint(idParam, 10, 32)
+	id, err := parseBookID(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Bad Request", Message: "Invalid book ID"})
+		_ = c.Error(err)
 		return
 	}
 	var req domain.UpdateBookRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Bad Request", Message: err.Error()})
+		_ = c.Error(domain.ErrInvalidRequest.WithCause(err))
 		return
 	}
-	book, err := h.bookUseCase.UpdateBook(uint(id), req)
+	book, err := h.bookUseCase.UpdateBook(c.Request.Context(), id, req)
 	if err != nil {
-		status := http.StatusInternalServerError
-		switch err.Error() {
-		case "book not found":
-			status = http.StatusNotFound
-		case "book with this ISBN already exists":
-			status = http.StatusConflict
-		}
-		c.JSON(status, domain.ErrorResponse{Error: "Failed to update book", Message: err.Error()})
+		_ = c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, book)
@@ -87,30 +85,32 @@ func (h *BookHandler) UpdateBook(c *gin.Context) {
 
 // DeleteBook deletes a book.  Not found errors return 404.
 func (h *BookHandler) DeleteBook(c *gin.Context) {
-	idParam := c.Param("id")
-	id, err := strconv.ParseUint(idParam, 10, 32)
+	id, err := parseBookID(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Bad Request", Message: "Invalid book ID"})
+		_ = c.Error(err)
 		return
 	}
-	if err := h.bookUseCase.DeleteBook(uint(id)); err != nil {
-		status := http.StatusInternalServerError
-		if err.Error() == "book not found" {
-			status = http.StatusNotFound
-		}
-		c.JSON(status, domain.ErrorResponse{Error: "Failed to delete book", Message: err.Error()})
+	if err := h.bookUseCase.DeleteBook(c.Request.Context(), id); err != nil {
+		_ = c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, domain.SuccessResponse{Message: "Book deleted successfully"})
 }
 
 // ListBooks lists books with pagination.  Defaults to page=1 and
-// limit=10 when parameters are omitted.  Invalid parameters return a
-// 400 response.
+// limit=10 when parameters are omitted.  Supplying a cursor parameter
+// (empty for the first page) switches to keyset pagination and fields
+// limits the attributes of each book.  Invalid parameters return a 400
+// response.
 func (h *BookHandler) ListBooks(c *gin.Context) {
 	var pagination domain.PaginationRequest
 	if err := c.ShouldBindQuery(&pagination); err != nil {
-		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Bad Request", Message: err.Error()})
+		_ = c.Error(domain.ErrInvalidRequest.WithCause(err))
+		return
+	}
+	fields, err := parseListParam(c, "fields", domain.BookFields)
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
 	if pagination.Page == 0 {
@@ -119,10 +119,34 @@ func (h *BookHandler) ListBooks(c *gin.Context) {
 	if pagination.Limit == 0 {
 		pagination.Limit = 10
 	}
-	result, err := h.bookUseCase.ListBooks(pagination.Page, pagination.Limit)
+	if _, ok := c.GetQuery("cursor"); ok {
+		result, err := h.bookUseCase.ListBooksByCursor(c.Request.Context(), pagination.Cursor, pagination.Limit)
+		if err == nil {
+			result.Data, err = selectFields(result.Data, fields)
+		}
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, result)
+		return
+	}
+	result, err := h.bookUseCase.ListBooks(c.Request.Context(), pagination.Page, pagination.Limit)
+	if err == nil {
+		result.Data, err = selectFields(result.Data, fields)
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{Error: "Failed to retrieve books", Message: err.Error()})
+		_ = c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, result)
 }
+
+// parseBookID reads the :id path parameter.
+func parseBookID(c *gin.Context) (uint, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return 0, domain.ErrInvalidBookID
+	}
+	return uint(id), nil
+}
@@ -3,7 +3,10 @@ package handler
 
 import (
 	"book-lending-api/internal/domain"
+	"book-lending-api/internal/i18n"
+	"book-lending-api/internal/middleware"
 	"book-lending-api/internal/usecase"
+	"context"
 	"errors"
 	"net/http"
 	"net/http/httptest"
@@ -19,20 +22,24 @@ type mockBookUseCase struct{}
 
 const notImpl = "not implemented"
 
-func (m *mockBookUseCase) CreateBook(req domain.CreateBookRequest) (*domain.Book, error) {
+func (m *mockBookUseCase) CreateBook(ctx context.Context, req domain.CreateBookRequest) (*domain.Book, error) {
 	return nil, errors.New(notImpl)
 }
-func (m *mockBookUseCase) GetBookByID(id uint) (*domain.Book, error) {
+func (m *mockBookUseCase) GetBookByID(ctx context.Context, id uint) (*domain.Book, error) {
 	if id == 1 {
 		return &domain.Book{ID: 1, Title: "Dune", Author: "Frank Herbert", ISBN: "9780441172719", Quantity: 3, Category: "Sci-Fi"}, nil
 	}
-	return nil, errors.New("book not found")
+	return nil, domain.ErrBookNotFound
 }
-func (m *mockBookUseCase) UpdateBook(id uint, req domain.UpdateBookRequest) (*domain.Book, error) {
+func (m *mockBookUseCase) UpdateBook(ctx context.Context, id uint, req domain.UpdateBookRequest) (*domain.Book, error) {
 	return nil, errors.New(notImpl)
 }
-func (m *mockBookUseCase) DeleteBook(id uint) error { return errors.New(notImpl) }
-func (m *mockBookUseCase) ListBooks(page, limit int) (*domain.PaginatedResponse, error) {
+func (m *mockBookUseCase) DeleteBook(ctx context.Context, id uint) error { return errors.New(notImpl) }
+func (m *mockBookUseCase) ListBooks(ctx context.Context, page, limit int) (*domain.PaginatedResponse, error) {
+	return nil, errors.New(notImpl)
+}
+
+func (m *mockBookUseCase) ListBooksByCursor(ctx context.Context, cursor string, limit int) (*domain.CursorPaginatedResponse, error) {
 	return nil, errors.New(notImpl)
 }
 
@@ -41,8 +48,12 @@ var _ usecase.BookUseCase = (*mockBookUseCase)(nil)
 
 func setupGin() *gin.Engine {
 	gin.SetMode(gin.TestMode)
+	translator, err := i18n.New()
+	if err != nil {
+		panic(err)
+	}
 	r := gin.New()
-	r.Use(gin.Recovery())
+	r.Use(gin.Recovery(), middleware.ErrorHandler(translator))
 	return r
 }
 
@@ -64,6 +75,23 @@ func TestBookHandlerGetBookSuccess(t *testing.T) {
 	}
 }
 
+func TestBookHandlerGetBookNotFound(t *testing.T) {
+	r := setupGin()
+	h := NewBookHandler(&mockBookUseCase{})
+	r.GET("/books/:id", h.GetBook)
+
+	req := httptest.NewRequest(http.MethodGet, "/books/2", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", w.Code)
+	}
+	if body := w.Body.String(); !strings.Contains(body, `"code":"book_not_found"`) {
+		t.Fatalf("expected stable error code in body: %s", body)
+	}
+}
+
 func TestBookHandlerGetBookInvalidID(t *testing.T) {
 	r := setupGin()
 	h := NewBookHandler(&mockBookUseCase{})
@@ -78,6 +106,31 @@ func TestBookHandlerGetBookInvalidID(t *testing.T) {
 	}
 }
 
+func TestBookHandlerGetBookSparseFields(t *testing.T) {
+	r := setupGin()
+	h := NewBookHandler(&mockBookUseCase{})
+	r.GET("/books/:id", h.GetBook)
+
+	req := httptest.NewRequest(http.MethodGet, "/books/1?fields=id,title", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "Dune") || strings.Contains(body, "isbn") {
+		t.Fatalf("unexpected body: %s", body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/books/1?fields=secret", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for unknown field, got %d", w.Code)
+	}
+}
+
 // containsAll is a tiny helper to assert substrings in the response.
 func containsAll(s string, subs []string) bool {
 	for _, sub := range subs {
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"book-lending-api/internal/domain"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseListParam reads a comma separated query parameter and checks
+// every entry against allowed.  It returns nil when the parameter is
+// absent so callers can fall back to their defaults, and an empty
+// slice when it is present but blank.
+func parseListParam(c *gin.Context, name string, allowed []string) ([]string, error) {
+	raw, ok := c.GetQuery(name)
+	if !ok {
+		return nil, nil
+	}
+	values := []string{}
+	for _, v := range strings.Split(raw, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if !slices.Contains(allowed, v) {
+			return nil, domain.ErrInvalidQuery.WithParams(map[string]string{
+				"param": name, "value": v, "allowed": strings.Join(allowed, ","),
+			})
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// selectFields trims a JSON object, or every object in a JSON array, to
+// the given top-level fields.  A nil field list returns v unchanged.
+func selectFields(v interface{}, fields []string) (interface{}, error) {
+	if fields == nil {
+		return v, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil, err
+	}
+	switch val := decoded.(type) {
+	case map[string]interface{}:
+		return pick(val, fields), nil
+	case []interface{}:
+		for i, item := range val {
+			if obj, ok := item.(map[string]interface{}); ok {
+				val[i] = pick(obj, fields)
+			}
+		}
+		return val, nil
+	}
+	return decoded, nil
+}
+
+func pick(obj map[string]interface{}, fields []string) map[string]interface{} {
+	out := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		if v, ok := obj[f]; ok {
+			out[f] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"book-lending-api/internal/health"
+	"book-lending-api/internal/logging"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// healthCacheTTL is how long a probe's report is reused.  The probes
+// are public and not rate limited, so that orchestrators are never
+// throttled; reusing reports keeps a flood of them off the database.
+const healthCacheTTL = time.Second
+
+// HealthHandler exposes the health check registry as probe endpoints.
+// The reports name each check and whether it passed, but not why it
+// failed, since the errors can describe the infrastructure; they are
+// logged instead.
+type HealthHandler struct {
+	live  cachedReport
+	ready cachedReport
+}
+
+// NewHealthHandler constructs a new HealthHandler.
+func NewHealthHandler(registry *health.Registry) *HealthHandler {
+	return &HealthHandler{
+		live:  cachedReport{run: registry.Live},
+		ready: cachedReport{run: registry.Ready},
+	}
+}
+
+// Livez reports whether the process is healthy enough to keep running.
+func (h *HealthHandler) Livez(c *gin.Context) {
+	respondHealth(c, h.live.get(c.Request.Context()))
+}
+
+// Readyz reports whether the service can handle traffic, including
+// its database and schema.
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	respondHealth(c, h.ready.get(c.Request.Context()))
+}
+
+// cachedReport runs a set of checks at most once per healthCacheTTL.
+// Probes arriving while the checks run wait for their report.
+type cachedReport struct {
+	run func(ctx context.Context) health.Report
+
+	mu     sync.Mutex
+	report health.Report
+	at     time.Time
+}
+
+func (r *cachedReport) get(ctx context.Context) health.Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.at.IsZero() && time.Since(r.at) < healthCacheTTL {
+		return r.report
+	}
+	// Other probes may be waiting for this report, so it must not be
+	// cut short by this one going away.  Each check has its own timeout.
+	report := r.run(context.WithoutCancel(ctx))
+	checks := make([]health.Result, len(report.Checks))
+	for i, res := range report.Checks {
+		if res.Error != "" {
+			logging.FromContext(ctx).Warn("health check failed", "check", res.Name, "error", res.Error)
+		}
+		res.Error = ""
+		checks[i] = res
+	}
+	report.Checks = checks
+	r.report, r.at = report, time.Now()
+	return report
+}
+
+func respondHealth(c *gin.Context, report health.Report) {
+	status := http.StatusOK
+	if !report.OK() {
+		status = http.StatusServiceUnavailable
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, report)
+}
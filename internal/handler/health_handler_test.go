@@ -0,0 +1,58 @@
+// Unit tests for HealthHandler probe endpoints.
+package handler
+
+import (
+	"book-lending-api/internal/health"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthHandlerProbes(t *testing.T) {
+	registry := health.NewRegistry(time.Second)
+	var runs atomic.Int32
+	registry.Register("database", health.Readiness, func(context.Context) error {
+		runs.Add(1)
+		return errors.New("dial tcp 10.0.0.5:3306: connection refused")
+	})
+	h := NewHealthHandler(registry)
+	r := setupGin()
+	r.GET("/livez", h.Livez)
+	r.GET("/readyz", h.Readyz)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("livez: expected 200, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("readyz: expected 503, got %d", w.Code)
+	}
+	var report health.Report
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if report.Status != health.StatusFail || len(report.Checks) != 1 || report.Checks[0].Name != "database" {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if strings.Contains(w.Body.String(), "10.0.0.5") {
+		t.Fatalf("expected the check's error to be kept private, got %s", w.Body.String())
+	}
+
+	// A burst of probes runs the checks once.
+	for range 5 {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	}
+	if n := runs.Load(); n != 1 {
+		t.Fatalf("expected the checks to run once, ran %d times", n)
+	}
+}
@@ -22,30 +22,22 @@ func NewLendingHandler(uc usecase.LendingUseCase) *LendingHandler {
 
 // BorrowBook allows an authenticated user to borrow a book.  It
 // returns the created lending record on success.  Validation errors
-// result in 400 responses and conflicts (e.g. already borrowed) in 409.
+// result in 400 responses, an unverified email address in 403 and
+// conflicts (e.g. already borrowed) in 409.
 func (h *LendingHandler) BorrowBook(c *gin.Context) {
 	userID, exists := middleware.GetUserIDFromContext(c)
 	if !exists {
-		c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: "Unauthorized", Message: "User not found in context"})
+		_ = c.Error(domain.ErrUnauthorized)
 		return
 	}
 	var req domain.BorrowBookRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Bad Request", Message: err.Error()})
+		_ = c.Error(domain.ErrInvalidRequest.WithCause(err))
 		return
 	}
-	record, err := h.lendingUseCase.BorrowBook(userID, req.BookID)
+	record, err := h.lendingUseCase.BorrowBook(c.Request.Context(), userID, req.BookID)
 	if err != nil {
-		status := http.StatusInternalServerError
-		switch err.Error() {
-		case "book not found":
-			status = http.StatusNotFound
-		case "you have already borrowed this book",
-			"borrowing limit exceeded: maximum 5 books per week",
-			"book is not available for borrowing":
-			status = http.StatusConflict
-		}
-		c.JSON(status, domain.ErrorResponse{Error: "Failed to borrow book", Message: err.Error()})
+		_ = c.Error(err)
 		return
 	}
 	c.JSON(http.StatusCreated, record)
@@ -57,27 +49,17 @@ func (h *LendingHandler) BorrowBook(c *gin.Context) {
 func (h *LendingHandler) ReturnBook(c *gin.Context) {
 	userID, exists := middleware.GetUserIDFromContext(c)
 	if !exists {
-		c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: "Unauthorized", Message: "User not found in context"})
+		_ = c.Error(domain.ErrUnauthorized)
 		return
 	}
-	idParam := c.Param("id")
-	recordID, err := strconv.ParseUint(idParam, 10, 32)
+	recordID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Bad Request", Message: "Invalid lending record ID"})
+		_ = c.Error(domain.ErrInvalidRecordID)
 		return
 	}
-	record, err := h.lendingUseCase.ReturnBook(userID, uint(recordID))
+	record, err := h.lendingUseCase.ReturnBook(c.Request.Context(), userID, uint(recordID))
 	if err != nil {
-		status := http.StatusInternalServerError
-		switch err.Error() {
-		case "lending record not found":
-			status = http.StatusNotFound
-		case "unauthorized: this lending record does not belong to you":
-			status = http.StatusForbidden
-		case "book has already been returned":
-			status = http.StatusConflict
-		}
-		c.JSON(status, domain.ErrorResponse{Error: "Failed to return book", Message: err.Error()})
+		_ = c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, record)
@@ -85,16 +67,38 @@ func (h *LendingHandler) ReturnBook(c *gin.Context) {
 
 // GetBorrowingHistory returns a paginated list of a user's past borrowing
 // records.  Page and limit parameters are optional and default to
-// page=1 limit=10.
+// page=1 limit=10.  Supplying a cursor parameter (empty for the first
+// page) switches to keyset pagination.  Optional fields and include
+// parameters select record attributes and embedded associations.
 func (h *LendingHandler) GetBorrowingHistory(c *gin.Context) {
 	userID, exists := middleware.GetUserIDFromContext(c)
 	if !exists {
-		c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: "Unauthorized", Message: "User not found in context"})
+		_ = c.Error(domain.ErrUnauthorized)
 		return
 	}
+	respondBorrowingHistory(c, h.lendingUseCase, userID)
+}
+
+// GetActiveBorrowings lists all currently active borrowings for the
+// authenticated user.  An empty slice is returned when there are
+// none.  Accepts the same fields and include parameters as the
+// history endpoint.
+func (h *LendingHandler) GetActiveBorrowings(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		_ = c.Error(domain.ErrUnauthorized)
+		return
+	}
+	respondActiveBorrowings(c, h.lendingUseCase, userID)
+}
+
+// respondBorrowingHistory writes a page of userID's borrowing history
+// as selected by the request's pagination and sparse fieldset
+// parameters.
+func respondBorrowingHistory(c *gin.Context, uc usecase.LendingUseCase, userID uint) {
 	var pagination domain.PaginationRequest
 	if err := c.ShouldBindQuery(&pagination); err != nil {
-		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Bad Request", Message: err.Error()})
+		_ = c.Error(domain.ErrInvalidRequest.WithCause(err))
 		return
 	}
 	if pagination.Page == 0 {
@@ -103,27 +107,63 @@ func (h *LendingHandler) GetBorrowingHistory(c *gin.Context) {
 	if pagination.Limit == 0 {
 		pagination.Limit = 10
 	}
-	result, err := h.lendingUseCase.GetUserBorrowingHistory(userID, pagination.Page, pagination.Limit)
+	fields, include, err := parseSparseParams(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if _, ok := c.GetQuery("cursor"); ok {
+		result, err := uc.GetUserBorrowingHistoryByCursor(c.Request.Context(), userID, pagination.Cursor, pagination.Limit, include)
+		if err == nil {
+			result.Data, err = selectFields(result.Data, fields)
+		}
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, result)
+		return
+	}
+	result, err := uc.GetUserBorrowingHistory(c.Request.Context(), userID, pagination.Page, pagination.Limit, include)
+	if err == nil {
+		result.Data, err = selectFields(result.Data, fields)
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{Error: "Failed to retrieve borrowing history", Message: err.Error()})
+		_ = c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, result)
 }
 
-// GetActiveBorrowings lists all currently active borrowings for the
-// authenticated user.  An empty slice is returned when there are
-// none.
-func (h *LendingHandler) GetActiveBorrowings(c *gin.Context) {
-	userID, exists := middleware.GetUserIDFromContext(c)
-	if !exists {
-		c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: "Unauthorized", Message: "User not found in context"})
+// respondActiveBorrowings writes userID's active borrowings, trimmed by
+// the request's sparse fieldset parameters.
+func respondActiveBorrowings(c *gin.Context, uc usecase.LendingUseCase, userID uint) {
+	fields, include, err := parseSparseParams(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	records, err := uc.GetActiveBorrowings(c.Request.Context(), userID, include)
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
-	records, err := h.lendingUseCase.GetActiveBorrowings(userID)
+	body, err := selectFields(records, fields)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{Error: "Failed to retrieve active borrowings", Message: err.Error()})
+		_ = c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, records)
+	c.JSON(http.StatusOK, body)
+}
+
+// parseSparseParams parses the fields and include query parameters for
+// lending record listings.
+func parseSparseParams(c *gin.Context) (fields, include []string, err error) {
+	if fields, err = parseListParam(c, "fields", domain.LendingRecordFields); err != nil {
+		return nil, nil, err
+	}
+	if include, err = parseListParam(c, "include", domain.LendingRecordIncludes); err != nil {
+		return nil, nil, err
+	}
+	return fields, include, nil
 }
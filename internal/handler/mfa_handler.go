@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/middleware"
+	"book-lending-api/internal/usecase"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MFAHandler lets authenticated users manage two-factor
+// authentication.
+type MFAHandler struct {
+	mfaUseCase usecase.MFAUseCase
+}
+
+// NewMFAHandler constructs a new MFAHandler.
+func NewMFAHandler(mfaUseCase usecase.MFAUseCase) *MFAHandler {
+	return &MFAHandler{mfaUseCase: mfaUseCase}
+}
+
+// Enroll starts two-factor enrolment and returns the new secret with
+// its otpauth URI.  Users who already have it enabled get 409.
+func (h *MFAHandler) Enroll(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		_ = c.Error(domain.ErrUnauthorized)
+		return
+	}
+	enrollment, err := h.mfaUseCase.Enroll(c.Request.Context(), userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// Activate enables two-factor authentication once the user enters a
+// code from their authenticator, and returns their recovery codes.
+// Tokens issued before this do not count as two-factor logins; the
+// user must log in again for that.
+func (h *MFAHandler) Activate(c *gin.Context) {
+	h.withCode(c, func(userID uint, code string) (any, error) {
+		codes, err := h.mfaUseCase.Activate(c.Request.Context(), userID, code)
+		if err != nil {
+			return nil, err
+		}
+		return domain.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+	})
+}
+
+// Disable turns two-factor authentication off.  It needs a current
+// code and returns 403 for roles that must keep it.
+func (h *MFAHandler) Disable(c *gin.Context) {
+	h.withCode(c, func(userID uint, code string) (any, error) {
+		if err := h.mfaUseCase.Disable(c.Request.Context(), userID, code); err != nil {
+			return nil, err
+		}
+		return domain.SuccessResponse{Message: "Two-factor authentication disabled"}, nil
+	})
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes.  It needs
+// a current code.
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	h.withCode(c, func(userID uint, code string) (any, error) {
+		codes, err := h.mfaUseCase.RegenerateRecoveryCodes(c.Request.Context(), userID, code)
+		if err != nil {
+			return nil, err
+		}
+		return domain.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+	})
+}
+
+// withCode binds an MFACodeRequest for the authenticated user, runs fn
+// and renders its result with 200.
+func (h *MFAHandler) withCode(c *gin.Context, fn func(userID uint, code string) (any, error)) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		_ = c.Error(domain.ErrUnauthorized)
+		return
+	}
+	var req domain.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(domain.ErrInvalidRequest.WithCause(err))
+		return
+	}
+	resp, err := fn(userID, req.Code)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
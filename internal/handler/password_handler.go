@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/usecase"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PasswordHandler wires password reset use cases to HTTP requests.
+type PasswordHandler struct {
+	passwordUseCase usecase.PasswordUseCase
+}
+
+// NewPasswordHandler constructs a new PasswordHandler.
+func NewPasswordHandler(passwordUseCase usecase.PasswordUseCase) *PasswordHandler {
+	return &PasswordHandler{passwordUseCase: passwordUseCase}
+}
+
+// ForgotPassword emails a reset token to the given address.  It
+// returns 202 whether or not an account exists for the email.
+func (h *PasswordHandler) ForgotPassword(c *gin.Context) {
+	var req domain.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(domain.ErrInvalidRequest.WithCause(err))
+		return
+	}
+	if err := h.passwordUseCase.ForgotPassword(c.Request.Context(), req.Email); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusAccepted, domain.SuccessResponse{Message: "If an account exists for this email, a password reset link has been sent"})
+}
+
+// ResetPassword sets a new password using a reset token.  Unknown,
+// expired or used tokens return 400.
+func (h *PasswordHandler) ResetPassword(c *gin.Context) {
+	var req domain.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(domain.ErrInvalidRequest.WithCause(err))
+		return
+	}
+	if err := h.passwordUseCase.ResetPassword(c.Request.Context(), req.Token, req.Password); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, domain.SuccessResponse{Message: "Password reset successfully"})
+}
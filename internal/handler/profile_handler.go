@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/middleware"
+	"book-lending-api/internal/usecase"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProfileHandler wires the authenticated user's account operations to
+// HTTP requests.
+type ProfileHandler struct {
+	profileUseCase usecase.ProfileUseCase
+}
+
+// NewProfileHandler constructs a new ProfileHandler.
+func NewProfileHandler(profileUseCase usecase.ProfileUseCase) *ProfileHandler {
+	return &ProfileHandler{profileUseCase: profileUseCase}
+}
+
+// GetProfile returns the authenticated user.
+func (h *ProfileHandler) GetProfile(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		_ = c.Error(domain.ErrUnauthorized)
+		return
+	}
+	user, err := h.profileUseCase.GetProfile(c.Request.Context(), userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateProfile changes the name, phone or preferences of the
+// authenticated user and returns the updated user.
+func (h *ProfileHandler) UpdateProfile(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		_ = c.Error(domain.ErrUnauthorized)
+		return
+	}
+	var req domain.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(domain.ErrInvalidRequest.WithCause(err))
+		return
+	}
+	user, err := h.profileUseCase.UpdateProfile(c.Request.Context(), userID, req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// ChangePassword sets a new password for the authenticated user and
+// logs out of their other sessions.  A wrong current password returns
+// 403.
+func (h *ProfileHandler) ChangePassword(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		_ = c.Error(domain.ErrUnauthorized)
+		return
+	}
+	var req domain.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(domain.ErrInvalidRequest.WithCause(err))
+		return
+	}
+	sessionID, _ := middleware.GetSessionIDFromContext(c)
+	if err := h.profileUseCase.ChangePassword(c.Request.Context(), userID, sessionID, req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, domain.SuccessResponse{Message: "Password changed successfully"})
+}
+
+// ChangeEmail moves the authenticated user to a new email address,
+// which must then be verified, logs out of their other sessions and
+// returns the updated user.
+func (h *ProfileHandler) ChangeEmail(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		_ = c.Error(domain.ErrUnauthorized)
+		return
+	}
+	var req domain.ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(domain.ErrInvalidRequest.WithCause(err))
+		return
+	}
+	sessionID, _ := middleware.GetSessionIDFromContext(c)
+	user, err := h.profileUseCase.ChangeEmail(c.Request.Context(), userID, sessionID, req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// DeleteAccount deletes the authenticated user.  Users with books
+// still on loan get 409.
+func (h *ProfileHandler) DeleteAccount(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		_ = c.Error(domain.ErrUnauthorized)
+		return
+	}
+	var req domain.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(domain.ErrInvalidRequest.WithCause(err))
+		return
+	}
+	if err := h.profileUseCase.DeleteAccount(c.Request.Context(), userID, req.Password); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, domain.SuccessResponse{Message: "Account deleted successfully"})
+}
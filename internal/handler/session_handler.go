@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/middleware"
+	"book-lending-api/internal/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionHandler wires the authenticated user's login sessions to HTTP
+// requests.
+type SessionHandler struct {
+	sessionUseCase usecase.SessionUseCase
+}
+
+// NewSessionHandler constructs a new SessionHandler.
+func NewSessionHandler(sessionUseCase usecase.SessionUseCase) *SessionHandler {
+	return &SessionHandler{sessionUseCase: sessionUseCase}
+}
+
+// ListSessions returns the devices the authenticated user is logged in
+// on, marking the one making the request.
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		_ = c.Error(domain.ErrUnauthorized)
+		return
+	}
+	currentID, _ := middleware.GetSessionIDFromContext(c)
+	sessions, err := h.sessionUseCase.List(c.Request.Context(), userID, currentID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession logs the authenticated user out of the session named
+// by the :id path parameter.  Revoking the current session logs out of
+// it.
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		_ = c.Error(domain.ErrUnauthorized)
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		_ = c.Error(domain.ErrInvalidSessionID)
+		return
+	}
+	if err := h.sessionUseCase.Revoke(c.Request.Context(), userID, uint(id)); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, domain.SuccessResponse{Message: "Session revoked successfully"})
+}
+
+// RevokeOtherSessions logs the authenticated user out everywhere except
+// the session making the request.
+func (h *SessionHandler) RevokeOtherSessions(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		_ = c.Error(domain.ErrUnauthorized)
+		return
+	}
+	currentID, exists := middleware.GetSessionIDFromContext(c)
+	if !exists {
+		_ = c.Error(domain.ErrUnauthorized)
+		return
+	}
+	n, err := h.sessionUseCase.RevokeOthers(c.Request.Context(), userID, currentID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, domain.SuccessResponse{Message: "Other sessions revoked successfully", Data: gin.H{"revoked": n}})
+}
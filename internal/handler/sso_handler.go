@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/usecase"
+	"book-lending-api/pkg"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ssoStateCookie holds the state of the sign-in a browser started, so
+// that the callback only completes sign-ins started by the same
+// browser.  Without it an attacker could send a victim to the callback
+// with the attacker's own state and code and sign them in as the
+// attacker.
+const ssoStateCookie = "sso_state"
+
+// SSOHandler wires single sign-on to HTTP requests.
+type SSOHandler struct {
+	ssoUseCase     usecase.SSOUseCase
+	sessionUseCase usecase.SessionUseCase
+	jwtUtil        *pkg.JWTUtil
+	challengeTTL   time.Duration
+	loginTTL       time.Duration
+	cookiePath     string
+	secureCookie   bool
+}
+
+// NewSSOHandler constructs a new SSOHandler.  Users with two-factor
+// authentication have challengeTTL to enter their code after signing
+// in.  The state cookie lasts loginTTL and is scoped to redirectURL,
+// the callback the provider sends users back to, and only sent over
+// HTTPS if the callback uses it.
+func NewSSOHandler(ssoUseCase usecase.SSOUseCase, sessionUseCase usecase.SessionUseCase, jwtUtil *pkg.JWTUtil, challengeTTL, loginTTL time.Duration, redirectURL string) *SSOHandler {
+	h := &SSOHandler{
+		ssoUseCase:     ssoUseCase,
+		sessionUseCase: sessionUseCase,
+		jwtUtil:        jwtUtil,
+		challengeTTL:   challengeTTL,
+		loginTTL:       loginTTL,
+		cookiePath:     "/",
+	}
+	if u, err := url.Parse(redirectURL); err == nil {
+		if u.Path != "" {
+			h.cookiePath = u.Path
+		}
+		h.secureCookie = u.Scheme == "https"
+	}
+	return h
+}
+
+// Login redirects the user to the identity provider to sign in, and
+// keeps the sign-in's state in a cookie for Callback to check.
+func (h *SSOHandler) Login(c *gin.Context) {
+	authURL, state, err := h.ssoUseCase.Begin(c.Request.Context())
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	h.setStateCookie(c, state, int(h.loginTTL.Seconds()))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback completes a sign-in when the identity provider sends the
+// user back.  It responds like Login with a password: an access token,
+// or a challenge token for users with two-factor authentication.
+// Sign-ins started by another browser are refused.
+func (h *SSOHandler) Callback(c *gin.Context) {
+	if reason := c.Query("error"); reason != "" {
+		_ = c.Error(domain.ErrSSOFailed.WithCause(fmt.Errorf("provider returned %s: %s", reason, c.Query("error_description"))))
+		return
+	}
+	state, code := c.Query("state"), c.Query("code")
+	if state == "" || code == "" {
+		_ = c.Error(domain.ErrInvalidSSOState)
+		return
+	}
+	cookie, err := c.Cookie(ssoStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie), []byte(state)) != 1 {
+		_ = c.Error(domain.ErrInvalidSSOState)
+		return
+	}
+	h.setStateCookie(c, "", -1)
+	result, err := h.ssoUseCase.Complete(c.Request.Context(), state, code)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	respondLogin(c, h.sessionUseCase, h.jwtUtil, h.challengeTTL, result)
+}
+
+// setStateCookie sets the state cookie, or deletes it when maxAge is
+// negative.  SameSite=Lax lets it through on the provider's redirect
+// back, which is a top-level navigation.
+func (h *SSOHandler) setStateCookie(c *gin.Context, state string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(ssoStateCookie, state, maxAge, h.cookiePath, "", h.secureCookie, true)
+}
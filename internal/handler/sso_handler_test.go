@@ -0,0 +1,70 @@
+// Unit tests for SSOHandler's login state cookie.
+package handler
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/pkg"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubSSO begins sign-ins with a fixed state and records completions.
+type stubSSO struct{ completed []string }
+
+func (s *stubSSO) Begin(ctx context.Context) (string, string, error) {
+	return "https://idp.example.com/authorize?state=s1", "s1", nil
+}
+
+func (s *stubSSO) Complete(ctx context.Context, state, code string) (*domain.LoginResult, error) {
+	s.completed = append(s.completed, state)
+	return nil, domain.ErrSSOFailed
+}
+
+func TestSSOHandlerRequiresStateCookie(t *testing.T) {
+	sso := &stubSSO{}
+	h := NewSSOHandler(sso, nil, pkg.NewJWTUtil("test"), time.Minute, 10*time.Minute, "https://api.example.com/api/v1/auth/oidc/callback")
+	r := setupGin()
+	r.GET("/api/v1/auth/oidc/login", h.Login)
+	r.GET("/api/v1/auth/oidc/callback", h.Callback)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/login", nil))
+	cookies := w.Result().Cookies()
+	if w.Code != http.StatusFound || len(cookies) != 1 {
+		t.Fatalf("expected a redirect setting the state cookie, got %d %v", w.Code, cookies)
+	}
+	cookie := cookies[0]
+	if cookie.Name != ssoStateCookie || cookie.Value != "s1" || !cookie.HttpOnly || !cookie.Secure ||
+		cookie.SameSite != http.SameSiteLaxMode || cookie.Path != "/api/v1/auth/oidc/callback" || cookie.MaxAge != 600 {
+		t.Fatalf("unexpected state cookie %+v", cookie)
+	}
+
+	for _, tc := range []struct {
+		name, cookie string
+		want         int
+	}{
+		{"no cookie", "", http.StatusBadRequest},
+		{"another browser's state", "s2", http.StatusBadRequest},
+		{"same browser", "s1", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/callback?state=s1&code=c", nil)
+		if tc.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: ssoStateCookie, Value: tc.cookie})
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d %s", tc.name, tc.want, w.Code, w.Body.String())
+		}
+		if tc.want == http.StatusBadRequest && !strings.Contains(w.Body.String(), "invalid_sso_state") {
+			t.Errorf("%s: expected invalid_sso_state, got %s", tc.name, w.Body.String())
+		}
+	}
+	if len(sso.completed) != 1 {
+		t.Fatalf("expected only the same browser's sign-in to be completed, got %v", sso.completed)
+	}
+}
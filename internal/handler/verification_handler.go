@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/middleware"
+	"book-lending-api/internal/usecase"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VerificationHandler wires email verification use cases to HTTP
+// requests.
+type VerificationHandler struct {
+	verificationUseCase usecase.VerificationUseCase
+}
+
+// NewVerificationHandler constructs a new VerificationHandler.
+func NewVerificationHandler(verificationUseCase usecase.VerificationUseCase) *VerificationHandler {
+	return &VerificationHandler{verificationUseCase: verificationUseCase}
+}
+
+// VerifyEmail confirms an email address using the token query
+// parameter from a verification email and returns the updated user.
+// Unknown, expired or used tokens return 400.
+func (h *VerificationHandler) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		_ = c.Error(domain.ErrInvalidVerifyToken)
+		return
+	}
+	user, err := h.verificationUseCase.VerifyEmail(c.Request.Context(), token)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// ResendVerification sends the authenticated user a new verification
+// email.  Users who are already verified get 409.
+func (h *VerificationHandler) ResendVerification(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		_ = c.Error(domain.ErrUnauthorized)
+		return
+	}
+	if err := h.verificationUseCase.ResendVerification(c.Request.Context(), userID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusAccepted, domain.SuccessResponse{Message: "Verification email sent"})
+}
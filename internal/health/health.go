@@ -0,0 +1,189 @@
+// Package health keeps a registry of liveness and readiness checks and
+// runs them on demand for the /livez and /readyz probes.
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Status values reported for the whole registry and for each check.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Kind says which probes a check takes part in.
+type Kind int
+
+const (
+	// Readiness checks only affect /readyz.  Use them for dependencies
+	// that may recover on their own, such as the database.
+	Readiness Kind = iota
+	// Liveness checks affect both /livez and /readyz.  Use them for
+	// failures only a restart can fix, such as a crashed worker.
+	Liveness
+)
+
+// CheckFunc reports a problem by returning an error.  It must honour
+// ctx, which carries the registry's per-check timeout.
+type CheckFunc func(ctx context.Context) error
+
+// Result is the outcome of a single check.
+type Result struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Report is the outcome of running a set of checks.
+type Report struct {
+	Status    string    `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
+	Checks    []Result  `json:"checks"`
+}
+
+// OK reports whether every check passed.
+func (r Report) OK() bool { return r.Status == StatusOK }
+
+type check struct {
+	name string
+	kind Kind
+	fn   CheckFunc
+}
+
+// Registry holds the registered checks.  It is safe for concurrent use.
+type Registry struct {
+	mu      sync.RWMutex
+	checks  []check
+	timeout time.Duration
+}
+
+// NewRegistry returns an empty registry that gives each check at most
+// timeout to complete.
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{timeout: timeout}
+}
+
+// Register adds a named check of the given kind.
+func (r *Registry) Register(name string, kind Kind, fn CheckFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, check{name: name, kind: kind, fn: fn})
+}
+
+// Live runs the liveness checks.
+func (r *Registry) Live(ctx context.Context) Report {
+	return r.run(ctx, func(c check) bool { return c.kind == Liveness })
+}
+
+// Ready runs every check.
+func (r *Registry) Ready(ctx context.Context) Report {
+	return r.run(ctx, func(check) bool { return true })
+}
+
+func (r *Registry) run(ctx context.Context, want func(check) bool) Report {
+	r.mu.RLock()
+	var selected []check
+	for _, c := range r.checks {
+		if want(c) {
+			selected = append(selected, c)
+		}
+	}
+	r.mu.RUnlock()
+
+	results := make([]Result, len(selected))
+	var wg sync.WaitGroup
+	for i, c := range selected {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = r.runOne(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Timestamp: time.Now(), Checks: results}
+	for _, res := range results {
+		if res.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+func (r *Registry) runOne(ctx context.Context, c check) Result {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() { errCh <- c.fn(ctx) }()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		// A check that ignores ctx must not hold up the probe.
+		err = fmt.Errorf("timed out after %s", r.timeout)
+	}
+	res := Result{Name: c.name, Status: StatusOK, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// Pinger is implemented by *sql.DB.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Ping returns a check that pings a database.
+func Ping(db Pinger) CheckFunc {
+	return db.PingContext
+}
+
+// PendingCounter is implemented by *migrate.Migrator.
+type PendingCounter interface {
+	Pending() (int, error)
+}
+
+// Migrations returns a check that fails while migrations are pending.
+func Migrations(m PendingCounter) CheckFunc {
+	return func(context.Context) error {
+		n, err := m.Pending()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return fmt.Errorf("%d pending migration(s)", n)
+		}
+		return nil
+	}
+}
+
+// Worker tracks whether a background job is still running so that it
+// can be registered as a liveness check.
+type Worker struct {
+	running atomic.Bool
+}
+
+// Run calls job and marks the worker as running until it returns.
+func (w *Worker) Run(ctx context.Context, job func(context.Context)) {
+	w.running.Store(true)
+	defer w.running.Store(false)
+	job(ctx)
+}
+
+// Check fails when the worker is not running.
+func (w *Worker) Check(context.Context) error {
+	if !w.running.Load() {
+		return fmt.Errorf("not running")
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// Unit tests for the health check registry
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type pendingStub int
+
+func (p pendingStub) Pending() (int, error) { return int(p), nil }
+
+func TestRegistryLiveAndReady(t *testing.T) {
+	r := NewRegistry(time.Second)
+	r.Register("database", Readiness, func(context.Context) error { return errors.New("connection refused") })
+	r.Register("migrations", Readiness, Migrations(pendingStub(0)))
+	r.Register("worker", Liveness, func(context.Context) error { return nil })
+
+	live := r.Live(context.Background())
+	if !live.OK() || len(live.Checks) != 1 || live.Checks[0].Name != "worker" {
+		t.Fatalf("unexpected liveness report: %+v", live)
+	}
+
+	ready := r.Ready(context.Background())
+	if ready.OK() || len(ready.Checks) != 3 {
+		t.Fatalf("unexpected readiness report: %+v", ready)
+	}
+	if ready.Checks[0].Status != StatusFail || ready.Checks[0].Error != "connection refused" {
+		t.Errorf("expected database failure, got %+v", ready.Checks[0])
+	}
+	if ready.Checks[1].Status != StatusOK {
+		t.Errorf("expected migrations ok, got %+v", ready.Checks[1])
+	}
+}
+
+func TestRegistryTimesOutSlowChecks(t *testing.T) {
+	r := NewRegistry(10 * time.Millisecond)
+	block := make(chan struct{})
+	defer close(block)
+	r.Register("slow", Readiness, func(context.Context) error { <-block; return nil })
+
+	report := r.Ready(context.Background())
+	if report.OK() || report.Checks[0].Error == "" {
+		t.Fatalf("expected timeout failure, got %+v", report)
+	}
+}
+
+func TestMigrationsPending(t *testing.T) {
+	if err := Migrations(pendingStub(2))(context.Background()); err == nil {
+		t.Fatal("expected pending migrations to fail the check")
+	}
+}
+
+func TestWorker(t *testing.T) {
+	var w Worker
+	if w.Check(context.Background()) == nil {
+		t.Fatal("worker should not be running before Run")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx, func(ctx context.Context) { close(started); <-ctx.Done() })
+		close(done)
+	}()
+	<-started
+	if err := w.Check(context.Background()); err != nil {
+		t.Fatalf("running worker failed check: %v", err)
+	}
+	cancel()
+	<-done
+	if w.Check(context.Background()) == nil {
+		t.Fatal("stopped worker should fail the check")
+	}
+}
@@ -0,0 +1,100 @@
+// Package i18n translates user‑facing messages.  Message catalogs are
+// JSON files embedded from the locales directory, one per language,
+// keyed by domain error code or "validation.<rule>".
+package i18n
+
+import (
+	"book-lending-api/internal/domain"
+	"embed"
+	"encoding/json"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Fallback is the language used when the client's preferences cannot
+// be satisfied or a key is missing from the selected catalog.
+const Fallback = "en"
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// aliases maps legacy or equivalent language tags onto catalog names.
+var aliases = map[string]string{"in": "id"}
+
+// Translator looks up messages in the embedded catalogs.
+type Translator struct {
+	catalogs map[string]map[string]string
+}
+
+// New loads every embedded catalog.
+func New() (*Translator, error) {
+	entries, err := locales.ReadDir("locales")
+	if err != nil {
+		return nil, err
+	}
+	t := &Translator{catalogs: make(map[string]map[string]string)}
+	for _, entry := range entries {
+		raw, err := locales.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		catalog := make(map[string]string)
+		if err := json.Unmarshal(raw, &catalog); err != nil {
+			return nil, err
+		}
+		t.catalogs[strings.TrimSuffix(entry.Name(), ".json")] = catalog
+	}
+	return t, nil
+}
+
+// Match picks the best supported language for an Accept-Language
+// header value, honouring q weights.  It returns Fallback when nothing
+// matches.
+func (t *Translator) Match(acceptLanguage string) string {
+	type pref struct {
+		lang string
+		q    float64
+	}
+	var prefs []pref
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		prefs = append(prefs, pref{lang: tag, q: q})
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+	for _, p := range prefs {
+		if p.q <= 0 {
+			continue
+		}
+		base, _, _ := strings.Cut(strings.ToLower(p.lang), "-")
+		if alias, ok := aliases[base]; ok {
+			base = alias
+		}
+		if _, ok := t.catalogs[base]; ok {
+			return base
+		}
+	}
+	return Fallback
+}
+
+// Translate returns the message for key in lang with {name}
+// placeholders filled from params.  Missing keys fall back to English;
+// ok is false when neither catalog has the key.
+func (t *Translator) Translate(lang, key string, params map[string]string) (string, bool) {
+	for _, l := range []string{lang, Fallback} {
+		if msg, ok := t.catalogs[l][key]; ok {
+			return domain.Interpolate(msg, params), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,39 @@
+// Unit tests for the message catalogs.
+package i18n
+
+import "testing"
+
+func TestCatalogsShareKeys(t *testing.T) {
+	tr, err := New()
+	if err != nil {
+		t.Fatalf("failed to load catalogs: %v", err)
+	}
+	for lang, catalog := range tr.catalogs {
+		for key := range tr.catalogs[Fallback] {
+			if _, ok := catalog[key]; !ok {
+				t.Errorf("catalog %q is missing key %q", lang, key)
+			}
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tr, err := New()
+	if err != nil {
+		t.Fatalf("failed to load catalogs: %v", err)
+	}
+	cases := map[string]string{
+		"":                    "en",
+		"id-ID,id;q=0.9":      "id",
+		"in":                  "id",
+		"de, en-US;q=0.8":     "en",
+		"en;q=0.2, id;q=0.7":  "id",
+		"id;q=0, en-GB;q=0.1": "en",
+		"fr-CA, fr;q=0.9, *":  "en",
+	}
+	for header, want := range cases {
+		if got := tr.Match(header); got != want {
+			t.Errorf("Match(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
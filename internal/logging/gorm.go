@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// GormLogger sends GORM's logs to the request logger found in the
+// statement context.  Failed statements are logged at error level,
+// statements slower than SlowThreshold at warn and everything else at
+// debug.  Missing records are an expected outcome and not logged as
+// errors.
+type GormLogger struct {
+	SlowThreshold time.Duration
+}
+
+var _ gormlogger.Interface = GormLogger{}
+
+// LogMode implements gormlogger.Interface.  Levels are controlled by
+// the slog handler instead.
+func (l GormLogger) LogMode(gormlogger.LogLevel) gormlogger.Interface { return l }
+
+func (l GormLogger) Info(ctx context.Context, msg string, args ...any) {
+	FromContext(ctx).InfoContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (l GormLogger) Warn(ctx context.Context, msg string, args ...any) {
+	FromContext(ctx).WarnContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (l GormLogger) Error(ctx context.Context, msg string, args ...any) {
+	FromContext(ctx).ErrorContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+// Trace implements gormlogger.Interface.
+func (l GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	logger := FromContext(ctx)
+	level := slog.LevelDebug
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		level = slog.LevelError
+	case l.SlowThreshold > 0 && elapsed > l.SlowThreshold:
+		level = slog.LevelWarn
+	}
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	sql, rows := fc()
+	attrs := []slog.Attr{
+		slog.String("sql", sql),
+		slog.Int64("rows", rows),
+		slog.Float64("elapsed_ms", float64(elapsed.Microseconds())/1000),
+	}
+	if level == slog.LevelError {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	logger.LogAttrs(ctx, level, "query", attrs...)
+}
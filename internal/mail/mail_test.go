@@ -0,0 +1,183 @@
+// Unit tests for the mail package.
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"book-lending-api/internal/config"
+)
+
+var testMessage = Message{To: "alice@example.com", Subject: "Hello", Body: "line one\nline two"}
+
+func TestWriterMailer(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewWriterMailer("Library <library@example.com>", &buf)
+	m.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+	if err := m.Send(context.Background(), testMessage); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"From: Library <library@example.com>\r\n",
+		"To: alice@example.com\r\n",
+		"Subject: Hello\r\n",
+		"Date: Tue, 02 Jan 2024 03:04:05 +0000\r\n",
+		"Content-Type: text/plain; charset=UTF-8\r\n",
+		"\r\n\r\nline one\r\nline two\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestMailerRejectsHeaderInjection(t *testing.T) {
+	m := NewWriterMailer("library@example.com", &bytes.Buffer{})
+	msg := testMessage
+	msg.Subject = "Hello\r\nBcc: mallory@example.com"
+	if err := m.Send(context.Background(), msg); !errors.Is(err, errHeaderInjection) {
+		t.Fatalf("expected header injection to be refused, got %v", err)
+	}
+}
+
+func TestFileMailerAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.log")
+	m := NewFileMailer("library@example.com", path)
+	for i := 0; i < 2; i++ {
+		if err := m.Send(context.Background(), testMessage); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "To: alice@example.com"); n != 2 {
+		t.Fatalf("expected 2 messages, found %d", n)
+	}
+}
+
+// fakeSMTP accepts one SMTP session on a local port and records the
+// envelope and message data it receives.
+type fakeSMTP struct {
+	addr     *net.TCPAddr
+	from, to string
+	data     string
+	done     chan struct{}
+}
+
+func startFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	s := &fakeSMTP{addr: ln.Addr().(*net.TCPAddr), done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.TrimRight(line, "\r\n")
+			switch verb := strings.ToUpper(strings.Fields(cmd + " ")[0]); verb {
+			case "EHLO", "HELO":
+				reply("250 localhost")
+			case "MAIL":
+				s.from = cmd
+				reply("250 OK")
+			case "RCPT":
+				s.to = cmd
+				reply("250 OK")
+			case "DATA":
+				reply("354 Go ahead")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				s.data = data.String()
+				reply("250 OK")
+			case "QUIT":
+				reply("221 Bye")
+				return
+			default:
+				reply("502 Unknown command")
+			}
+		}
+	}()
+	return s
+}
+
+func TestSMTPMailer(t *testing.T) {
+	s := startFakeSMTP(t)
+	m := NewSMTPMailer("Library <library@example.com>", config.SMTPConfig{Host: "127.0.0.1", Port: s.addr.Port})
+	if err := m.Send(context.Background(), testMessage); err != nil {
+		t.Fatal(err)
+	}
+	<-s.done
+	if s.from != "MAIL FROM:<library@example.com>" || s.to != "RCPT TO:<alice@example.com>" {
+		t.Fatalf("unexpected envelope %q %q", s.from, s.to)
+	}
+	if !strings.Contains(s.data, "Subject: Hello\r\n") || !strings.Contains(s.data, "line two\r\n") {
+		t.Fatalf("unexpected data:\n%s", s.data)
+	}
+}
+
+func TestSMTPMailerHonoursContext(t *testing.T) {
+	// A listener that never answers stands in for a hung relay.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	m := NewSMTPMailer("library@example.com", config.SMTPConfig{Host: "127.0.0.1", Port: port})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := m.Send(ctx, testMessage); err == nil {
+		t.Fatal("expected an error from an unresponsive server")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("send took %v despite the deadline", elapsed)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, driver := range []string{config.MailSMTP, config.MailFile, config.MailStdout} {
+		cfg := config.MailConfig{Driver: driver, From: "library@example.com", File: "mail.log", SMTP: config.SMTPConfig{Host: "localhost", Port: 25}}
+		if _, err := New(cfg); err != nil {
+			t.Errorf("%s: %v", driver, err)
+		}
+	}
+	if _, err := New(config.MailConfig{Driver: "pigeon"}); err == nil {
+		t.Error("expected an unknown driver to be rejected")
+	}
+}
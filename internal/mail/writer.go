@@ -0,0 +1,72 @@
+package mail
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// separator follows each message written by a WriterMailer so that
+// consecutive messages can be told apart.
+const separator = "\r\n----\r\n\r\n"
+
+// WriterMailer writes formatted messages to an io.Writer instead of
+// sending them.
+type WriterMailer struct {
+	from string
+	mu   sync.Mutex
+	w    io.Writer
+	now  func() time.Time
+}
+
+// NewWriterMailer returns a mailer that writes messages to w.
+func NewWriterMailer(from string, w io.Writer) *WriterMailer {
+	return &WriterMailer{from: from, w: w, now: time.Now}
+}
+
+// Send writes msg to the underlying writer.
+func (m *WriterMailer) Send(_ context.Context, msg Message) error {
+	data, err := format(m.from, msg, m.now())
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err = m.w.Write(append(data, separator...))
+	return err
+}
+
+// FileMailer appends messages to a file, opening it for each message
+// so that the file can be rotated or removed while the server runs.
+type FileMailer struct {
+	from string
+	path string
+	mu   sync.Mutex
+	now  func() time.Time
+}
+
+// NewFileMailer returns a mailer that appends messages to path.
+func NewFileMailer(from, path string) *FileMailer {
+	return &FileMailer{from: from, path: path, now: time.Now}
+}
+
+// Send appends msg to the file.
+func (m *FileMailer) Send(_ context.Context, msg Message) error {
+	data, err := format(m.from, msg, m.now())
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := os.OpenFile(m.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, separator...)); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
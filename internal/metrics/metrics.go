@@ -0,0 +1,149 @@
+// Package metrics exposes Prometheus metrics for HTTP traffic, the
+// database pool and lending activity.
+package metrics
+
+import (
+	"book-lending-api/internal/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "book_lending"
+
+// unmatchedRoute labels requests that did not match any route, so that
+// arbitrary paths cannot blow up label cardinality.
+const unmatchedRoute = "unmatched"
+
+// Metrics owns a Prometheus registry and the collectors registered in
+// it.
+type Metrics struct {
+	registry     *prometheus.Registry
+	requests     *prometheus.CounterVec
+	duration     *prometheus.HistogramVec
+	rateLimited  *prometheus.CounterVec
+	borrows      prometheus.Counter
+	returns      *prometheus.CounterVec
+	failedLogins prometheus.Counter
+}
+
+// New creates the metrics and registers them, along with the standard
+// Go runtime and process collectors.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route template and status code.",
+		}, []string{"method", "route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method, route template and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rate_limit_rejections_total",
+			Help:      "Requests rejected by the rate limiter, by route template.",
+		}, []string{"route"}),
+		borrows: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "borrows_total",
+			Help:      "Books borrowed.",
+		}),
+		returns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "returns_total",
+			Help:      "Books returned, split by whether the loan was overdue.",
+		}, []string{"overdue"}),
+		failedLogins: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "failed_logins_total",
+			Help:      "Login attempts rejected for invalid credentials.",
+		}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests, m.duration, m.rateLimited, m.borrows, m.returns, m.failedLogins,
+	)
+	return m
+}
+
+// Handler serves the registry in the Prometheus exposition format.  A
+// collector that fails, such as the overdue loan query while the
+// database is down, is left out rather than failing the whole scrape.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
+		Registry:      m.registry,
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+}
+
+// RegisterDB exports connection pool statistics for db.
+func (m *Metrics) RegisterDB(db *sql.DB, name string) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterOverdueLoans exports the number of overdue loans, computed by
+// count at scrape time.
+func (m *Metrics) RegisterOverdueLoans(count func(context.Context) (int64, error)) {
+	m.registry.MustRegister(&gaugeFuncCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "overdue_loans"),
+			"Open loans older than the loan period.", nil, nil),
+		value: count,
+	})
+}
+
+// Middleware records request counts and latency.  It must run before
+// ErrorHandler so that it observes the final status code.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		m.requests.WithLabelValues(c.Request.Method, route, status).Inc()
+		m.duration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+		for _, err := range c.Errors {
+			if errors.Is(err.Err, domain.ErrRateLimited) {
+				m.rateLimited.WithLabelValues(route).Inc()
+				break
+			}
+		}
+	}
+}
+
+// gaugeFuncCollector is a gauge whose value is computed on every
+// scrape.  Unlike prometheus.GaugeFunc it reports errors to the
+// scraper instead of exporting a misleading value.
+type gaugeFuncCollector struct {
+	desc  *prometheus.Desc
+	value func(context.Context) (int64, error)
+}
+
+func (g *gaugeFuncCollector) Describe(ch chan<- *prometheus.Desc) { ch <- g.desc }
+
+func (g *gaugeFuncCollector) Collect(ch chan<- prometheus.Metric) {
+	v, err := g.value(context.Background())
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(g.desc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, float64(v))
+}
@@ -0,0 +1,109 @@
+// Unit tests for the Prometheus metrics middleware and decorators
+package metrics
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/usecase"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddlewareLabelsByRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := New()
+	r := gin.New()
+	r.Use(m.Middleware())
+	r.GET("/books/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/limited", func(c *gin.Context) {
+		_ = c.Error(domain.ErrRateLimited)
+		c.AbortWithStatus(http.StatusTooManyRequests)
+	})
+
+	for _, path := range []string{"/books/1", "/books/2", "/limited", "/nope"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("GET", "/books/:id", "200")); got != 2 {
+		t.Errorf("expected 2 book requests, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("GET", unmatchedRoute, "404")); got != 1 {
+		t.Errorf("expected 1 unmatched request, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.rateLimited.WithLabelValues("/limited")); got != 1 {
+		t.Errorf("expected 1 rate limit rejection, got %v", got)
+	}
+}
+
+type stubLending struct{ usecase.LendingUseCase }
+
+func (stubLending) BorrowBook(ctx context.Context, userID, bookID uint) (*domain.LendingRecord, error) {
+	if bookID == 0 {
+		return nil, domain.ErrBookNotFound
+	}
+	return &domain.LendingRecord{ID: 1}, nil
+}
+
+func (stubLending) ReturnBook(ctx context.Context, userID, recordID uint) (*domain.LendingRecord, error) {
+	now := time.Now()
+	return &domain.LendingRecord{ID: recordID, BorrowDate: now.AddDate(0, 0, -int(recordID)), ReturnDate: &now}, nil
+}
+
+type stubAuth struct{ usecase.AuthUseCase }
+
+func (stubAuth) Login(ctx context.Context, req domain.LoginRequest) (*domain.LoginResult, error) {
+	return nil, domain.ErrInvalidCredentials
+}
+
+func (stubAuth) LoginMFA(ctx context.Context, userID uint, code, ip string) (*domain.User, error) {
+	return nil, domain.ErrInvalidMFACode
+}
+
+func TestInstrumentedUseCases(t *testing.T) {
+	m := New()
+	lending := m.InstrumentLending(stubLending{}, domain.DefaultLoanPolicy)
+	_, _ = lending.BorrowBook(context.Background(), 1, 1)
+	_, _ = lending.BorrowBook(context.Background(), 1, 0)
+	_, _ = lending.ReturnBook(context.Background(), 1, 3)
+	_, _ = lending.ReturnBook(context.Background(), 1, 30)
+	_, _ = m.InstrumentAuth(stubAuth{}).Login(context.Background(), domain.LoginRequest{})
+	_, _ = m.InstrumentAuth(stubAuth{}).LoginMFA(context.Background(), 1, "000000", "")
+
+	if got := testutil.ToFloat64(m.borrows); got != 1 {
+		t.Errorf("expected 1 borrow, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.returns.WithLabelValues("false")); got != 1 {
+		t.Errorf("expected 1 on-time return, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.returns.WithLabelValues("true")); got != 1 {
+		t.Errorf("expected 1 overdue return, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.failedLogins); got != 2 {
+		t.Errorf("expected 2 failed logins, got %v", got)
+	}
+}
+
+func TestOverdueLoansGauge(t *testing.T) {
+	m := New()
+	m.RegisterOverdueLoans(func(context.Context) (int64, error) { return 4, nil })
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(w.Body.String(), "book_lending_overdue_loans 4") {
+		t.Fatalf("overdue gauge missing from output:\n%s", w.Body.String())
+	}
+
+	m = New()
+	m.RegisterOverdueLoans(func(context.Context) (int64, error) { return 0, errors.New("db down") })
+	w = httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "book_lending_overdue_loans") {
+		t.Fatalf("failed gauge should be omitted from a successful scrape, got %d", w.Code)
+	}
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/usecase"
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// instrumentedLending counts successful borrows and returns.
+type instrumentedLending struct {
+	usecase.LendingUseCase
+	m      *Metrics
+	policy domain.LoanPolicy
+}
+
+// InstrumentLending wraps uc so that borrows and returns are counted.
+// policy decides whether a return was overdue.
+func (m *Metrics) InstrumentLending(uc usecase.LendingUseCase, policy domain.LoanPolicy) usecase.LendingUseCase {
+	return &instrumentedLending{LendingUseCase: uc, m: m, policy: policy}
+}
+
+func (i *instrumentedLending) BorrowBook(ctx context.Context, userID, bookID uint) (*domain.LendingRecord, error) {
+	record, err := i.LendingUseCase.BorrowBook(ctx, userID, bookID)
+	if err == nil {
+		i.m.borrows.Inc()
+	}
+	return record, err
+}
+
+func (i *instrumentedLending) ReturnBook(ctx context.Context, userID, recordID uint) (*domain.LendingRecord, error) {
+	record, err := i.LendingUseCase.ReturnBook(ctx, userID, recordID)
+	if err == nil {
+		overdue := i.policy.IsOverdue(record, time.Now())
+		i.m.returns.WithLabelValues(strconv.FormatBool(overdue)).Inc()
+	}
+	return record, err
+}
+
+// instrumentedAuth counts failed logins.
+type instrumentedAuth struct {
+	usecase.AuthUseCase
+	m *Metrics
+}
+
+// InstrumentAuth wraps uc so that failed logins are counted.
+func (m *Metrics) InstrumentAuth(uc usecase.AuthUseCase) usecase.AuthUseCase {
+	return &instrumentedAuth{AuthUseCase: uc, m: m}
+}
+
+func (i *instrumentedAuth) Login(ctx context.Context, req domain.LoginRequest) (*domain.LoginResult, error) {
+	result, err := i.AuthUseCase.Login(ctx, req)
+	if errors.Is(err, domain.ErrInvalidCredentials) {
+		i.m.failedLogins.Inc()
+	}
+	return result, err
+}
+
+func (i *instrumentedAuth) LoginMFA(ctx context.Context, userID uint, code, ip string) (*domain.User, error) {
+	user, err := i.AuthUseCase.LoginMFA(ctx, userID, code, ip)
+	if errors.Is(err, domain.ErrInvalidMFACode) {
+		i.m.failedLogins.Inc()
+	}
+	return user, err
+}
@@ -2,51 +2,173 @@ package middleware
 
 import (
 	"book-lending-api/internal/domain"
+	"book-lending-api/internal/logging"
 	"book-lending-api/pkg"
-	"net/http"
+	"context"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware validates the Authorization header for bearer tokens.
-// If the token is valid the user id and email are injected into the
-// context.  Otherwise the request is aborted with 401.
-func AuthMiddleware(jwtUtil *pkg.JWTUtil) gin.HandlerFunc {
+// APIKeyHeader is the request header carrying an API key.
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyContextKey is the context key under which the API key that
+// authenticated a request is stored.
+const apiKeyContextKey = "api_key"
+
+// sessionIDKey is the context key under which the session of the
+// bearer token that authenticated a request is stored.
+const sessionIDKey = "session_id"
+
+// SessionValidator checks that the session a bearer token belongs to is
+// still active and returns the token's user as they are now.
+type SessionValidator interface {
+	Validate(ctx context.Context, userID, sessionID uint, ip string) (*domain.User, error)
+}
+
+// APIKeyAuthenticator resolves an API key to the key and its owner.
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string) (*domain.APIKey, *domain.User, error)
+}
+
+// AuthMiddleware authenticates the request with a bearer token in the
+// Authorization header or, failing that, an API key in the X-API-Key
+// header.  Bearer tokens are only accepted while their session is
+// active and their user is not suspended, so that revoked sessions and
+// suspensions take effect at once; the user's role is read with the
+// session rather than taken from the token.  If the credentials are
+// valid the user id and email are injected into the context and the
+// user id is added to the request logger.  Otherwise the request is
+// aborted with an unauthorized error rendered by ErrorHandler.
+// Requests already identified by OptionalAuth are let through.
+//
+// API keys are only accepted on routes that name the scopes they
+// require, and only when the key has all of them, so that keys cannot
+// reach account management unless a route opts in.  apiKeys may be nil
+// to accept bearer tokens only, and sessions nil to accept tokens
+// without checking their session.
+func AuthMiddleware(jwtUtil *pkg.JWTUtil, sessions SessionValidator, apiKeys APIKeyAuthenticator, scopes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, domain.ErrorResponse{
-				Error:   "Unauthorized",
-				Message: "Authorization header required",
-			})
-			c.Abort()
-			return
+		if _, ok := GetUserIDFromContext(c); !ok {
+			if err := authenticate(c, jwtUtil, sessions, apiKeys); err != nil {
+				_ = c.Error(err)
+				c.Abort()
+				return
+			}
 		}
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, domain.ErrorResponse{
-				Error:   "Unauthorized",
-				Message: "Invalid authorization header format",
-			})
+		if err := authorizeAPIKey(c, scopes); err != nil {
+			_ = c.Error(err)
 			c.Abort()
 			return
 		}
-		claims, err := jwtUtil.ValidateToken(parts[1])
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, domain.ErrorResponse{
-				Error:   "Unauthorized",
-				Message: "Invalid or expired token",
-			})
+		c.Next()
+	}
+}
+
+// OptionalAuth identifies the user behind a valid bearer token or API
+// key without requiring one, so that middleware running before the
+// route's own AuthMiddleware (such as per-user rate limits) can see who
+// is calling.  Missing or invalid credentials are ignored here and
+// rejected by AuthMiddleware where authentication is required.
+func OptionalAuth(jwtUtil *pkg.JWTUtil, sessions SessionValidator, apiKeys APIKeyAuthenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "" || c.GetHeader(APIKeyHeader) != "" {
+			_ = authenticate(c, jwtUtil, sessions, apiKeys)
+		}
+		c.Next()
+	}
+}
+
+// RequireScope aborts requests made with an API key unless the key has
+// all of scopes.  Other requests, including anonymous ones, are let
+// through, so it suits public routes that API keys may also call.
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := authorizeAPIKey(c, scopes); err != nil {
+			_ = c.Error(err)
 			c.Abort()
 			return
 		}
-		c.Set("user_id", claims.UserID)
-		c.Set("user_email", claims.Email)
 		c.Next()
 	}
 }
 
+func authenticate(c *gin.Context, jwtUtil *pkg.JWTUtil, sessions SessionValidator, apiKeys APIKeyAuthenticator) error {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		if key := c.GetHeader(APIKeyHeader); key != "" && apiKeys != nil {
+			return authenticateAPIKey(c, apiKeys, key)
+		}
+		return domain.ErrMissingAuthHeader
+	}
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return domain.ErrMalformedAuth
+	}
+	claims, err := jwtUtil.ValidateToken(parts[1])
+	if err != nil {
+		return domain.ErrInvalidToken
+	}
+	email, role := claims.Email, claims.Role
+	if sessions != nil {
+		// Tokens issued before sessions were recorded cannot be revoked.
+		if claims.SessionID == 0 {
+			return domain.ErrInvalidToken
+		}
+		user, err := sessions.Validate(c.Request.Context(), claims.UserID, claims.SessionID, c.ClientIP())
+		if err != nil {
+			return err
+		}
+		email, role = user.Email, user.Role
+		c.Set(sessionIDKey, claims.SessionID)
+	}
+	c.Set("user_id", claims.UserID)
+	c.Set("user_email", email)
+	c.Set("user_role", role)
+	c.Set("user_mfa", claims.MFA)
+	c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "user_id", claims.UserID))
+	return nil
+}
+
+// authenticateAPIKey identifies the owner of key.  The role is the
+// owner's current one, read with the key.
+func authenticateAPIKey(c *gin.Context, apiKeys APIKeyAuthenticator, raw string) error {
+	key, user, err := apiKeys.Authenticate(c.Request.Context(), raw)
+	if err != nil {
+		return err
+	}
+	c.Set("user_id", user.ID)
+	c.Set("user_email", user.Email)
+	c.Set("user_role", user.Role)
+	c.Set("user_mfa", key.MFA)
+	c.Set(APIKeyIDKey, key.ID)
+	c.Set(apiKeyContextKey, key)
+	c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "user_id", user.ID, "api_key_id", key.ID))
+	return nil
+}
+
+// authorizeAPIKey checks that the API key a request was made with, if
+// any, has every one of scopes.  Routes that name no scopes refuse API
+// keys altogether.
+func authorizeAPIKey(c *gin.Context, scopes []string) error {
+	v, ok := c.Get(apiKeyContextKey)
+	if !ok {
+		return nil
+	}
+	if len(scopes) == 0 {
+		return domain.ErrAPIKeyNotAllowed
+	}
+	key := v.(*domain.APIKey)
+	for _, scope := range scopes {
+		if !key.HasScope(scope) {
+			return domain.ErrInsufficientScope.WithParams(map[string]string{"scope": scope})
+		}
+	}
+	return nil
+}
+
 // GetUserIDFromContext extracts the user id from the context.
 func GetUserIDFromContext(c *gin.Context) (uint, bool) {
 	userID, exists := c.Get("user_id")
@@ -58,3 +180,50 @@ func GetUserIDFromContext(c *gin.Context) (uint, bool) {
 	}
 	return 0, false
 }
+
+// GetSessionIDFromContext returns the session of the bearer token that
+// authenticated the request.  Requests made with an API key have none.
+func GetSessionIDFromContext(c *gin.Context) (uint, bool) {
+	if id, ok := c.Get(sessionIDKey); ok {
+		if sessionID, ok := id.(uint); ok {
+			return sessionID, true
+		}
+	}
+	return 0, false
+}
+
+// GetUserRoleFromContext returns the authenticated user's role.  Tokens
+// issued before roles existed are treated as members.
+func GetUserRoleFromContext(c *gin.Context) string {
+	if role := c.GetString("user_role"); role != "" {
+		return role
+	}
+	return domain.RoleMember
+}
+
+// RequireRole aborts with domain.ErrForbidden unless the authenticated
+// user has one of roles.  It must run after AuthMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !slices.Contains(roles, GetUserRoleFromContext(c)) {
+			_ = c.Error(domain.ErrForbidden)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireMFA aborts with domain.ErrMFARequired if the authenticated
+// user has one of roles but did not log in with a second factor.  Users
+// with other roles are let through.  It must run after AuthMiddleware.
+func RequireMFA(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if slices.Contains(roles, GetUserRoleFromContext(c)) && !c.GetBool("user_mfa") {
+			_ = c.Error(domain.ErrMFARequired)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,237 @@
+// Unit tests for the authentication and role middleware.
+package middleware
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/i18n"
+	"book-lending-api/pkg"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	translator, err := i18n.New()
+	if err != nil {
+		t.Fatalf("failed to load catalogs: %v", err)
+	}
+	jwtUtil := pkg.NewJWTUtil("test")
+	r := gin.New()
+	r.Use(ErrorHandler(translator), OptionalAuth(jwtUtil, nil, nil))
+	r.GET("/admin", AuthMiddleware(jwtUtil, nil, nil), RequireRole(domain.RoleAdmin), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, tc := range []struct {
+		role string
+		want int
+	}{{"", http.StatusUnauthorized}, {domain.RoleMember, http.StatusForbidden}, {domain.RoleAdmin, http.StatusOK}} {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		if tc.role != "" {
+			token, err := jwtUtil.GenerateToken(&domain.User{ID: 1, Email: "a@example.com", Role: tc.role}, false, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("role %q: expected %d, got %d", tc.role, tc.want, w.Code)
+		}
+	}
+}
+
+func TestRequireMFA(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	translator, err := i18n.New()
+	if err != nil {
+		t.Fatalf("failed to load catalogs: %v", err)
+	}
+	jwtUtil := pkg.NewJWTUtil("test")
+	r := gin.New()
+	r.Use(ErrorHandler(translator))
+	r.GET("/books", AuthMiddleware(jwtUtil, nil, nil), RequireMFA(domain.RoleLibrarian, domain.RoleAdmin), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	challenge, err := jwtUtil.GenerateMFAChallenge(&domain.User{ID: 1}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		role string
+		mfa  bool
+		want int
+	}{
+		{domain.RoleMember, false, http.StatusOK},
+		{domain.RoleLibrarian, false, http.StatusForbidden},
+		{domain.RoleLibrarian, true, http.StatusOK},
+		{domain.RoleAdmin, true, http.StatusOK},
+	} {
+		token, err := jwtUtil.GenerateToken(&domain.User{ID: 1, Email: "a@example.com", Role: tc.role}, tc.mfa, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/books", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("role %q mfa %v: expected %d, got %d", tc.role, tc.mfa, tc.want, w.Code)
+		}
+	}
+
+	// A challenge token is not an access token.
+	req := httptest.NewRequest(http.MethodGet, "/books", nil)
+	req.Header.Set("Authorization", "Bearer "+challenge)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("challenge token: expected 401, got %d", w.Code)
+	}
+}
+
+// stubAPIKeys accepts the single key "blk_valid".
+type stubAPIKeys struct{ key *domain.APIKey }
+
+func (s stubAPIKeys) Authenticate(ctx context.Context, raw string) (*domain.APIKey, *domain.User, error) {
+	if raw != "blk_valid" {
+		return nil, nil, domain.ErrInvalidAPIKey
+	}
+	return s.key, &domain.User{ID: 7, Email: "bot@example.com", Role: domain.RoleMember}, nil
+}
+
+func TestAuthMiddlewareAPIKeyScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	translator, err := i18n.New()
+	if err != nil {
+		t.Fatalf("failed to load catalogs: %v", err)
+	}
+	jwtUtil := pkg.NewJWTUtil("test")
+	keys := stubAPIKeys{&domain.APIKey{ID: 3, Scopes: []string{domain.ScopeLendingWrite}}}
+	ok := func(c *gin.Context) { c.String(http.StatusOK, c.GetString("user_email")) }
+	r := gin.New()
+	r.Use(ErrorHandler(translator), OptionalAuth(jwtUtil, nil, keys))
+	r.GET("/me", AuthMiddleware(jwtUtil, nil, keys), ok)
+	r.GET("/active", AuthMiddleware(jwtUtil, nil, keys, domain.ScopeLendingRead), ok)
+	r.GET("/admin", AuthMiddleware(jwtUtil, nil, keys, domain.ScopeUsersRead), ok)
+	r.GET("/books", RequireScope(domain.ScopeBooksRead), ok)
+
+	for _, tc := range []struct {
+		path, key string
+		want      int
+	}{
+		{"/active", "blk_valid", http.StatusOK},
+		{"/active", "blk_wrong", http.StatusUnauthorized},
+		{"/admin", "blk_valid", http.StatusForbidden},
+		{"/me", "blk_valid", http.StatusForbidden},
+		{"/books", "blk_valid", http.StatusForbidden},
+		{"/books", "", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.key != "" {
+			req.Header.Set(APIKeyHeader, tc.key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("%s with key %q: expected %d, got %d", tc.path, tc.key, tc.want, w.Code)
+		}
+		if w.Code == http.StatusOK && tc.key != "" && w.Body.String() != "bot@example.com" {
+			t.Errorf("%s: expected the key's owner, got %q", tc.path, w.Body.String())
+		}
+	}
+}
+
+// stubSessions accepts the sessions listed in active, which map to
+// their user as they are now.
+type stubSessions struct{ active map[uint]*domain.User }
+
+func (s stubSessions) Validate(ctx context.Context, userID, sessionID uint, ip string) (*domain.User, error) {
+	user, ok := s.active[sessionID]
+	if !ok {
+		return nil, domain.ErrSessionRevoked
+	}
+	if user.Suspended() {
+		return nil, domain.ErrAccountSuspended
+	}
+	return user, nil
+}
+
+func TestAuthMiddlewareSessions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	translator, err := i18n.New()
+	if err != nil {
+		t.Fatalf("failed to load catalogs: %v", err)
+	}
+	jwtUtil := pkg.NewJWTUtil("test")
+	sessions := stubSessions{map[uint]*domain.User{5: {ID: 1, Email: "a@example.com"}}}
+	r := gin.New()
+	r.Use(ErrorHandler(translator), OptionalAuth(jwtUtil, sessions, nil))
+	r.GET("/me", AuthMiddleware(jwtUtil, sessions, nil), func(c *gin.Context) {
+		id, _ := GetSessionIDFromContext(c)
+		c.String(http.StatusOK, "%d", id)
+	})
+
+	for _, tc := range []struct {
+		sessionID uint
+		want      int
+	}{
+		{5, http.StatusOK},
+		{6, http.StatusUnauthorized},
+		{0, http.StatusUnauthorized},
+	} {
+		token, err := jwtUtil.GenerateToken(&domain.User{ID: 1, Email: "a@example.com"}, false, tc.sessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("session %d: expected %d, got %d", tc.sessionID, tc.want, w.Code)
+		}
+		if w.Code == http.StatusOK && w.Body.String() != "5" {
+			t.Errorf("expected the token's session in the context, got %q", w.Body.String())
+		}
+	}
+}
+
+func TestAuthMiddlewareUsesCurrentUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	translator, err := i18n.New()
+	if err != nil {
+		t.Fatalf("failed to load catalogs: %v", err)
+	}
+	jwtUtil := pkg.NewJWTUtil("test")
+	suspendedAt := time.Unix(1_700_000_000, 0)
+	// Both tokens were issued to librarians; one has since been demoted
+	// and the other suspended.
+	sessions := stubSessions{map[uint]*domain.User{
+		1: {ID: 1, Email: "a@example.com", Role: domain.RoleMember},
+		2: {ID: 2, Email: "b@example.com", Role: domain.RoleLibrarian, SuspendedAt: &suspendedAt},
+	}}
+	r := gin.New()
+	r.Use(ErrorHandler(translator))
+	r.GET("/staff", AuthMiddleware(jwtUtil, sessions, nil), RequireRole(domain.RoleLibrarian), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for sessionID, want := range map[uint]string{1: "forbidden", 2: "account_suspended"} {
+		token, err := jwtUtil.GenerateToken(&domain.User{ID: sessionID, Role: domain.RoleLibrarian}, false, sessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/staff", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), want) {
+			t.Errorf("session %d: expected 403 %s, got %d %s", sessionID, want, w.Code, w.Body.String())
+		}
+	}
+}
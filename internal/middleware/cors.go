@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"book-lending-api/internal/config"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORS answers preflight requests and sets the Access-Control headers
+// allowed by cfg.  An origin list containing "*" allows every origin;
+// otherwise only listed origins are echoed back.  The request ID and
+// rate limit headers are exposed to scripts.
+func CORS(cfg config.CORSConfig) gin.HandlerFunc {
+	allowAll := slices.Contains(cfg.AllowedOrigins, "*")
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	exposed := strings.Join([]string{
+		RequestIDHeader, RateLimitLimitHeader, RateLimitRemainingHeader,
+		RateLimitResetHeader, RateLimitPolicyHeader, RetryAfterHeader,
+	}, ", ")
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		switch {
+		case allowAll:
+			c.Header("Access-Control-Allow-Origin", "*")
+		case origin != "" && slices.Contains(cfg.AllowedOrigins, origin):
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Vary", "Origin")
+		}
+		c.Header("Access-Control-Allow-Methods", methods)
+		c.Header("Access-Control-Allow-Headers", headers)
+		c.Header("Access-Control-Expose-Headers", exposed)
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/i18n"
+	"book-lending-api/internal/logging"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+)
+
+// ErrorHandler renders the last error attached to the context with
+// c.Error once the handler chain has finished.  Domain errors are
+// mapped to HTTP statuses by kind and rendered with their stable code.
+// Anything else is logged and reported as a generic internal error so
+// that implementation details never reach clients.  Both formats carry
+// the request ID so that users can quote it in support requests.  Clients that
+// accept application/problem+json receive an RFC 7807 document with
+// field-level validation errors instead of domain.ErrorResponse.
+//
+// Messages are translated into the best language from Accept-Language
+// using translator, falling back to English.  Errors with a
+// retry_after parameter also set the Retry-After header.
+func ErrorHandler(translator *i18n.Translator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		err := domain.AsError(c.Errors.Last().Err)
+		status := StatusForKind(err.Kind)
+		if err.Kind == domain.KindInternal && err.Err != nil {
+			logging.FromContext(c.Request.Context()).Error("internal error", "error", err.Err)
+		}
+		lang := translator.Match(c.GetHeader("Accept-Language"))
+		message, ok := translator.Translate(lang, err.Code, err.Params)
+		if !ok {
+			message = err.Message
+		}
+		fields := fieldErrors(err, translator, lang)
+		if len(fields) > 0 {
+			parts := make([]string, len(fields))
+			for i, fe := range fields {
+				parts[i] = fe.Field + " " + fe.Message
+			}
+			message = strings.Join(parts, "; ")
+		}
+		c.Header("Content-Language", lang)
+		if retry := err.Params["retry_after"]; retry != "" {
+			c.Header(RetryAfterHeader, retry)
+		}
+		if wantsProblem(c) {
+			c.Header("Content-Type", ProblemContentType)
+			c.Render(status, render.JSON{Data: newProblem(c, err, status, message, fields)})
+			return
+		}
+		c.JSON(status, domain.ErrorResponse{
+			Error:     http.StatusText(status),
+			Code:      err.Code,
+			Message:   message,
+			RequestID: GetRequestID(c),
+		})
+	}
+}
+
+// StatusClientClosedRequest is the non-standard status, popularised by
+// nginx, recorded when the client disconnects before the response.
+const StatusClientClosedRequest = 499
+
+// StatusForKind maps a domain error kind to an HTTP status code.
+func StatusForKind(kind domain.ErrorKind) int {
+	switch kind {
+	case domain.KindInvalid:
+		return http.StatusBadRequest
+	case domain.KindUnauthorized:
+		return http.StatusUnauthorized
+	case domain.KindForbidden:
+		return http.StatusForbidden
+	case domain.KindNotFound:
+		return http.StatusNotFound
+	case domain.KindConflict:
+		return http.StatusConflict
+	case domain.KindLocked:
+		return http.StatusLocked
+	case domain.KindTooManyRequests:
+		return http.StatusTooManyRequests
+	case domain.KindTimeout:
+		return http.StatusGatewayTimeout
+	case domain.KindCanceled:
+		return StatusClientClosedRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
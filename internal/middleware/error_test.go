@@ -0,0 +1,118 @@
+// Unit tests for the error rendering middleware.
+package middleware
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/i18n"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setupErrorRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	translator, err := i18n.New()
+	if err != nil {
+		t.Fatalf("failed to load catalogs: %v", err)
+	}
+	r := gin.New()
+	r.Use(ErrorHandler(translator))
+	r.POST("/register", func(c *gin.Context) {
+		var req domain.RegisterRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			_ = c.Error(domain.ErrInvalidRequest.WithCause(err))
+			return
+		}
+		_ = c.Error(domain.ErrEmailTaken)
+	})
+	return r
+}
+
+func TestErrorHandlerLegacyFormat(t *testing.T) {
+	r := setupErrorRouter(t)
+	req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(`{"email":"a@b.co","password":"secret123"}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status 409, got %d", w.Code)
+	}
+	var body domain.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Code != "email_taken" {
+		t.Fatalf("unexpected body: %s (err=%v)", w.Body.String(), err)
+	}
+}
+
+func TestErrorHandlerProblemJSON(t *testing.T) {
+	r := setupErrorRouter(t)
+	req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(`{"email":"nope"}`))
+	req.Header.Set("Accept", "application/problem+json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, ProblemContentType) {
+		t.Fatalf("expected problem content type, got %q", ct)
+	}
+	var problem domain.ProblemDetails
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("invalid problem body: %v", err)
+	}
+	if problem.Status != http.StatusBadRequest || problem.Instance != "/register" || len(problem.Errors) != 2 {
+		t.Fatalf("unexpected problem: %+v", problem)
+	}
+	if problem.Errors[0].Field != "email" || problem.Errors[0].Rule != "email" {
+		t.Fatalf("unexpected field error: %+v", problem.Errors[0])
+	}
+}
+
+func TestErrorHandlerLocalizesMessages(t *testing.T) {
+	r := setupErrorRouter(t)
+	req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(`{"email":"a@b.co","password":"secret123"}`))
+	req.Header.Set("Accept-Language", "fr;q=0.9, id-ID, en;q=0.5")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var body domain.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid body: %v", err)
+	}
+	if body.Message != "pengguna dengan email ini sudah terdaftar" || w.Header().Get("Content-Language") != "id" {
+		t.Fatalf("expected Indonesian message, got %q (%s)", body.Message, w.Header().Get("Content-Language"))
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(`{}`))
+	req.Header.Set("Accept-Language", "id")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid body: %v", err)
+	}
+	if body.Message != "email wajib diisi; password wajib diisi" {
+		t.Fatalf("unexpected validation message: %q", body.Message)
+	}
+}
+
+func TestErrorHandlerLockedWithRetryAfter(t *testing.T) {
+	r := setupErrorRouter(t)
+	r.POST("/login", func(c *gin.Context) {
+		_ = c.Error(domain.ErrAccountLocked.WithParams(map[string]string{"retry_after": "90"}))
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/login", nil))
+
+	if w.Code != http.StatusLocked || w.Header().Get(RetryAfterHeader) != "90" {
+		t.Fatalf("expected 423 with Retry-After 90, got %d %v", w.Code, w.Header())
+	}
+	if !strings.Contains(w.Body.String(), "try again in 90 seconds") {
+		t.Fatalf("expected the wait in the message, got %s", w.Body.String())
+	}
+}
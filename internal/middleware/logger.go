@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"book-lending-api/internal/logging"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestLogger gives each request its own logger, derived from base
+// and carrying the route and trace ID, and writes one access log line
+// when the request completes.  It must run after RequestID and before
+// ErrorHandler.  Requests to quietPaths, such as health probes, are
+// logged at debug level.
+func RequestLogger(base *slog.Logger, quietPaths ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		ctx := c.Request.Context()
+		logger := base
+		if id := GetRequestID(c); id != "" {
+			logger = logger.With("request_id", id)
+		}
+		logger = logger.With("method", c.Request.Method, "route", c.FullPath())
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			logger = logger.With("trace_id", sc.TraceID().String())
+		}
+		c.Request = c.Request.WithContext(logging.WithLogger(ctx, logger))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		case slices.Contains(quietPaths, c.Request.URL.Path):
+			level = slog.LevelDebug
+		}
+		// Read the logger back so that attributes added downstream,
+		// such as user_id from AuthMiddleware, are included.
+		logging.FromContext(c.Request.Context()).LogAttrs(c.Request.Context(), level, "request",
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+		)
+	}
+}
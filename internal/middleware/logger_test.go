@@ -0,0 +1,89 @@
+// Unit tests for request IDs, request logging and panic recovery.
+package middleware
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/i18n"
+	"book-lending-api/internal/logging"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setupLoggingRouter(t *testing.T) (*gin.Engine, *bytes.Buffer) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	translator, err := i18n.New()
+	if err != nil {
+		t.Fatalf("failed to load catalogs: %v", err)
+	}
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	r := gin.New()
+	r.Use(RequestID(), RequestLogger(logger), ErrorHandler(translator), Recovery())
+	r.GET("/books/:id", func(c *gin.Context) {
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "user_id", 7))
+		_ = c.Error(domain.ErrBookNotFound)
+	})
+	r.GET("/panic", func(c *gin.Context) { panic("boom") })
+	return r, &buf
+}
+
+func lastLogLine(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &entry); err != nil {
+		t.Fatalf("invalid log line %q: %v", lines[len(lines)-1], err)
+	}
+	return entry
+}
+
+func TestRequestIDAndAccessLog(t *testing.T) {
+	r, buf := setupLoggingRouter(t)
+	req := httptest.NewRequest(http.MethodGet, "/books/9", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if got := w.Header().Get(RequestIDHeader); got != "abc-123" {
+		t.Fatalf("expected request ID to be echoed, got %q", got)
+	}
+	var body domain.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.RequestID != "abc-123" {
+		t.Fatalf("expected request ID in error body, got %s", w.Body.String())
+	}
+	entry := lastLogLine(t, buf)
+	if entry["request_id"] != "abc-123" || entry["route"] != "/books/:id" || entry["user_id"] != float64(7) || entry["status"] != float64(404) {
+		t.Fatalf("unexpected access log entry: %v", entry)
+	}
+}
+
+func TestRequestIDGeneratedForInvalidHeader(t *testing.T) {
+	r, _ := setupLoggingRouter(t)
+	req := httptest.NewRequest(http.MethodGet, "/books/9", nil)
+	req.Header.Set(RequestIDHeader, "bad id\nwith newline")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if got := w.Header().Get(RequestIDHeader); len(got) != 32 {
+		t.Fatalf("expected a generated request ID, got %q", got)
+	}
+}
+
+func TestRecoveryRendersInternalError(t *testing.T) {
+	r, buf := setupLoggingRouter(t)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), `"code":"internal_error"`) {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(buf.String(), `"msg":"panic recovered"`) {
+		t.Fatalf("panic was not logged: %s", buf.String())
+	}
+}
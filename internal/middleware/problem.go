@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/i18n"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// ProblemContentType is the media type of RFC 7807 error bodies.
+const ProblemContentType = "application/problem+json"
+
+func init() {
+	// Report JSON/form field names rather than Go struct field names in
+	// validation errors so clients can map them back to their input.
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			for _, tag := range []string{"json", "form"} {
+				name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+				if name == "-" {
+					return ""
+				}
+				if name != "" {
+					return name
+				}
+			}
+			return f.Name
+		})
+		_ = v.RegisterValidation("phone", validatePhone)
+	}
+}
+
+// e164 matches a phone number in E.164 international format.
+var e164 = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+
+// validatePhone implements the "phone" binding rule: an E.164 number,
+// or the empty string so that a stored number can be cleared.
+func validatePhone(fl validator.FieldLevel) bool {
+	s := fl.Field().String()
+	return s == "" || e164.MatchString(s)
+}
+
+// wantsProblem reports whether the client listed application/problem+json
+// in its Accept header.  Clients that do not ask keep receiving
+// domain.ErrorResponse.
+func wantsProblem(c *gin.Context) bool {
+	for _, part := range strings.Split(c.GetHeader("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(part, ";")
+		if strings.EqualFold(strings.TrimSpace(mediaType), ProblemContentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// newProblem builds the problem document for a domain error.
+func newProblem(c *gin.Context, err *domain.Error, status int, detail string, fields []domain.FieldError) domain.ProblemDetails {
+	problemType := "about:blank"
+	if err.Kind != domain.KindInternal {
+		problemType = "urn:book-lending:problem:" + err.Code
+	}
+	return domain.ProblemDetails{
+		Type:      problemType,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  c.Request.URL.RequestURI(),
+		Code:      err.Code,
+		RequestID: GetRequestID(c),
+		Errors:    fields,
+	}
+}
+
+// fieldErrors extracts per-field failures from a binding error and
+// renders their messages in lang.  It understands validator failures
+// and JSON type mismatches; any other error yields nil.
+func fieldErrors(err error, translator *i18n.Translator, lang string) []domain.FieldError {
+	var out []domain.FieldError
+	var verrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &verrs):
+		for _, fe := range verrs {
+			out = append(out, domain.FieldError{Field: fieldPath(fe), Rule: fe.Tag(), Param: fe.Param()})
+		}
+	case errors.As(err, &typeErr):
+		out = append(out, domain.FieldError{Field: typeErr.Field, Rule: "type", Param: typeErr.Type.String()})
+	}
+	for i, fe := range out {
+		params := map[string]string{"param": fe.Param, "rule": fe.Rule}
+		msg, ok := translator.Translate(lang, "validation."+fe.Rule, params)
+		if !ok {
+			msg, _ = translator.Translate(lang, "validation.default", params)
+		}
+		out[i].Message = msg
+	}
+	return out
+}
+
+// fieldPath strips the top-level struct name from the validator
+// namespace, e.g. "RegisterRequest.email" becomes "email".
+func fieldPath(fe validator.FieldError) string {
+	if _, rest, ok := strings.Cut(fe.Namespace(), "."); ok {
+		return rest
+	}
+	return fe.Field()
+}
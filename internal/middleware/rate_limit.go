@@ -1,83 +1,122 @@
 package middleware
 
 import (
+	"book-lending-api/internal/config"
 	"book-lending-api/internal/domain"
-	"net/http"
-	"sync"
+	"book-lending-api/internal/logging"
+	"book-lending-api/internal/ratelimit"
+	"fmt"
+	"math"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/time/rate"
 )
 
-// RateLimiter provides a simple per‑IP token bucket implementation.
-// It stores a limiter per client IP and periodically cleans up old
-// entries.  This middleware is intended to protect the API against
-// bursts of traffic or abuse.
-type RateLimiter struct {
-	limiters map[string]*rate.Limiter
-	mu       sync.RWMutex
-	rate     rate.Limit
-	burst    int
+// Headers describing the rate limit that applies to a response.  They
+// follow the IETF RateLimit header fields draft.
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+	RateLimitPolicyHeader    = "RateLimit-Policy"
+	RetryAfterHeader         = "Retry-After"
+)
+
+// APIKeyIDKey is the context key under which an authenticated API
+// key's identifier is stored.
+const APIKeyIDKey = "api_key_id"
+
+// KeyFunc identifies the client a request is counted against.
+type KeyFunc func(c *gin.Context) string
+
+// KeyByIP counts requests per client IP address.
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
 }
 
-// NewRateLimiter constructs a RateLimiter with the provided rate and
-// burst capacity.
-func NewRateLimiter(r rate.Limit, b int) *RateLimiter {
-	return &RateLimiter{
-		limiters: make(map[string]*rate.Limiter),
-		rate:     r,
-		burst:    b,
+// KeyByUser counts requests per authenticated user, falling back to
+// the client IP for anonymous requests.
+func KeyByUser(c *gin.Context) string {
+	if id, ok := GetUserIDFromContext(c); ok {
+		return "user:" + strconv.FormatUint(uint64(id), 10)
 	}
+	return KeyByIP(c)
 }
 
-func (rl *RateLimiter) getLimiter(key string) *rate.Limiter {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-	if limiter, exists := rl.limiters[key]; exists {
-		return limiter
+// KeyByAPIKey counts requests per authenticated API key, falling back
+// to the user and then the client IP.  Only keys that have already
+// been authenticated are used, so clients cannot dodge the limit by
+// inventing new keys.
+func KeyByAPIKey(c *gin.Context) string {
+	if id, ok := c.Get(APIKeyIDKey); ok {
+		return "key:" + fmt.Sprint(id)
 	}
-	limiter := rate.NewLimiter(rl.rate, rl.burst)
-	rl.limiters[key] = limiter
-	return limiter
+	return KeyByUser(c)
 }
 
-// cleanupOldEntries removes unused limiters periodically.  This is a
-// naive implementation; in production you may want to track last
-// access time per key.
-func (rl *RateLimiter) cleanupOldEntries() {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-	if len(rl.limiters) > 1000 {
-		for k := range rl.limiters {
-			delete(rl.limiters, k)
-		}
+// RateLimitPolicy is a limit together with how clients are told
+// apart.  Each policy has its own buckets, so a request can be counted
+// against several policies at once.
+type RateLimitPolicy struct {
+	ratelimit.Limit
+	Key KeyFunc
+}
+
+// NewRateLimitPolicy builds the policy described by cfg.
+func NewRateLimitPolicy(name string, cfg config.RateLimitPolicy) RateLimitPolicy {
+	key := KeyByIP
+	switch cfg.Key {
+	case config.RateLimitByUser:
+		key = KeyByUser
+	case config.RateLimitByAPIKey:
+		key = KeyByAPIKey
+	}
+	return RateLimitPolicy{
+		Limit: ratelimit.PerMinute(name, cfg.RequestsPerMinute, cfg.Burst),
+		Key:   key,
 	}
 }
 
-// RateLimitMiddleware returns a Gin middleware that applies rate
-// limiting based on the client's IP address.  If a request exceeds
-// the allowed rate a 429 response is returned and the request is
-// aborted.
-func RateLimitMiddleware(rl *RateLimiter) gin.HandlerFunc {
-	go func() {
-		ticker := time.NewTicker(10 * time.Minute)
-		defer ticker.Stop()
-		for range ticker.C {
-			rl.cleanupOldEntries()
-		}
-	}()
+// RateLimitMiddleware returns a Gin middleware that counts each request
+// against policy in store and reports the outcome in RateLimit-*
+// headers.  When several policies apply, the headers describe the one
+// closest to its limit.  If a request exceeds the allowed rate it is
+// aborted with domain.ErrRateLimited, which ErrorHandler renders as
+// 429, and Retry-After says when to try again.  Requests are let
+// through when the store fails, so an unavailable Redis does not take
+// the API down with it.
+func RateLimitMiddleware(store ratelimit.Store, policy RateLimitPolicy) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		key := c.ClientIP()
-		limiter := rl.getLimiter(key)
-		if !limiter.Allow() {
-			c.JSON(http.StatusTooManyRequests, domain.ErrorResponse{
-				Error:   "Rate limit exceeded",
-				Message: "Too many requests, please try again later",
-			})
+		d, err := store.Allow(c.Request.Context(), policy.Limit, policy.Key(c))
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Warn("rate limit check failed", "policy", policy.Name, "error", err)
+			c.Next()
+			return
+		}
+		if !d.Allowed || tighterThanReported(c, d.Remaining) {
+			c.Header(RateLimitLimitHeader, strconv.Itoa(policy.Burst))
+			c.Header(RateLimitRemainingHeader, strconv.Itoa(d.Remaining))
+			c.Header(RateLimitResetHeader, strconv.Itoa(ceilSeconds(d.Reset)))
+			c.Header(RateLimitPolicyHeader, strconv.Itoa(policy.Burst)+";w="+strconv.Itoa(ceilSeconds(policy.Window())))
+		}
+		if !d.Allowed {
+			c.Header(RetryAfterHeader, strconv.Itoa(max(1, ceilSeconds(d.RetryAfter))))
+			_ = c.Error(domain.ErrRateLimited)
 			c.Abort()
 			return
 		}
 		c.Next()
 	}
 }
+
+// tighterThanReported reports whether remaining is lower than the
+// value an earlier policy already put in the response headers.
+func tighterThanReported(c *gin.Context, remaining int) bool {
+	reported, err := strconv.Atoi(c.Writer.Header().Get(RateLimitRemainingHeader))
+	return err != nil || remaining < reported
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,122 @@
+// Unit tests for the rate limiting middleware.
+package middleware
+
+import (
+	"book-lending-api/internal/i18n"
+	"book-lending-api/internal/ratelimit"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newRateLimitRouter(t *testing.T, middleware ...gin.HandlerFunc) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	translator, err := i18n.New()
+	if err != nil {
+		t.Fatalf("failed to load catalogs: %v", err)
+	}
+	r := gin.New()
+	r.Use(ErrorHandler(translator))
+	r.Use(middleware...)
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return r
+}
+
+func TestRateLimitMiddlewareRejectsBurst(t *testing.T) {
+	policy := RateLimitPolicy{Limit: ratelimit.Limit{Name: "test", Interval: time.Hour, Burst: 1}, Key: KeyByIP}
+	r := newRateLimitRouter(t, RateLimitMiddleware(ratelimit.NewMemoryStore(), policy))
+
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != want {
+			t.Fatalf("request %d: expected %d, got %d", i, want, w.Code)
+		}
+	}
+}
+
+func TestRateLimitMiddlewareHeaders(t *testing.T) {
+	policy := RateLimitPolicy{Limit: ratelimit.Limit{Name: "test", Interval: time.Minute, Burst: 2}, Key: KeyByIP}
+	r := newRateLimitRouter(t, RateLimitMiddleware(ratelimit.NewMemoryStore(), policy))
+
+	want := []struct {
+		code      int
+		remaining string
+	}{{http.StatusOK, "1"}, {http.StatusOK, "0"}, {http.StatusTooManyRequests, "0"}}
+	for i, tc := range want {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != tc.code {
+			t.Fatalf("request %d: expected %d, got %d", i, tc.code, w.Code)
+		}
+		h := w.Header()
+		if h.Get(RateLimitLimitHeader) != "2" || h.Get(RateLimitRemainingHeader) != tc.remaining || h.Get(RateLimitPolicyHeader) != "2;w=120" {
+			t.Fatalf("request %d: unexpected headers %v", i, h)
+		}
+		if tc.code == http.StatusTooManyRequests && h.Get(RetryAfterHeader) != "60" {
+			t.Fatalf("expected Retry-After 60, got %q", h.Get(RetryAfterHeader))
+		}
+	}
+}
+
+func TestRateLimitMiddlewareKeysByUser(t *testing.T) {
+	policy := RateLimitPolicy{Limit: ratelimit.Limit{Name: "test", Interval: time.Hour, Burst: 1}, Key: KeyByUser}
+	asUser := func(c *gin.Context) {
+		if id := c.GetHeader("X-User"); id != "" {
+			c.Set("user_id", uint(len(id)))
+		}
+	}
+	r := newRateLimitRouter(t, asUser, RateLimitMiddleware(ratelimit.NewMemoryStore(), policy))
+
+	// Two users behind the same address each get their own bucket;
+	// anonymous requests share the address's bucket.
+	for i, tc := range []struct {
+		user string
+		want int
+	}{{"a", http.StatusOK}, {"bb", http.StatusOK}, {"a", http.StatusTooManyRequests}, {"", http.StatusOK}, {"", http.StatusTooManyRequests}} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-User", tc.user)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Fatalf("request %d: expected %d, got %d", i, tc.want, w.Code)
+		}
+	}
+}
+
+func TestRateLimitMiddlewareReportsTightestPolicy(t *testing.T) {
+	rl := ratelimit.NewMemoryStore()
+	loose := RateLimitPolicy{Limit: ratelimit.Limit{Name: "default", Interval: time.Second, Burst: 100}, Key: KeyByIP}
+	strict := RateLimitPolicy{Limit: ratelimit.Limit{Name: "auth", Interval: time.Minute, Burst: 3}, Key: KeyByIP}
+	r := newRateLimitRouter(t, RateLimitMiddleware(rl, loose), RateLimitMiddleware(rl, strict))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Header().Get(RateLimitLimitHeader) != "3" || w.Header().Get(RateLimitRemainingHeader) != "2" {
+		t.Fatalf("expected the strict policy in headers, got %v", w.Header())
+	}
+}
+
+// failingStore stands in for an unreachable shared store.
+type failingStore struct{}
+
+func (failingStore) Allow(context.Context, ratelimit.Limit, string) (ratelimit.Decision, error) {
+	return ratelimit.Decision{}, errors.New("connection refused")
+}
+
+func TestRateLimitMiddlewareFailsOpen(t *testing.T) {
+	policy := RateLimitPolicy{Limit: ratelimit.PerMinute("test", 1, 1), Key: KeyByIP}
+	r := newRateLimitRouter(t, RateLimitMiddleware(failingStore{}, policy))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK || w.Header().Get(RateLimitLimitHeader) != "" {
+		t.Fatalf("expected the request through without headers, got %d %v", w.Code, w.Header())
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/logging"
+	"errors"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Recovery turns a panic in a handler into an internal error rendered
+// by ErrorHandler, logging the panic value and stack trace with the
+// request logger.  It must run after ErrorHandler.  The error carries
+// no cause, so ErrorHandler does not log it a second time.
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if err, ok := rec.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(rec)
+			}
+			logging.FromContext(c.Request.Context()).Error("panic recovered",
+				"panic", rec, "stack", string(debug.Stack()))
+			_ = c.Error(domain.ErrInternal)
+			c.Abort()
+		}()
+		c.Next()
+	}
+}
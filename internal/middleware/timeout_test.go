@@ -0,0 +1,33 @@
+// Unit tests for the request timeout middleware.
+package middleware
+
+import (
+	"book-lending-api/internal/i18n"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTimeoutRendersGatewayTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	translator, err := i18n.New()
+	if err != nil {
+		t.Fatalf("failed to load catalogs: %v", err)
+	}
+	r := gin.New()
+	r.Use(ErrorHandler(translator), Timeout(10*time.Millisecond))
+	r.GET("/slow", func(c *gin.Context) {
+		// Stands in for a query that is cancelled at the deadline.
+		<-c.Request.Context().Done()
+		_ = c.Error(c.Request.Context().Err())
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,297 @@
+// Package migrate applies versioned SQL migrations and records which
+// versions have been applied in a schema_migrations table.
+//
+// Each migration runs in a transaction, but MySQL commits every schema
+// change as soon as it runs, so a MySQL script that fails part way is
+// left half applied and unrecorded.  MySQL scripts therefore make at
+// most one schema change that is not guarded by IF [NOT] EXISTS, and
+// make it first: several columns are added in a single ALTER TABLE
+// rather than one each.  Statements after it should be safe to finish
+// by hand should they fail.
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// schemaTable records applied migration versions.
+const schemaTable = "schema_migrations"
+
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is a single versioned schema change.
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes whether a migration has been applied.
+type Status struct {
+	Version   uint       `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// Migrator runs migrations against a database.
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+type appliedRow struct {
+	Version   uint
+	Name      string
+	AppliedAt time.Time
+}
+
+// Load reads every migration pair from the root of fsys, sorted by
+// version.  Every version must have both an up and a down script.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[uint]*Migration)
+	for _, entry := range entries {
+		m := fileName.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(m[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", entry.Name(), err)
+		}
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		mig, ok := byVersion[uint(version)]
+		if !ok {
+			mig = &Migration{Version: uint(version), Name: m[2]}
+			byVersion[uint(version)] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if strings.TrimSpace(mig.Up) == "" || strings.TrimSpace(mig.Down) == "" {
+			return nil, fmt.Errorf("migration %d_%s must have non-empty up and down scripts", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// New loads the migrations in fsys and returns a Migrator for db.
+func New(db *gorm.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Up applies every pending migration in order.
+func (m *Migrator) Up() error {
+	if len(m.migrations) == 0 {
+		return nil
+	}
+	return m.To(m.migrations[len(m.migrations)-1].Version)
+}
+
+// Down reverts the given number of most recently applied migrations.
+func (m *Migrator) Down(steps int) error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	versions := sortedVersions(applied)
+	for i := 0; i < steps && i < len(versions); i++ {
+		v := versions[len(versions)-1-i]
+		mig, ok := m.find(v)
+		if !ok {
+			return fmt.Errorf("applied migration %d has no script", v)
+		}
+		if err := m.revert(mig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// To migrates up or down so that exactly the migrations with a version
+// less than or equal to target are applied.  A target of 0 reverts
+// everything.
+func (m *Migrator) To(target uint) error {
+	if target != 0 {
+		if _, ok := m.find(target); !ok {
+			return fmt.Errorf("unknown migration version %d", target)
+		}
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	versions := sortedVersions(applied)
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i] <= target {
+			break
+		}
+		mig, ok := m.find(versions[i])
+		if !ok {
+			return fmt.Errorf("applied migration %d has no script", versions[i])
+		}
+		if err := m.revert(mig); err != nil {
+			return err
+		}
+	}
+	for _, mig := range m.migrations {
+		if mig.Version > target {
+			break
+		}
+		if _, done := applied[mig.Version]; done {
+			continue
+		}
+		if err := m.apply(mig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Status lists every known migration along with whether it has been
+// applied.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if row, ok := applied[mig.Version]; ok {
+			at := row.AppliedAt
+			s.Applied = true
+			s.AppliedAt = &at
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// Pending returns the number of migrations that have not been applied.
+func (m *Migrator) Pending() (int, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, s := range statuses {
+		if !s.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+func (m *Migrator) find(version uint) (Migration, bool) {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return mig, true
+		}
+	}
+	return Migration{}, false
+}
+
+func (m *Migrator) ensureSchemaTable() error {
+	return m.db.Exec(`CREATE TABLE IF NOT EXISTS ` + schemaTable + ` (
+    version BIGINT NOT NULL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    applied_at TIMESTAMP NOT NULL
+)`).Error
+}
+
+func (m *Migrator) applied() (map[uint]appliedRow, error) {
+	if err := m.ensureSchemaTable(); err != nil {
+		return nil, err
+	}
+	var rows []appliedRow
+	if err := m.db.Table(schemaTable).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[uint]appliedRow, len(rows))
+	for _, row := range rows {
+		out[row.Version] = row
+	}
+	return out, nil
+}
+
+func (m *Migrator) apply(mig Migration) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		if err := execScript(tx, mig.Up); err != nil {
+			return fmt.Errorf("migration %d_%s up: %w", mig.Version, mig.Name, err)
+		}
+		return tx.Exec("INSERT INTO "+schemaTable+" (version, name, applied_at) VALUES (?, ?, ?)",
+			mig.Version, mig.Name, time.Now().UTC()).Error
+	})
+}
+
+func (m *Migrator) revert(mig Migration) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		if err := execScript(tx, mig.Down); err != nil {
+			return fmt.Errorf("migration %d_%s down: %w", mig.Version, mig.Name, err)
+		}
+		return tx.Exec("DELETE FROM "+schemaTable+" WHERE version = ?", mig.Version).Error
+	})
+}
+
+// execScript runs each statement in script.  Errors name the failing
+// statement, since on MySQL the ones before it stay applied.
+func execScript(tx *gorm.DB, script string) error {
+	for i, stmt := range statements(script) {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("statement %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// statements splits script into its semicolon terminated statements.
+// The splitter is deliberately simple: migrations must not contain
+// semicolons inside string literals.
+func statements(script string) []string {
+	var out []string
+	for _, stmt := range strings.Split(script, ";") {
+		if strings.TrimSpace(stmt) != "" {
+			out = append(out, stmt)
+		}
+	}
+	return out
+}
+
+func sortedVersions(applied map[uint]appliedRow) []uint {
+	versions := make([]uint, 0, len(applied))
+	for v := range applied {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions
+}
@@ -0,0 +1,106 @@
+// Unit tests for the migration runner using sqlite in-memory
+package migrate
+
+import (
+	"book-lending-api/migrations"
+	"io/fs"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+var testMigrations = fstest.MapFS{
+	"000001_create_a.up.sql":   {Data: []byte("CREATE TABLE a (id INTEGER PRIMARY KEY);")},
+	"000001_create_a.down.sql": {Data: []byte("DROP TABLE a;")},
+	"000002_create_b.up.sql":   {Data: []byte("CREATE TABLE b (id INTEGER PRIMARY KEY);\nCREATE INDEX idx_b ON b (id);")},
+	"000002_create_b.down.sql": {Data: []byte("DROP INDEX idx_b;\nDROP TABLE b;")},
+	"README.md":                {Data: []byte("ignored")},
+}
+
+func setupMigrator(t *testing.T) (*Migrator, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	m, err := New(db, testMigrations)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	return m, db
+}
+
+func TestMigratorUpDownTo(t *testing.T) {
+	m, db := setupMigrator(t)
+
+	if err := m.Up(); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if !db.Migrator().HasTable("a") || !db.Migrator().HasTable("b") {
+		t.Fatalf("expected tables a and b after up")
+	}
+	if pending, err := m.Pending(); err != nil || pending != 0 {
+		t.Fatalf("expected no pending migrations, got %d (err=%v)", pending, err)
+	}
+
+	if err := m.Down(1); err != nil {
+		t.Fatalf("down failed: %v", err)
+	}
+	if db.Migrator().HasTable("b") || !db.Migrator().HasTable("a") {
+		t.Fatalf("expected only table a after down")
+	}
+	statuses, err := m.Status()
+	if err != nil || len(statuses) != 2 || !statuses[0].Applied || statuses[1].Applied {
+		t.Fatalf("unexpected status: %+v (err=%v)", statuses, err)
+	}
+
+	if err := m.To(0); err != nil {
+		t.Fatalf("to 0 failed: %v", err)
+	}
+	if db.Migrator().HasTable("a") {
+		t.Fatalf("expected no tables after migrating to 0")
+	}
+	if err := m.To(2); err != nil || !db.Migrator().HasTable("b") {
+		t.Fatalf("to 2 failed: %v", err)
+	}
+}
+
+func TestLoadRequiresDownScript(t *testing.T) {
+	_, err := Load(fstest.MapFS{"000001_x.up.sql": {Data: []byte("SELECT 1;")}})
+	if err == nil {
+		t.Fatalf("expected error for missing down script")
+	}
+}
+
+// schemaChange matches statements that change the schema, and guarded
+// the ones that can safely run again.
+var (
+	schemaChange = regexp.MustCompile(`(?is)^\s*(?:--[^\n]*\n\s*)*(CREATE|ALTER|DROP|RENAME)\b`)
+	guarded      = regexp.MustCompile(`(?i)\bIF (NOT )?EXISTS\b`)
+)
+
+func TestMySQLScriptsSurviveAutoCommit(t *testing.T) {
+	scripts, err := migrations.For("mysql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := fs.Glob(scripts, "*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		script, err := fs.ReadFile(scripts, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, stmt := range statements(string(script)) {
+			if i > 0 && schemaChange.MatchString(stmt) && !guarded.MatchString(stmt) {
+				t.Errorf("%s: statement %d changes the schema after another statement without IF [NOT] EXISTS: %s", name, i+1, strings.TrimSpace(stmt))
+			}
+		}
+	}
+}
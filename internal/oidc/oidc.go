@@ -0,0 +1,147 @@
+// Package oidc signs users in through an OpenID Connect provider using
+// the authorization code flow with PKCE.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+
+	"book-lending-api/internal/config"
+)
+
+// Identity is what the provider asserts about a user who signed in.
+// Issuer and Subject together name the user's account at the provider
+// and never change; the email address may.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider is an OpenID Connect provider.
+type Provider interface {
+	// AuthCodeURL returns the provider page to send the user to.  The
+	// provider sends them back to the redirect URL with state and a
+	// code.  nonce is echoed in the ID token; the S256 challenge of
+	// verifier binds the code to whoever holds verifier.
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	// Exchange redeems code, checks the ID token it yields, including
+	// that it carries nonce, and returns the identity it asserts.
+	Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error)
+}
+
+// Client is a Provider configured from config.OIDCConfig.  The
+// provider's discovery document is fetched on first use rather than at
+// start-up, so that an unreachable provider does not stop the API from
+// starting; a failed fetch is retried on the next use.
+type Client struct {
+	cfg        config.OIDCConfig
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	provider *gooidc.Provider
+}
+
+// New returns a Client for the provider described by cfg.  httpClient
+// is used for every request to the provider; nil means
+// http.DefaultClient.
+func New(cfg config.OIDCConfig, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{cfg: cfg, httpClient: httpClient}
+}
+
+func (c *Client) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	conf, _, err := c.oauth2Config(ctx)
+	if err != nil {
+		return "", err
+	}
+	return conf.AuthCodeURL(state, gooidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+func (c *Client) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	conf, provider, err := c.oauth2Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx = gooidc.ClientContext(ctx, c.httpClient)
+	token, err := conf.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("oidc: exchanging code: %w", err)
+	}
+	raw, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("oidc: token response has no id_token")
+	}
+	idToken, err := provider.Verifier(&gooidc.Config{ClientID: c.cfg.ClientID}).Verify(ctx, raw)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: verifying ID token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("oidc: ID token nonce does not match")
+	}
+	var claims struct {
+		Email         string    `json:"email"`
+		EmailVerified looseBool `json:"email_verified"`
+		Name          string    `json:"name"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("oidc: reading ID token claims: %w", err)
+	}
+	return &Identity{
+		Issuer:        idToken.Issuer,
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+	}, nil
+}
+
+// oauth2Config discovers the provider if it has not been yet and
+// returns the OAuth 2 configuration for it.
+func (c *Client) oauth2Config(ctx context.Context) (*oauth2.Config, *gooidc.Provider, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.provider == nil {
+		provider, err := gooidc.NewProvider(gooidc.ClientContext(ctx, c.httpClient), c.cfg.IssuerURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("oidc: discovering provider: %w", err)
+		}
+		c.provider = provider
+	}
+	return &oauth2.Config{
+		ClientID:     c.cfg.ClientID,
+		ClientSecret: c.cfg.ClientSecret,
+		RedirectURL:  c.cfg.RedirectURL,
+		Endpoint:     c.provider.Endpoint(),
+		Scopes:       c.cfg.Scopes,
+	}, c.provider, nil
+}
+
+// looseBool accepts the string forms "true" and "false" as well as
+// JSON booleans, since some providers send email_verified as a string.
+type looseBool bool
+
+func (b *looseBool) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case bool:
+		*b = looseBool(v)
+	case string:
+		*b = looseBool(v == "true")
+	}
+	return nil
+}
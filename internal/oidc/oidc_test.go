@@ -0,0 +1,71 @@
+// Unit tests for the OpenID Connect client against a mock provider.
+package oidc
+
+import (
+	"book-lending-api/internal/config"
+	"book-lending-api/internal/oidc/oidctest"
+	"context"
+	"net/url"
+	"testing"
+)
+
+func newTestClient(t *testing.T) (*Client, *oidctest.Provider) {
+	t.Helper()
+	provider := oidctest.NewProvider("library")
+	t.Cleanup(provider.Close)
+	provider.SetUser(oidctest.User{Subject: "u-1", Email: "alice@uni.example", EmailVerified: true, Name: "Alice"})
+	client := New(config.OIDCConfig{
+		IssuerURL:   provider.URL,
+		ClientID:    "library",
+		RedirectURL: "https://api.example.com/api/v1/auth/oidc/callback",
+		Scopes:      []string{"openid", "email", "profile"},
+	}, provider.Client())
+	return client, provider
+}
+
+func TestClientCodeFlow(t *testing.T) {
+	ctx := context.Background()
+	client, provider := newTestClient(t)
+
+	authURL, err := client.AuthCodeURL(ctx, "state-1", "nonce-1", "verifier-verifier-verifier-verifier-verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(authURL)
+	if q := u.Query(); q.Get("code_challenge_method") != "S256" || q.Get("nonce") != "nonce-1" || q.Get("scope") != "openid email profile" {
+		t.Fatalf("unexpected authorization URL %s", authURL)
+	}
+	code, state, err := provider.Authorize(authURL)
+	if err != nil || state != "state-1" {
+		t.Fatalf("unexpected state %q err=%v", state, err)
+	}
+	identity, err := client.Exchange(ctx, code, "verifier-verifier-verifier-verifier-verifier", "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Identity{Issuer: provider.URL, Subject: "u-1", Email: "alice@uni.example", EmailVerified: true, Name: "Alice"}
+	if *identity != want {
+		t.Fatalf("expected %+v, got %+v", want, *identity)
+	}
+	if _, err := client.Exchange(ctx, code, "verifier-verifier-verifier-verifier-verifier", "nonce-1"); err == nil {
+		t.Fatal("expected a code to be redeemable once")
+	}
+}
+
+func TestClientRejectsWrongVerifierAndNonce(t *testing.T) {
+	ctx := context.Background()
+	client, provider := newTestClient(t)
+	verifier := "verifier-verifier-verifier-verifier-verifier"
+
+	authURL, _ := client.AuthCodeURL(ctx, "s", "nonce-1", verifier)
+	code, _, _ := provider.Authorize(authURL)
+	if _, err := client.Exchange(ctx, code, verifier+"x", "nonce-1"); err == nil {
+		t.Fatal("expected a code to need its PKCE verifier")
+	}
+
+	authURL, _ = client.AuthCodeURL(ctx, "s", "nonce-1", verifier)
+	code, _, _ = provider.Authorize(authURL)
+	if _, err := client.Exchange(ctx, code, verifier, "nonce-2"); err == nil {
+		t.Fatal("expected an ID token for another nonce to be refused")
+	}
+}
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// cleanupInterval is how often MemoryStore.Run prunes idle buckets.
+const cleanupInterval = time.Minute
+
+type memoryBucket struct {
+	limiter  *rate.Limiter
+	window   time.Duration
+	lastSeen time.Time
+}
+
+// MemoryStore keeps buckets in process memory.  Limits are enforced
+// per instance, so replicas behind a load balancer each allow the full
+// rate.  Run must be started for idle buckets to be freed.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	now     func() time.Time
+}
+
+// NewMemoryStore constructs an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*memoryBucket), now: time.Now}
+}
+
+// Allow counts one request by key against limit.  It never fails.
+func (s *MemoryStore) Allow(_ context.Context, limit Limit, key string) (Decision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	id := limit.Name + "|" + key
+	b, ok := s.buckets[id]
+	if !ok {
+		b = &memoryBucket{limiter: rate.NewLimiter(rate.Every(limit.Interval), limit.Burst), window: limit.Window()}
+		s.buckets[id] = b
+	}
+	b.lastSeen = now
+
+	d := Decision{Allowed: b.limiter.AllowN(now, 1)}
+	tokens := b.limiter.TokensAt(now)
+	d.Remaining = max(0, int(math.Floor(tokens)))
+	d.Reset = time.Duration((float64(limit.Burst) - tokens) * float64(limit.Interval))
+	if !d.Allowed {
+		d.RetryAfter = time.Duration((1 - tokens) * float64(limit.Interval))
+	}
+	return d, nil
+}
+
+// cleanup removes buckets that have been idle for at least their
+// limit's window.  Such a bucket has refilled completely, so dropping
+// it is indistinguishable from keeping it.
+func (s *MemoryStore) cleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for id, b := range s.buckets {
+		if now.Sub(b.lastSeen) >= b.window {
+			delete(s.buckets, id)
+		}
+	}
+}
+
+// Run periodically frees idle buckets until ctx is cancelled.
+func (s *MemoryStore) Run(ctx context.Context) {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.cleanup()
+		}
+	}
+}
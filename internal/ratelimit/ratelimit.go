@@ -0,0 +1,46 @@
+// Package ratelimit counts requests against token bucket limits.  The
+// buckets live in a Store: in process memory for a single instance, or
+// in Redis so that every replica shares them.
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Limit allows Burst requests at once, refilled at one request per
+// Interval.  Name separates the buckets of different limits that share
+// a store.
+type Limit struct {
+	Name     string
+	Interval time.Duration
+	Burst    int
+}
+
+// PerMinute returns a limit of n requests per minute with the given
+// burst.
+func PerMinute(name string, n, burst int) Limit {
+	return Limit{Name: name, Interval: time.Minute / time.Duration(n), Burst: burst}
+}
+
+// Window is how long an empty bucket takes to refill completely.
+func (l Limit) Window() time.Duration {
+	return l.Interval * time.Duration(l.Burst)
+}
+
+// Decision is the outcome of counting one request against a limit.
+// Reset is how long until the bucket is full again and RetryAfter,
+// set only when the request is refused, how long until the next
+// request would be allowed.
+type Decision struct {
+	Allowed    bool
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Store keeps the buckets for every limit and client key.
+type Store interface {
+	// Allow counts one request by key against limit.
+	Allow(ctx context.Context, limit Limit, key string) (Decision, error)
+}
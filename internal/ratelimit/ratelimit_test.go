@@ -0,0 +1,131 @@
+// Unit tests for the in-memory and Redis rate limit stores.
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// expectSequence counts one request per entry in want against store
+// and checks whether each was allowed.
+func expectSequence(t *testing.T, store Store, limit Limit, key string, want ...bool) Decision {
+	t.Helper()
+	var d Decision
+	for i, allowed := range want {
+		var err error
+		d, err = store.Allow(context.Background(), limit, key)
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		if d.Allowed != allowed {
+			t.Fatalf("request %d: expected allowed=%v, got %+v", i, allowed, d)
+		}
+	}
+	return d
+}
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limit := PerMinute("test", 60, 2)
+
+	d := expectSequence(t, store, limit, "a", true, true, false)
+	if d.Remaining != 0 || d.RetryAfter != time.Second || d.Reset != 2*time.Second {
+		t.Fatalf("unexpected decision %+v", d)
+	}
+	expectSequence(t, store, limit, "b", true)
+
+	now = now.Add(time.Second)
+	expectSequence(t, store, limit, "a", true, false)
+}
+
+func TestMemoryStoreCleanupKeepsActiveBuckets(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limit := PerMinute("test", 60, 2)
+
+	expectSequence(t, store, limit, "idle", true)
+	now = now.Add(time.Second)
+	expectSequence(t, store, limit, "busy", true, true)
+	now = now.Add(time.Second)
+	store.cleanup()
+
+	if _, ok := store.buckets["test|idle"]; ok {
+		t.Error("idle bucket should have been removed")
+	}
+	if _, ok := store.buckets["test|busy"]; !ok {
+		t.Fatal("recently used bucket should have been kept")
+	}
+	// The busy bucket keeps its state: one token has refilled.
+	expectSequence(t, store, limit, "busy", true, false)
+}
+
+func TestMemoryStoreRunStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewMemoryStore().Run(ctx)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
+
+func newRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return mr, client
+}
+
+func TestRedisStoreSharedBetweenInstances(t *testing.T) {
+	mr, client := newRedis(t)
+	now := time.Unix(1_700_000_000, 0)
+	mr.SetTime(now)
+	limit := PerMinute("test", 60, 3)
+
+	// Two stores stand in for two replicas using the same Redis.
+	first, second := NewRedisStore(client), NewRedisStore(client)
+	d := expectSequence(t, first, limit, "a", true)
+	if d.Remaining != 2 || d.Reset != time.Second {
+		t.Fatalf("unexpected first decision %+v", d)
+	}
+	expectSequence(t, second, limit, "a", true, true)
+	d = expectSequence(t, first, limit, "a", false)
+	if d.Remaining != 0 || d.RetryAfter != time.Second || d.Reset != 3*time.Second {
+		t.Fatalf("unexpected refusal %+v", d)
+	}
+	expectSequence(t, second, limit, "b", true)
+
+	mr.SetTime(now.Add(time.Second))
+	expectSequence(t, second, limit, "a", true, false)
+}
+
+func TestRedisStoreKeysExpire(t *testing.T) {
+	mr, client := newRedis(t)
+	store := NewRedisStore(client)
+	expectSequence(t, store, PerMinute("test", 60, 3), "a", true)
+
+	if ttl := mr.TTL(keyPrefix + "test:a"); ttl <= 0 || ttl > time.Second {
+		t.Fatalf("expected the key to expire once refilled, ttl %v", ttl)
+	}
+}
+
+func TestRedisStoreReportsErrors(t *testing.T) {
+	mr, client := newRedis(t)
+	mr.Close()
+	if _, err := NewRedisStore(client).Allow(context.Background(), PerMinute("test", 60, 1), "a"); err == nil {
+		t.Fatal("expected an error when Redis is unavailable")
+	}
+}
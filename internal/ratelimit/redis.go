@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// keyPrefix namespaces rate limit keys in a shared Redis database.
+const keyPrefix = "ratelimit:"
+
+// gcra implements the generic cell rate algorithm.  Each bucket is a
+// single key holding the theoretical arrival time (TAT) of the next
+// request in microseconds; a request is allowed when accepting it
+// would not push the TAT more than burst intervals past now.  Redis's
+// own clock is used so that replicas with skewed clocks agree.
+//
+// KEYS[1] is the bucket, ARGV[1] the burst and ARGV[2] the emission
+// interval in microseconds.  It returns allowed (0 or 1), the
+// remaining requests, and the reset and retry-after delays in
+// microseconds.
+var gcra = redis.NewScript(`
+local burst = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local tolerance = interval * burst
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local tat = tonumber(redis.call("GET", KEYS[1])) or now
+if tat < now then
+  tat = now
+end
+local new_tat = tat + interval
+local allow_at = new_tat - tolerance
+if allow_at > now then
+  return {0, math.floor((tolerance - (tat - now)) / interval), tat - now, allow_at - now}
+end
+redis.call("SET", KEYS[1], string.format("%.0f", new_tat), "PX", math.ceil((new_tat - now) / 1000))
+return {1, math.floor((tolerance - (new_tat - now)) / interval), new_tat - now, 0}
+`)
+
+// RedisStore keeps buckets in Redis so that every instance of the API
+// shares them.  Keys expire once their bucket has refilled, so no
+// cleanup is needed.
+type RedisStore struct {
+	client redis.Scripter
+}
+
+// NewRedisStore returns a store backed by client.
+func NewRedisStore(client redis.Scripter) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+// Allow counts one request by key against limit.
+func (s *RedisStore) Allow(ctx context.Context, limit Limit, key string) (Decision, error) {
+	res, err := gcra.Run(ctx, s.client, []string{keyPrefix + limit.Name + ":" + key},
+		limit.Burst, limit.Interval.Microseconds()).Int64Slice()
+	if err != nil {
+		return Decision{}, fmt.Errorf("rate limit %s: %w", limit.Name, err)
+	}
+	if len(res) != 4 {
+		return Decision{}, fmt.Errorf("rate limit %s: unexpected script result %v", limit.Name, res)
+	}
+	return Decision{
+		Allowed:    res[0] == 1,
+		Remaining:  int(max(0, res[1])),
+		Reset:      time.Duration(res[2]) * time.Microsecond,
+		RetryAfter: time.Duration(res[3]) * time.Microsecond,
+	}, nil
+}
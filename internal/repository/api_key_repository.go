@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"book-lending-api/internal/domain"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKeyRepository stores users' API keys.
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *domain.APIKey) error
+	ListByUser(ctx context.Context, userID uint) ([]domain.APIKey, error)
+	CountByUser(ctx context.Context, userID uint) (int64, error)
+	GetByHash(ctx context.Context, keyHash string) (*domain.APIKey, error)
+	Delete(ctx context.Context, userID, id uint) error
+	DeleteByUser(ctx context.Context, userID uint) (int64, error)
+	Touch(ctx context.Context, id uint, now, staleBefore time.Time) error
+}
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepository returns an implementation of APIKeyRepository
+// backed by a gorm.DB instance.
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, key *domain.APIKey) error {
+	return wrapError(r.db.WithContext(ctx).Create(key).Error)
+}
+
+// ListByUser returns a user's keys, oldest first.
+func (r *apiKeyRepository) ListByUser(ctx context.Context, userID uint) ([]domain.APIKey, error) {
+	var keys []domain.APIKey
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&keys).Error
+	return keys, wrapError(err)
+}
+
+func (r *apiKeyRepository) CountByUser(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&domain.APIKey{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, wrapError(err)
+}
+
+func (r *apiKeyRepository) GetByHash(ctx context.Context, keyHash string) (*domain.APIKey, error) {
+	var key domain.APIKey
+	if err := r.db.WithContext(ctx).Where("key_hash = ?", keyHash).First(&key).Error; err != nil {
+		return nil, wrapError(err)
+	}
+	return &key, nil
+}
+
+// Delete revokes one of a user's keys.  It returns domain.ErrNotFound
+// if the user has no key with that id, so that users cannot probe for
+// other users' keys.
+func (r *apiKeyRepository) Delete(ctx context.Context, userID, id uint) error {
+	res := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&domain.APIKey{})
+	if res.Error != nil {
+		return wrapError(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// DeleteByUser revokes all of a user's keys and returns how many were
+// revoked.
+func (r *apiKeyRepository) DeleteByUser(ctx context.Context, userID uint) (int64, error) {
+	res := r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&domain.APIKey{})
+	return res.RowsAffected, wrapError(res.Error)
+}
+
+// Touch records that a key was used at now, unless it was already
+// recorded as used at or after staleBefore.  Skipping recent uses keeps
+// busy keys from writing to the database on every request.
+func (r *apiKeyRepository) Touch(ctx context.Context, id uint, now, staleBefore time.Time) error {
+	return wrapError(r.db.WithContext(ctx).Model(&domain.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, staleBefore).
+		UpdateColumn("last_used_at", now).Error)
+}
@@ -0,0 +1,69 @@
+// Unit tests for APIKeyRepository using sqlite in-memory
+package repository
+
+import (
+	"book-lending-api/internal/domain"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyRepository(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	users := NewUserRepository(db)
+	repo := NewAPIKeyRepository(db)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	user := &domain.User{Email: "alice@example.com", PasswordHash: "hash"}
+	if err := users.Create(ctx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	key := &domain.APIKey{UserID: user.ID, Name: "backup", Prefix: "blk_abcd", KeyHash: "hash1", Scopes: []string{domain.ScopeLendingRead}}
+	if err := repo.Create(ctx, key); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	got, err := repo.GetByHash(ctx, "hash1")
+	if err != nil || got.ID != key.ID || !slices.Equal(got.Scopes, key.Scopes) {
+		t.Fatalf("unexpected key %+v err=%v", got, err)
+	}
+	if _, err := repo.GetByHash(ctx, "other"); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := repo.Touch(ctx, key.ID, now, now.Add(-time.Minute)); err != nil {
+		t.Fatalf("touch: %v", err)
+	}
+	// A second use within the minute is not recorded.
+	if err := repo.Touch(ctx, key.ID, now.Add(30*time.Second), now.Add(-30*time.Second)); err != nil {
+		t.Fatalf("touch: %v", err)
+	}
+	got, _ = repo.GetByHash(ctx, "hash1")
+	if got.LastUsedAt == nil || !got.LastUsedAt.Equal(now) {
+		t.Fatalf("expected last use at %v, got %v", now, got.LastUsedAt)
+	}
+
+	if n, err := repo.CountByUser(ctx, user.ID); err != nil || n != 1 {
+		t.Fatalf("expected 1 key, got %d err=%v", n, err)
+	}
+	if err := repo.Delete(ctx, user.ID+1, key.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected another user's delete to be refused, got %v", err)
+	}
+	if err := repo.Delete(ctx, user.ID, key.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if keys, err := repo.ListByUser(ctx, user.ID); err != nil || len(keys) != 0 {
+		t.Fatalf("expected no keys, got %+v err=%v", keys, err)
+	}
+
+	for _, hash := range []string{"hash2", "hash3"} {
+		if err := repo.Create(ctx, &domain.APIKey{UserID: user.ID, Name: hash, Prefix: "blk_efgh", KeyHash: hash, Scopes: []string{domain.ScopeLendingRead}}); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	if n, err := repo.DeleteByUser(ctx, user.ID); err != nil || n != 2 {
+		t.Fatalf("expected both keys to be revoked, got %d err=%v", n, err)
+	}
+}
@@ -2,20 +2,22 @@ package repository
 
 import (
 	"book-lending-api/internal/domain"
+	"context"
 
 	"gorm.io/gorm"
 )
 
 // BookRepository abstracts persistence operations for books.
 type BookRepository interface {
-	Create(book *domain.Book) error
-	GetByID(id uint) (*domain.Book, error)
-	GetByISBN(isbn string) (*domain.Book, error)
-	Update(book *domain.Book) error
-	Delete(id uint) error
-	List(offset, limit int) ([]domain.Book, int64, error)
-	GetAvailableQuantity(bookID uint) (int, error)
-	UpdateQuantity(bookID uint, quantity int) error
+	Create(ctx context.Context, book *domain.Book) error
+	GetByID(ctx context.Context, id uint) (*domain.Book, error)
+	GetByISBN(ctx context.Context, isbn string) (*domain.Book, error)
+	Update(ctx context.Context, book *domain.Book) error
+	Delete(ctx context.Context, id uint) error
+	List(ctx context.Context, offset, limit int) ([]domain.Book, int64, error)
+	ListByCursor(ctx context.Context, cursor *domain.Cursor, limit int) ([]domain.Book, bool, error)
+	GetAvailableQuantity(ctx context.Context, bookID uint) (int, error)
+	UpdateQuantity(ctx context.Context, bookID uint, quantity int) error
 }
 
 type bookRepository struct {
@@ -28,67 +30,97 @@ func NewBookRepository(db *gorm.DB) BookRepository {
 	return &bookRepository{db: db}
 }
 
-func (r *bookRepository) Create(book *domain.Book) error {
-	return r.db.Create(book).Error
+func (r *bookRepository) Create(ctx context.Context, book *domain.Book) error {
+	return wrapError(r.db.WithContext(ctx).Create(book).Error)
 }
 
-func (r *bookRepository) GetByID(id uint) (*domain.Book, error) {
+func (r *bookRepository) GetByID(ctx context.Context, id uint) (*domain.Book, error) {
 	var book domain.Book
-	if err := r.db.First(&book, id).Error; err != nil {
-		return nil, err
+	if err := r.db.WithContext(ctx).First(&book, id).Error; err != nil {
+		return nil, wrapError(err)
 	}
 	return &book, nil
 }
 
-func (r *bookRepository) GetByISBN(isbn string) (*domain.Book, error) {
+func (r *bookRepository) GetByISBN(ctx context.Context, isbn string) (*domain.Book, error) {
 	var book domain.Book
-	if err := r.db.Where("isbn = ?", isbn).First(&book).Error; err != nil {
-		return nil, err
+	if err := r.db.WithContext(ctx).Where("isbn = ?", isbn).First(&book).Error; err != nil {
+		return nil, wrapError(err)
 	}
 	return &book, nil
 }
 
-func (r *bookRepository) Update(book *domain.Book) error {
-	return r.db.Save(book).Error
+func (r *bookRepository) Update(ctx context.Context, book *domain.Book) error {
+	return wrapError(r.db.WithContext(ctx).Save(book).Error)
 }
 
-func (r *bookRepository) Delete(id uint) error {
-	return r.db.Delete(&domain.Book{}, id).Error
+func (r *bookRepository) Delete(ctx context.Context, id uint) error {
+	return wrapError(r.db.WithContext(ctx).Delete(&domain.Book{}, id).Error)
 }
 
 // List returns a slice of books along with the total count.  Offset
 // and limit control pagination.
-func (r *bookRepository) List(offset, limit int) ([]domain.Book, int64, error) {
+func (r *bookRepository) List(ctx context.Context, offset, limit int) ([]domain.Book, int64, error) {
 	var books []domain.Book
 	var total int64
-	if err := r.db.Model(&domain.Book{}).Count(&total).Error; err != nil {
-		return nil, 0, err
+	if err := r.db.WithContext(ctx).Model(&domain.Book{}).Count(&total).Error; err != nil {
+		return nil, 0, wrapError(err)
 	}
-	if err := r.db.Offset(offset).Limit(limit).Find(&books).Error; err != nil {
-		return nil, 0, err
+	if err := r.db.WithContext(ctx).Offset(offset).Limit(limit).Find(&books).Error; err != nil {
+		return nil, 0, wrapError(err)
 	}
 	return books, total, nil
 }
 
+// ListByCursor returns up to limit books ordered by id starting after
+// the given cursor.  A nil cursor starts at the beginning.  Results are
+// always returned in ascending order; the boolean reports whether more
+// rows exist beyond the page in the direction of travel.
+func (r *bookRepository) ListByCursor(ctx context.Context, cursor *domain.Cursor, limit int) ([]domain.Book, bool, error) {
+	var books []domain.Book
+	query := r.db.WithContext(ctx).Limit(limit + 1)
+	switch {
+	case cursor == nil:
+		query = query.Order("id ASC")
+	case cursor.Backward:
+		query = query.Where("id < ?", cursor.ID).Order("id DESC")
+	default:
+		query = query.Where("id > ?", cursor.ID).Order("id ASC")
+	}
+	if err := query.Find(&books).Error; err != nil {
+		return nil, false, wrapError(err)
+	}
+	hasMore := len(books) > limit
+	if hasMore {
+		books = books[:limit]
+	}
+	if cursor != nil && cursor.Backward {
+		for i, j := 0, len(books)-1; i < j; i, j = i+1, j-1 {
+			books[i], books[j] = books[j], books[i]
+		}
+	}
+	return books, hasMore, nil
+}
+
 // GetAvailableQuantity calculates the number of books available for
 // borrowing by subtracting the number of active lending records from
 // the total quantity.
-func (r *bookRepository) GetAvailableQuantity(bookID uint) (int, error) {
+func (r *bookRepository) GetAvailableQuantity(ctx context.Context, bookID uint) (int, error) {
 	var book domain.Book
-	if err := r.db.Select("quantity").First(&book, bookID).Error; err != nil {
-		return 0, err
+	if err := r.db.WithContext(ctx).Select("quantity").First(&book, bookID).Error; err != nil {
+		return 0, wrapError(err)
 	}
 	var borrowedCount int64
-	if err := r.db.Model(&domain.LendingRecord{}).
+	if err := r.db.WithContext(ctx).Model(&domain.LendingRecord{}).
 		Where("book_id = ? AND return_date IS NULL", bookID).
 		Count(&borrowedCount).Error; err != nil {
-		return 0, err
+		return 0, wrapError(err)
 	}
 	return book.Quantity - int(borrowedCount), nil
 }
 
 // UpdateQuantity allows adjusting the total quantity for a book.
-func (r *bookRepository) UpdateQuantity(bookID uint, quantity int) error {
-	return r.db.Model(&domain.Book{}).Where("id = ?", bookID).
-		Update("quantity", quantity).Error
+func (r *bookRepository) UpdateQuantity(ctx context.Context, bookID uint, quantity int) error {
+	return wrapError(r.db.WithContext(ctx).Model(&domain.Book{}).Where("id = ?", bookID).
+		Update("quantity", quantity).Error)
 }
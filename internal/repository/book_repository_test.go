@@ -0,0 +1,56 @@
+// Unit tests for BookRepository using sqlite in-memory
+package repository
+
+import (
+	"book-lending-api/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestBookRepositoryListByCursor(t *testing.T) {
+	ctx := context.Background()
+	repo := NewBookRepository(setupTestDB(t))
+	for i := 1; i <= 5; i++ {
+		book := &domain.Book{Title: "T", Author: "A", ISBN: fmt.Sprintf("isbn-%d", i), Quantity: 1, Category: "C"}
+		if err := repo.Create(ctx, book); err != nil {
+			t.Fatalf("create failed: %v", err)
+		}
+	}
+
+	first, more, err := repo.ListByCursor(ctx, nil, 2)
+	if err != nil || !more || len(first) != 2 || first[0].ID != 1 || first[1].ID != 2 {
+		t.Fatalf("unexpected first page: books=%v more=%v err=%v", first, more, err)
+	}
+
+	second, more, err := repo.ListByCursor(ctx, &domain.Cursor{ID: first[1].ID}, 2)
+	if err != nil || !more || len(second) != 2 || second[0].ID != 3 || second[1].ID != 4 {
+		t.Fatalf("unexpected second page: books=%v more=%v err=%v", second, more, err)
+	}
+
+	back, more, err := repo.ListByCursor(ctx, &domain.Cursor{ID: second[0].ID, Backward: true}, 2)
+	if err != nil || more || len(back) != 2 || back[0].ID != 1 || back[1].ID != 2 {
+		t.Fatalf("unexpected previous page: books=%v more=%v err=%v", back, more, err)
+	}
+}
+
+func TestBookRepositoryReportsDuplicateISBN(t *testing.T) {
+	ctx := context.Background()
+	repo := NewBookRepository(setupTestDB(t))
+	first := &domain.Book{Title: "T", Author: "A", ISBN: "isbn-1", Quantity: 1, Category: "C"}
+	second := &domain.Book{Title: "T", Author: "A", ISBN: "isbn-2", Quantity: 1, Category: "C"}
+	for _, book := range []*domain.Book{first, second} {
+		if err := repo.Create(ctx, book); err != nil {
+			t.Fatalf("create failed: %v", err)
+		}
+	}
+
+	if err := repo.Create(ctx, &domain.Book{Title: "T", Author: "A", ISBN: "isbn-1", Quantity: 1, Category: "C"}); !errors.Is(err, domain.ErrConflict) {
+		t.Fatalf("expected ErrConflict on create, got %v", err)
+	}
+	second.ISBN = first.ISBN
+	if err := repo.Update(ctx, second); !errors.Is(err, domain.ErrConflict) {
+		t.Fatalf("expected ErrConflict on update, got %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"book-lending-api/internal/domain"
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// wrapError translates GORM and context errors into domain errors so
+// that callers can test for them with errors.Is without depending on
+// GORM.  A unique constraint violation becomes domain.ErrConflict,
+// which use cases turn into an error naming the clashing field.  The
+// original error remains available through errors.Unwrap.
+func wrapError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return domain.ErrNotFound.WithCause(err)
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return domain.ErrConflict.WithCause(err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return domain.ErrTimeout.WithCause(err)
+	case errors.Is(err, context.Canceled):
+		return domain.ErrRequestCanceled.WithCause(err)
+	default:
+		return err
+	}
+}
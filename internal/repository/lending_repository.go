@@ -2,21 +2,26 @@ package repository
 
 import (
 	"book-lending-api/internal/domain"
+	"context"
 	"time"
 
 	"gorm.io/gorm"
 )
 
 // LendingRepository provides persistence methods for lending records.
-// Implementations are responsible for handling associations.
+// Implementations are responsible for handling associations.  Listing
+// methods take the names of the associations to preload ("book",
+// "user"); a nil slice preloads the book only.
 type LendingRepository interface {
-	Create(record *domain.LendingRecord) error
-	GetByID(id uint) (*domain.LendingRecord, error)
-	GetActiveByUserAndBook(userID, bookID uint) (*domain.LendingRecord, error)
-	Update(record *domain.LendingRecord) error
-	GetUserBorrowingHistory(userID uint, offset, limit int) ([]domain.LendingRecord, int64, error)
-	GetActiveBorrowingsByUser(userID uint) ([]domain.LendingRecord, error)
-	CountUserBorrowsInPeriod(userID uint, since time.Time) (int64, error)
+	Create(ctx context.Context, record *domain.LendingRecord) error
+	GetByID(ctx context.Context, id uint) (*domain.LendingRecord, error)
+	GetActiveByUserAndBook(ctx context.Context, userID, bookID uint) (*domain.LendingRecord, error)
+	Update(ctx context.Context, record *domain.LendingRecord) error
+	GetUserBorrowingHistory(ctx context.Context, userID uint, offset, limit int, include []string) ([]domain.LendingRecord, int64, error)
+	GetUserBorrowingHistoryByCursor(ctx context.Context, userID uint, cursor *domain.Cursor, limit int, include []string) ([]domain.LendingRecord, bool, error)
+	GetActiveBorrowingsByUser(ctx context.Context, userID uint, include []string) ([]domain.LendingRecord, error)
+	CountUserBorrowsInPeriod(ctx context.Context, userID uint, since time.Time) (int64, error)
+	CountActiveBorrowedBefore(ctx context.Context, before time.Time) (int64, error)
 }
 
 type lendingRepository struct {
@@ -29,64 +34,129 @@ func NewLendingRepository(db *gorm.DB) LendingRepository {
 	return &lendingRepository{db: db}
 }
 
-func (r *lendingRepository) Create(record *domain.LendingRecord) error {
-	return r.db.Create(record).Error
+// preload applies the requested association preloads to the query.
+// Unknown names are ignored; callers validate against
+// domain.LendingRecordIncludes.
+func (r *lendingRepository) preload(ctx context.Context, include []string) *gorm.DB {
+	if include == nil {
+		include = []string{"book"}
+	}
+	query := r.db.WithContext(ctx)
+	for _, rel := range include {
+		switch rel {
+		case "book":
+			query = query.Preload("Book")
+		case "user":
+			query = query.Preload("User")
+		}
+	}
+	return query
 }
 
-func (r *lendingRepository) GetByID(id uint) (*domain.LendingRecord, error) {
+func (r *lendingRepository) Create(ctx context.Context, record *domain.LendingRecord) error {
+	return wrapError(r.db.WithContext(ctx).Create(record).Error)
+}
+
+func (r *lendingRepository) GetByID(ctx context.Context, id uint) (*domain.LendingRecord, error) {
 	var record domain.LendingRecord
-	if err := r.db.Preload("Book").Preload("User").First(&record, id).Error; err != nil {
-		return nil, err
+	if err := r.db.WithContext(ctx).Preload("Book").Preload("User").First(&record, id).Error; err != nil {
+		return nil, wrapError(err)
 	}
 	return &record, nil
 }
 
-func (r *lendingRepository) GetActiveByUserAndBook(userID, bookID uint) (*domain.LendingRecord, error) {
+func (r *lendingRepository) GetActiveByUserAndBook(ctx context.Context, userID, bookID uint) (*domain.LendingRecord, error) {
 	var record domain.LendingRecord
-	if err := r.db.Where("user_id = ? AND book_id = ? AND return_date IS NULL", userID, bookID).
+	if err := r.db.WithContext(ctx).Where("user_id = ? AND book_id = ? AND return_date IS NULL", userID, bookID).
 		First(&record).Error; err != nil {
-		return nil, err
+		return nil, wrapError(err)
 	}
 	return &record, nil
 }
 
-func (r *lendingRepository) Update(record *domain.LendingRecord) error {
-	return r.db.Save(record).Error
+func (r *lendingRepository) Update(ctx context.Context, record *domain.LendingRecord) error {
+	return wrapError(r.db.WithContext(ctx).Save(record).Error)
 }
 
-func (r *lendingRepository) GetUserBorrowingHistory(userID uint, offset, limit int) ([]domain.LendingRecord, int64, error) {
+func (r *lendingRepository) GetUserBorrowingHistory(ctx context.Context, userID uint, offset, limit int, include []string) ([]domain.LendingRecord, int64, error) {
 	var records []domain.LendingRecord
 	var total int64
-	if err := r.db.Model(&domain.LendingRecord{}).
+	if err := r.db.WithContext(ctx).Model(&domain.LendingRecord{}).
 		Where("user_id = ?", userID).
 		Count(&total).Error; err != nil {
-		return nil, 0, err
+		return nil, 0, wrapError(err)
 	}
-	if err := r.db.Preload("Book").
+	if err := r.preload(ctx, include).
 		Where("user_id = ?", userID).
 		Order("borrow_date DESC").
 		Offset(offset).Limit(limit).
 		Find(&records).Error; err != nil {
-		return nil, 0, err
+		return nil, 0, wrapError(err)
 	}
 	return records, total, nil
 }
 
-func (r *lendingRepository) GetActiveBorrowingsByUser(userID uint) ([]domain.LendingRecord, error) {
+// GetUserBorrowingHistoryByCursor pages through a user's history newest
+// first using (borrow_date, id) as the keyset.  Records are always
+// returned newest first; the boolean reports whether more rows exist
+// beyond the page in the direction of travel.
+func (r *lendingRepository) GetUserBorrowingHistoryByCursor(ctx context.Context, userID uint, cursor *domain.Cursor, limit int, include []string) ([]domain.LendingRecord, bool, error) {
 	var records []domain.LendingRecord
-	if err := r.db.Preload("Book").Where("user_id = ? AND return_date IS NULL", userID).
+	query := r.preload(ctx, include).Where("user_id = ?", userID).Limit(limit + 1)
+	switch {
+	case cursor == nil:
+		query = query.Order("borrow_date DESC").Order("id DESC")
+	case cursor.Backward:
+		query = query.Where("borrow_date > ? OR (borrow_date = ? AND id > ?)",
+			cursor.Timestamp, cursor.Timestamp, cursor.ID).
+			Order("borrow_date ASC").Order("id ASC")
+	default:
+		query = query.Where("borrow_date < ? OR (borrow_date = ? AND id < ?)",
+			cursor.Timestamp, cursor.Timestamp, cursor.ID).
+			Order("borrow_date DESC").Order("id DESC")
+	}
+	if err := query.Find(&records).Error; err != nil {
+		return nil, false, wrapError(err)
+	}
+	hasMore := len(records) > limit
+	if hasMore {
+		records = records[:limit]
+	}
+	if cursor != nil && cursor.Backward {
+		for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+			records[i], records[j] = records[j], records[i]
+		}
+	}
+	return records, hasMore, nil
+}
+
+func (r *lendingRepository) GetActiveBorrowingsByUser(ctx context.Context, userID uint, include []string) ([]domain.LendingRecord, error) {
+	var records []domain.LendingRecord
+	if err := r.preload(ctx, include).Where("user_id = ? AND return_date IS NULL", userID).
 		Find(&records).Error; err != nil {
-		return nil, err
+		return nil, wrapError(err)
 	}
 	return records, nil
 }
 
-func (r *lendingRepository) CountUserBorrowsInPeriod(userID uint, since time.Time) (int64, error) {
+func (r *lendingRepository) CountUserBorrowsInPeriod(ctx context.Context, userID uint, since time.Time) (int64, error) {
 	var count int64
-	if err := r.db.Model(&domain.LendingRecord{}).
+	if err := r.db.WithContext(ctx).Model(&domain.LendingRecord{}).
 		Where("user_id = ? AND borrow_date >= ?", userID, since).
 		Count(&count).Error; err != nil {
-		return 0, err
+		return 0, wrapError(err)
+	}
+	return count, nil
+}
+
+// CountActiveBorrowedBefore counts unreturned records borrowed before
+// the given time across all users.
+func (r *lendingRepository) CountActiveBorrowedBefore(ctx context.Context, before time.Time) (int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&domain.LendingRecord{}).
+		Where("return_date IS NULL AND borrow_date < ?", before).
+		Count(&count).Error; err != nil {
+		return 0, wrapError(err)
 	}
 	return count, nil
 }
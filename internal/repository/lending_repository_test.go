@@ -0,0 +1,109 @@
+// Unit tests for LendingRepository using sqlite in-memory
+package repository
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/pkg"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLendingRepositoryHistoryByCursor(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	user := &domain.User{Email: "bob@example.com", PasswordHash: "hash"}
+	book := &domain.Book{Title: "T", Author: "A", ISBN: "isbn", Quantity: 5, Category: "C"}
+	if err := NewUserRepository(db).Create(ctx, user); err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	if err := NewBookRepository(db).Create(ctx, book); err != nil {
+		t.Fatalf("create book failed: %v", err)
+	}
+	repo := NewLendingRepository(db)
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		rec := &domain.LendingRecord{BookID: book.ID, UserID: user.ID, BorrowDate: base.Add(time.Duration(i) * time.Hour)}
+		if err := repo.Create(ctx, rec); err != nil {
+			t.Fatalf("create record failed: %v", err)
+		}
+	}
+
+	first, more, err := repo.GetUserBorrowingHistoryByCursor(ctx, user.ID, nil, 2, nil)
+	if err != nil || !more || len(first) != 2 || first[0].ID != 3 || first[0].Book == nil {
+		t.Fatalf("unexpected first page: records=%v more=%v err=%v", first, more, err)
+	}
+	last := first[1]
+	rest, more, err := repo.GetUserBorrowingHistoryByCursor(ctx, user.ID, &domain.Cursor{ID: last.ID, Timestamp: last.BorrowDate}, 2, []string{})
+	if err != nil || more || len(rest) != 1 || rest[0].ID != 1 || rest[0].Book != nil {
+		t.Fatalf("unexpected second page: records=%v more=%v err=%v", rest, more, err)
+	}
+}
+
+// TestLendingRepositoryHistoryByCursorSharedTimestamp pages one record
+// at a time through records borrowed in the same second, passing
+// cursors through CursorCodec as the API does, and expects every
+// record exactly once in each direction.
+func TestLendingRepositoryHistoryByCursorSharedTimestamp(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	user := &domain.User{Email: "bob@example.com", PasswordHash: "hash"}
+	book := &domain.Book{Title: "T", Author: "A", ISBN: "isbn", Quantity: 5, Category: "C"}
+	if err := NewUserRepository(db).Create(ctx, user); err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	if err := NewBookRepository(db).Create(ctx, book); err != nil {
+		t.Fatalf("create book failed: %v", err)
+	}
+	repo := NewLendingRepository(db)
+	borrowed := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		rec := &domain.LendingRecord{BookID: book.ID, UserID: user.ID, BorrowDate: borrowed}
+		if err := repo.Create(ctx, rec); err != nil {
+			t.Fatalf("create record failed: %v", err)
+		}
+	}
+	cursors := pkg.NewCursorCodec("test")
+	// page fetches the page after rec, or before it if backward.  The
+	// cursor is given a finer timestamp than the column keeps, as a
+	// value from time.Now() would have, which encoding must drop.
+	page := func(rec domain.LendingRecord, backward bool) []domain.LendingRecord {
+		t.Helper()
+		ts := rec.BorrowDate.Add(400 * time.Millisecond)
+		token, err := cursors.Encode("history", domain.Cursor{ID: rec.ID, Timestamp: ts, Backward: backward})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cursor, err := cursors.Decode("history", token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, _, err := repo.GetUserBorrowingHistoryByCursor(ctx, user.ID, cursor, 1, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return records
+	}
+
+	records, _, err := repo.GetUserBorrowingHistoryByCursor(ctx, user.ID, nil, 1, nil)
+	if err != nil || len(records) != 1 {
+		t.Fatalf("unexpected first page: records=%v err=%v", records, err)
+	}
+	var seen []uint
+	for len(records) == 1 && len(seen) < 5 {
+		seen = append(seen, records[0].ID)
+		records = page(records[0], false)
+	}
+	if len(seen) != 3 || seen[0] != 3 || seen[1] != 2 || seen[2] != 1 {
+		t.Fatalf("expected records 3, 2, 1 going forward, got %v", seen)
+	}
+	oldest, _ := repo.GetByID(ctx, 1)
+	records, seen = []domain.LendingRecord{*oldest}, nil
+	for len(records) == 1 && len(seen) < 5 {
+		seen = append(seen, records[0].ID)
+		records = page(records[0], true)
+	}
+	if len(seen) != 3 || seen[0] != 1 || seen[1] != 2 || seen[2] != 3 {
+		t.Fatalf("expected records 1, 2, 3 going backward, got %v", seen)
+	}
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"book-lending-api/internal/domain"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginThrottleRepository stores failed login counters.
+type LoginThrottleRepository interface {
+	Get(ctx context.Context, subject string) (*domain.LoginThrottle, error)
+	RecordFailure(ctx context.Context, subject string, now, resetBefore time.Time) (*domain.LoginThrottle, error)
+	Lock(ctx context.Context, subject string, until time.Time) error
+	Delete(ctx context.Context, subject string) error
+	DeleteExpired(ctx context.Context, before time.Time) error
+}
+
+type loginThrottleRepository struct {
+	db *gorm.DB
+}
+
+// NewLoginThrottleRepository returns an implementation of
+// LoginThrottleRepository backed by a gorm.DB instance.
+func NewLoginThrottleRepository(db *gorm.DB) LoginThrottleRepository {
+	return &loginThrottleRepository{db: db}
+}
+
+func (r *loginThrottleRepository) Get(ctx context.Context, subject string) (*domain.LoginThrottle, error) {
+	var throttle domain.LoginThrottle
+	if err := r.db.WithContext(ctx).Where("subject = ?", subject).First(&throttle).Error; err != nil {
+		return nil, wrapError(err)
+	}
+	return &throttle, nil
+}
+
+// RecordFailure counts a failure against subject at now and returns
+// the updated throttle.  The count restarts at one when neither the
+// last failure nor the lockout is after resetBefore.  The count is
+// incremented in a single statement so that concurrent failures are
+// never lost.
+func (r *loginThrottleRepository) RecordFailure(ctx context.Context, subject string, now, resetBefore time.Time) (*domain.LoginThrottle, error) {
+	throttle := domain.LoginThrottle{Subject: subject, Failures: 1, LastFailureAt: now}
+	// Assignments run in order and, on MySQL, see the ones before
+	// them, so failures must be worked out before last_failure_at
+	// changes.
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "subject"}},
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "failures"}, Value: gorm.Expr(
+				"CASE WHEN login_throttles.last_failure_at < ? AND (login_throttles.locked_until IS NULL OR login_throttles.locked_until < ?) THEN 1 ELSE login_throttles.failures + 1 END",
+				resetBefore, resetBefore)},
+			{Column: clause.Column{Name: "last_failure_at"}, Value: now},
+		},
+	}).Create(&throttle).Error
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return r.Get(ctx, subject)
+}
+
+// Lock locks subject until the given time, unless it is already locked
+// for longer.
+func (r *loginThrottleRepository) Lock(ctx context.Context, subject string, until time.Time) error {
+	return wrapError(r.db.WithContext(ctx).Model(&domain.LoginThrottle{}).
+		Where("subject = ? AND (locked_until IS NULL OR locked_until < ?)", subject, until).
+		Update("locked_until", until).Error)
+}
+
+func (r *loginThrottleRepository) Delete(ctx context.Context, subject string) error {
+	return wrapError(r.db.WithContext(ctx).Where("subject = ?", subject).Delete(&domain.LoginThrottle{}).Error)
+}
+
+// DeleteExpired removes throttles whose last failure and lockout are
+// both before the given time, so that the table does not fill up with
+// addresses and unknown emails that never come back.
+func (r *loginThrottleRepository) DeleteExpired(ctx context.Context, before time.Time) error {
+	return wrapError(r.db.WithContext(ctx).
+		Where("last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)", before, before).
+		Delete(&domain.LoginThrottle{}).Error)
+}
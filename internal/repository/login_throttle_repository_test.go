@@ -0,0 +1,112 @@
+// Unit tests for LoginThrottleRepository using sqlite in-memory
+package repository
+
+import (
+	"book-lending-api/internal/domain"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoginThrottleRepositoryRecordFailure(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLoginThrottleRepository(setupTestDB(t))
+	now := time.Now().UTC().Truncate(time.Second)
+	const subject = "ip:10.0.0.1"
+
+	if _, err := repo.Get(ctx, subject); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	for want := 1; want <= 2; want++ {
+		got, err := repo.RecordFailure(ctx, subject, now, now.Add(-time.Minute))
+		if err != nil || got.Failures != want || !got.LastFailureAt.Equal(now) {
+			t.Fatalf("failure %d: unexpected throttle %+v err=%v", want, got, err)
+		}
+	}
+
+	until := now.Add(time.Minute)
+	if err := repo.Lock(ctx, subject, until); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	if err := repo.Lock(ctx, subject, now); err != nil {
+		t.Fatalf("shorter lock failed: %v", err)
+	}
+	got, err := repo.Get(ctx, subject)
+	if err != nil || got.LockedUntil == nil || !got.LockedUntil.Equal(until) {
+		t.Fatalf("expected the longer lock to stand, got %+v err=%v", got, err)
+	}
+
+	// Failures are only forgotten once the lockout is over too.
+	later := now.Add(30 * time.Second)
+	if got, err := repo.RecordFailure(ctx, subject, later, later.Add(-10*time.Second)); err != nil || got.Failures != 3 {
+		t.Fatalf("expected the count to continue during the lockout, got %+v err=%v", got, err)
+	}
+	later = until.Add(time.Hour)
+	if got, err := repo.RecordFailure(ctx, subject, later, later.Add(-time.Minute)); err != nil || got.Failures != 1 {
+		t.Fatalf("expected the count to restart, got %+v err=%v", got, err)
+	}
+
+	if err := repo.Delete(ctx, subject); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, err := repo.Get(ctx, subject); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestLoginThrottleRepositoryRecordFailureConcurrently(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLoginThrottleRepository(setupTestDB(t))
+	now := time.Now().UTC().Truncate(time.Second)
+
+	const attempts = 20
+	var wg sync.WaitGroup
+	for range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := repo.RecordFailure(ctx, "account:alice@example.com", now, now.Add(-time.Minute)); err != nil {
+				t.Errorf("record failure: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	got, err := repo.Get(ctx, "account:alice@example.com")
+	if err != nil || got.Failures != attempts {
+		t.Fatalf("expected %d failures, got %+v err=%v", attempts, got, err)
+	}
+}
+
+func TestLoginThrottleRepositoryDeleteExpired(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLoginThrottleRepository(setupTestDB(t))
+	now := time.Now().UTC().Truncate(time.Second)
+	old := now.Add(-time.Hour)
+
+	for _, subject := range []string{"ip:stale", "ip:locked", "ip:recent"} {
+		at := old
+		if subject == "ip:recent" {
+			at = now
+		}
+		if _, err := repo.RecordFailure(ctx, subject, at, at); err != nil {
+			t.Fatalf("record %s: %v", subject, err)
+		}
+	}
+	if err := repo.Lock(ctx, "ip:locked", now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.DeleteExpired(ctx, now.Add(-time.Minute)); err != nil {
+		t.Fatalf("delete expired failed: %v", err)
+	}
+	if _, err := repo.Get(ctx, "ip:stale"); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected the stale throttle to be deleted, got %v", err)
+	}
+	for _, subject := range []string{"ip:locked", "ip:recent"} {
+		if _, err := repo.Get(ctx, subject); err != nil {
+			t.Fatalf("expected %s to be kept, got %v", subject, err)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"book-lending-api/internal/domain"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MFARepository stores TOTP secrets and recovery codes.
+type MFARepository interface {
+	Get(ctx context.Context, userID uint) (*domain.UserMFA, error)
+	Save(ctx context.Context, mfa *domain.UserMFA) error
+	Delete(ctx context.Context, userID uint) error
+	UseStep(ctx context.Context, userID uint, step int64) error
+	ReplaceRecoveryCodes(ctx context.Context, userID uint, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID uint, codeHash string, now time.Time) error
+}
+
+type mfaRepository struct {
+	db *gorm.DB
+}
+
+// NewMFARepository returns an implementation of MFARepository backed by
+// a gorm.DB instance.
+func NewMFARepository(db *gorm.DB) MFARepository {
+	return &mfaRepository{db: db}
+}
+
+func (r *mfaRepository) Get(ctx context.Context, userID uint) (*domain.UserMFA, error) {
+	var mfa domain.UserMFA
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&mfa).Error; err != nil {
+		return nil, wrapError(err)
+	}
+	return &mfa, nil
+}
+
+// Save inserts the secret or replaces the stored one for its user.
+func (r *mfaRepository) Save(ctx context.Context, mfa *domain.UserMFA) error {
+	return wrapError(r.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(mfa).Error)
+}
+
+// Delete removes a user's secret and recovery codes.
+func (r *mfaRepository) Delete(ctx context.Context, userID uint) error {
+	return wrapError(r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&domain.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&domain.UserMFA{}).Error
+	}))
+}
+
+// UseStep records step as the last one a code was accepted for.  It
+// returns domain.ErrNotFound, without changing anything, unless step is
+// later than the one already recorded, so that concurrent requests
+// cannot both use the same code.
+func (r *mfaRepository) UseStep(ctx context.Context, userID uint, step int64) error {
+	res := r.db.WithContext(ctx).Model(&domain.UserMFA{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if res.Error != nil {
+		return wrapError(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes discards a user's recovery codes, used or not,
+// and stores the given ones.
+func (r *mfaRepository) ReplaceRecoveryCodes(ctx context.Context, userID uint, codeHashes []string) error {
+	codes := make([]domain.MFARecoveryCode, len(codeHashes))
+	for i, h := range codeHashes {
+		codes[i] = domain.MFARecoveryCode{UserID: userID, CodeHash: h}
+	}
+	return wrapError(r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&domain.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	}))
+}
+
+// UseRecoveryCode marks an unused recovery code of a user as used.  It
+// returns domain.ErrNotFound if the user has no such unused code.
+func (r *mfaRepository) UseRecoveryCode(ctx context.Context, userID uint, codeHash string, now time.Time) error {
+	res := r.db.WithContext(ctx).Model(&domain.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", now)
+	if res.Error != nil {
+		return wrapError(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
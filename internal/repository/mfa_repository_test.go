@@ -0,0 +1,65 @@
+// Unit tests for MFARepository using sqlite in-memory
+package repository
+
+import (
+	"book-lending-api/internal/domain"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMFARepository(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	users := NewUserRepository(db)
+	repo := NewMFARepository(db)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	user := &domain.User{Email: "alice@example.com", PasswordHash: "hash"}
+	if err := users.Create(ctx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if _, err := repo.Get(ctx, user.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := repo.Save(ctx, &domain.UserMFA{UserID: user.ID, Secret: "PENDING"}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := repo.Save(ctx, &domain.UserMFA{UserID: user.ID, Secret: "ACTIVE", EnabledAt: &now, LastUsedStep: 10}); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	got, err := repo.Get(ctx, user.ID)
+	if err != nil || got.Secret != "ACTIVE" || !got.Enabled() {
+		t.Fatalf("unexpected secret %+v err=%v", got, err)
+	}
+
+	if err := repo.UseStep(ctx, user.ID, 10); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected the last step to be refused, got %v", err)
+	}
+	if err := repo.UseStep(ctx, user.ID, 11); err != nil {
+		t.Fatalf("use step: %v", err)
+	}
+
+	if err := repo.ReplaceRecoveryCodes(ctx, user.ID, []string{"a", "b"}); err != nil {
+		t.Fatalf("replace codes: %v", err)
+	}
+	if err := repo.UseRecoveryCode(ctx, user.ID, "a", now); err != nil {
+		t.Fatalf("use code: %v", err)
+	}
+	if err := repo.UseRecoveryCode(ctx, user.ID, "a", now); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected a used code to be refused, got %v", err)
+	}
+	if err := repo.UseRecoveryCode(ctx, user.ID+1, "b", now); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected another user's code to be refused, got %v", err)
+	}
+
+	if err := repo.Delete(ctx, user.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	var n int64
+	db.Model(&domain.MFARecoveryCode{}).Count(&n)
+	if _, err := repo.Get(ctx, user.ID); !errors.Is(err, domain.ErrNotFound) || n != 0 {
+		t.Fatalf("expected secret and codes to be gone, err=%v codes=%d", err, n)
+	}
+}
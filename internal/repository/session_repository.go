@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"book-lending-api/internal/domain"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SessionRepository stores users' login sessions.
+type SessionRepository interface {
+	Create(ctx context.Context, session *domain.Session) error
+	ListByUser(ctx context.Context, userID uint, now time.Time) ([]domain.Session, error)
+	Get(ctx context.Context, userID, id uint, now time.Time) (*domain.Session, error)
+	Delete(ctx context.Context, userID, id uint) error
+	DeleteOthers(ctx context.Context, userID, keepID uint) (int64, error)
+	DeleteByUser(ctx context.Context, userID uint) (int64, error)
+	DeleteExpired(ctx context.Context, userID uint, now time.Time) error
+	Touch(ctx context.Context, id uint, ip string, now, staleBefore time.Time) error
+}
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository returns an implementation of SessionRepository
+// backed by a gorm.DB instance.
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+func (r *sessionRepository) Create(ctx context.Context, session *domain.Session) error {
+	return wrapError(r.db.WithContext(ctx).Create(session).Error)
+}
+
+// ListByUser returns a user's unexpired sessions, most recently used
+// first.
+func (r *sessionRepository) ListByUser(ctx context.Context, userID uint, now time.Time) ([]domain.Session, error) {
+	var sessions []domain.Session
+	err := r.db.WithContext(ctx).Where("user_id = ? AND expires_at > ?", userID, now).
+		Order("last_seen_at DESC, id DESC").Find(&sessions).Error
+	return sessions, wrapError(err)
+}
+
+// Get returns one of a user's sessions.  Revoked and expired sessions,
+// and sessions of other users, yield domain.ErrNotFound.
+func (r *sessionRepository) Get(ctx context.Context, userID, id uint, now time.Time) (*domain.Session, error) {
+	var session domain.Session
+	err := r.db.WithContext(ctx).Where("id = ? AND user_id = ? AND expires_at > ?", id, userID, now).First(&session).Error
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &session, nil
+}
+
+// Delete revokes one of a user's sessions.  It returns
+// domain.ErrNotFound if the user has no session with that id.
+func (r *sessionRepository) Delete(ctx context.Context, userID, id uint) error {
+	res := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&domain.Session{})
+	if res.Error != nil {
+		return wrapError(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// DeleteOthers revokes all of a user's sessions except keepID and
+// returns how many were revoked.
+func (r *sessionRepository) DeleteOthers(ctx context.Context, userID, keepID uint) (int64, error) {
+	res := r.db.WithContext(ctx).Where("user_id = ? AND id <> ?", userID, keepID).Delete(&domain.Session{})
+	return res.RowsAffected, wrapError(res.Error)
+}
+
+// DeleteByUser revokes all of a user's sessions and returns how many
+// were revoked.
+func (r *sessionRepository) DeleteByUser(ctx context.Context, userID uint) (int64, error) {
+	res := r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&domain.Session{})
+	return res.RowsAffected, wrapError(res.Error)
+}
+
+// DeleteExpired removes a user's sessions that expired by now.
+func (r *sessionRepository) DeleteExpired(ctx context.Context, userID uint, now time.Time) error {
+	return wrapError(r.db.WithContext(ctx).Where("user_id = ? AND expires_at <= ?", userID, now).Delete(&domain.Session{}).Error)
+}
+
+// Touch records that a session was used at now from ip, unless it was
+// already recorded as used at or after staleBefore from the same
+// address.  Skipping recent uses keeps busy sessions from writing to
+// the database on every request.
+func (r *sessionRepository) Touch(ctx context.Context, id uint, ip string, now, staleBefore time.Time) error {
+	return wrapError(r.db.WithContext(ctx).Model(&domain.Session{}).
+		Where("id = ? AND (last_seen_at < ? OR ip <> ?)", id, staleBefore, ip).
+		UpdateColumns(map[string]any{"last_seen_at": now, "ip": ip}).Error)
+}
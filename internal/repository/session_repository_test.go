@@ -0,0 +1,79 @@
+// Unit tests for SessionRepository using sqlite in-memory
+package repository
+
+import (
+	"book-lending-api/internal/domain"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSessionRepository(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	users := NewUserRepository(db)
+	repo := NewSessionRepository(db)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	user := &domain.User{Email: "alice@example.com", PasswordHash: "hash"}
+	if err := users.Create(ctx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	newSession := func(device string, expiresAt time.Time) *domain.Session {
+		t.Helper()
+		s := &domain.Session{UserID: user.ID, Device: device, IP: "192.0.2.1", UserAgent: "test", LastSeenAt: now.Add(-time.Hour), ExpiresAt: expiresAt}
+		if err := repo.Create(ctx, s); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		return s
+	}
+	laptop := newSession("laptop", now.Add(time.Hour))
+	phone := newSession("phone", now.Add(time.Hour))
+	tablet := newSession("tablet", now.Add(time.Hour))
+	stale := newSession("stale", now)
+
+	if _, err := repo.Get(ctx, user.ID, stale.ID, now); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected an expired session to be gone, got %v", err)
+	}
+	if _, err := repo.Get(ctx, user.ID+1, laptop.ID, now); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected another user's session to be hidden, got %v", err)
+	}
+
+	if err := repo.Touch(ctx, phone.ID, "192.0.2.9", now, now.Add(-time.Minute)); err != nil {
+		t.Fatalf("touch: %v", err)
+	}
+	// A second use within the minute from the same address is not recorded.
+	if err := repo.Touch(ctx, phone.ID, "192.0.2.9", now.Add(30*time.Second), now.Add(-30*time.Second)); err != nil {
+		t.Fatalf("touch: %v", err)
+	}
+	got, err := repo.Get(ctx, user.ID, phone.ID, now)
+	if err != nil || !got.LastSeenAt.Equal(now) || got.IP != "192.0.2.9" {
+		t.Fatalf("expected the phone seen at %v from 192.0.2.9, got %+v err=%v", now, got, err)
+	}
+
+	sessions, err := repo.ListByUser(ctx, user.ID, now)
+	if err != nil || len(sessions) != 3 || sessions[0].ID != phone.ID {
+		t.Fatalf("expected three sessions, most recent first, got %+v err=%v", sessions, err)
+	}
+
+	if err := repo.DeleteExpired(ctx, user.ID, now); err != nil {
+		t.Fatalf("delete expired: %v", err)
+	}
+	if err := repo.Delete(ctx, user.ID+1, tablet.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected another user's delete to be refused, got %v", err)
+	}
+	if err := repo.Delete(ctx, user.ID, tablet.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if n, err := repo.DeleteOthers(ctx, user.ID, laptop.ID); err != nil || n != 1 {
+		t.Fatalf("expected the phone to be revoked, got %d err=%v", n, err)
+	}
+	sessions, err = repo.ListByUser(ctx, user.ID, now.Add(-time.Hour))
+	if err != nil || len(sessions) != 1 || sessions[0].ID != laptop.ID {
+		t.Fatalf("expected only the laptop to remain, got %+v err=%v", sessions, err)
+	}
+	if n, err := repo.DeleteByUser(ctx, user.ID); err != nil || n != 1 {
+		t.Fatalf("expected the laptop to be revoked, got %d err=%v", n, err)
+	}
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"book-lending-api/internal/domain"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SSORepository stores pending single sign-on attempts and the
+// provider accounts linked to users.
+type SSORepository interface {
+	CreateLogin(ctx context.Context, login *domain.SSOLogin) error
+	ConsumeLogin(ctx context.Context, stateHash string, now time.Time) (*domain.SSOLogin, error)
+	DeleteExpiredLogins(ctx context.Context, now time.Time) error
+	GetIdentity(ctx context.Context, issuer, subject string) (*domain.UserIdentity, error)
+	CreateIdentity(ctx context.Context, identity *domain.UserIdentity) error
+}
+
+type ssoRepository struct {
+	db *gorm.DB
+}
+
+// NewSSORepository returns an implementation of SSORepository backed by
+// a gorm.DB instance.
+func NewSSORepository(db *gorm.DB) SSORepository {
+	return &ssoRepository{db: db}
+}
+
+func (r *ssoRepository) CreateLogin(ctx context.Context, login *domain.SSOLogin) error {
+	return wrapError(r.db.WithContext(ctx).Create(login).Error)
+}
+
+// ConsumeLogin deletes the unexpired attempt with the given state hash
+// and returns it.  The delete decides which of two concurrent callbacks
+// wins; the loser gets domain.ErrNotFound.
+func (r *ssoRepository) ConsumeLogin(ctx context.Context, stateHash string, now time.Time) (*domain.SSOLogin, error) {
+	var login domain.SSOLogin
+	err := r.db.WithContext(ctx).Where("state_hash = ? AND expires_at > ?", stateHash, now).First(&login).Error
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	res := r.db.WithContext(ctx).Delete(&domain.SSOLogin{}, login.ID)
+	if res.Error != nil {
+		return nil, wrapError(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, domain.ErrNotFound
+	}
+	return &login, nil
+}
+
+// DeleteExpiredLogins removes attempts the user never came back from.
+func (r *ssoRepository) DeleteExpiredLogins(ctx context.Context, now time.Time) error {
+	return wrapError(r.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&domain.SSOLogin{}).Error)
+}
+
+func (r *ssoRepository) GetIdentity(ctx context.Context, issuer, subject string) (*domain.UserIdentity, error) {
+	var identity domain.UserIdentity
+	if err := r.db.WithContext(ctx).Where("issuer = ? AND subject = ?", issuer, subject).First(&identity).Error; err != nil {
+		return nil, wrapError(err)
+	}
+	return &identity, nil
+}
+
+func (r *ssoRepository) CreateIdentity(ctx context.Context, identity *domain.UserIdentity) error {
+	return wrapError(r.db.WithContext(ctx).Create(identity).Error)
+}
@@ -0,0 +1,62 @@
+// Unit tests for SSORepository using sqlite in-memory
+package repository
+
+import (
+	"book-lending-api/internal/domain"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSSORepository(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	users := NewUserRepository(db)
+	repo := NewSSORepository(db)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	fresh := &domain.SSOLogin{StateHash: "fresh", Nonce: "n", CodeVerifier: "v", ExpiresAt: now.Add(time.Minute)}
+	stale := &domain.SSOLogin{StateHash: "stale", Nonce: "n", CodeVerifier: "v", ExpiresAt: now.Add(-time.Minute)}
+	for _, login := range []*domain.SSOLogin{fresh, stale} {
+		if err := repo.CreateLogin(ctx, login); err != nil {
+			t.Fatalf("create login: %v", err)
+		}
+	}
+	if _, err := repo.ConsumeLogin(ctx, "stale", now); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected an expired attempt to be refused, got %v", err)
+	}
+	got, err := repo.ConsumeLogin(ctx, "fresh", now)
+	if err != nil || got.Nonce != "n" || got.CodeVerifier != "v" {
+		t.Fatalf("unexpected attempt %+v err=%v", got, err)
+	}
+	if _, err := repo.ConsumeLogin(ctx, "fresh", now); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected an attempt to be usable once, got %v", err)
+	}
+	if err := repo.DeleteExpiredLogins(ctx, now); err != nil {
+		t.Fatalf("delete expired: %v", err)
+	}
+	var n int64
+	db.Model(&domain.SSOLogin{}).Count(&n)
+	if n != 0 {
+		t.Fatalf("expected no attempts left, found %d", n)
+	}
+
+	user := &domain.User{Email: "alice@example.com", PasswordHash: "hash"}
+	if err := users.Create(ctx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := repo.CreateIdentity(ctx, &domain.UserIdentity{UserID: user.ID, Issuer: "https://idp", Subject: "123"}); err != nil {
+		t.Fatalf("create identity: %v", err)
+	}
+	if err := repo.CreateIdentity(ctx, &domain.UserIdentity{UserID: user.ID, Issuer: "https://idp", Subject: "123"}); err == nil {
+		t.Fatal("expected a provider account to be linked only once")
+	}
+	identity, err := repo.GetIdentity(ctx, "https://idp", "123")
+	if err != nil || identity.UserID != user.ID {
+		t.Fatalf("unexpected identity %+v err=%v", identity, err)
+	}
+	if _, err := repo.GetIdentity(ctx, "https://other", "123"); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -2,6 +2,10 @@ package repository
 
 import (
 	"book-lending-api/internal/domain"
+	"context"
+	"errors"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -10,9 +14,18 @@ import (
 // implemented against GORM for MySQL but could be swapped out for
 // another backend if required.
 type UserRepository interface {
-	Create(user *domain.User) error
-	GetByEmail(email string) (*domain.User, error)
-	GetByID(id uint) (*domain.User, error)
+	Create(ctx context.Context, user *domain.User) error
+	GetByEmail(ctx context.Context, email string) (*domain.User, error)
+	GetByID(ctx context.Context, id uint) (*domain.User, error)
+	UpdateRole(ctx context.Context, id uint, role string) error
+	UpdatePassword(ctx context.Context, id uint, passwordHash string) error
+	MarkEmailVerified(ctx context.Context, id uint, at time.Time) error
+	UpdateProfile(ctx context.Context, user *domain.User) error
+	UpdateEmail(ctx context.Context, id uint, email string) error
+	Delete(ctx context.Context, id uint) error
+	List(ctx context.Context, filter domain.UserFilter, offset, limit int) ([]domain.User, int64, error)
+	SetSuspended(ctx context.Context, id uint, at *time.Time) error
+	ResetBorrowLimit(ctx context.Context, id uint, at time.Time) error
 }
 
 type userRepository struct {
@@ -25,22 +38,136 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 	return &userRepository{db: db}
 }
 
-func (r *userRepository) Create(user *domain.User) error {
-	return r.db.Create(user).Error
+// Create stores a new user, with its email normalized.
+func (r *userRepository) Create(ctx context.Context, user *domain.User) error {
+	user.Email = domain.NormalizeEmail(user.Email)
+	return wrapError(r.db.WithContext(ctx).Create(user).Error)
 }
 
-func (r *userRepository) GetByEmail(email string) (*domain.User, error) {
+// GetByEmail finds a user by email address, in any case.
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
 	var user domain.User
-	if err := r.db.Where("email = ?", email).First(&user).Error; err != nil {
-		return nil, err
+	if err := r.db.WithContext(ctx).Where("email = ?", domain.NormalizeEmail(email)).First(&user).Error; err != nil {
+		return nil, wrapError(err)
 	}
 	return &user, nil
 }
 
-func (r *userRepository) GetByID(id uint) (*domain.User, error) {
+func (r *userRepository) GetByID(ctx context.Context, id uint) (*domain.User, error) {
 	var user domain.User
-	if err := r.db.First(&user, id).Error; err != nil {
-		return nil, err
+	if err := r.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		return nil, wrapError(err)
 	}
 	return &user, nil
 }
+
+func (r *userRepository) UpdateRole(ctx context.Context, id uint, role string) error {
+	return r.updateColumn(ctx, id, "role", role)
+}
+
+func (r *userRepository) UpdatePassword(ctx context.Context, id uint, passwordHash string) error {
+	return r.updateColumn(ctx, id, "password_hash", passwordHash)
+}
+
+func (r *userRepository) MarkEmailVerified(ctx context.Context, id uint, at time.Time) error {
+	return r.updateColumn(ctx, id, "email_verified_at", at)
+}
+
+// UpdateProfile saves the name, phone and preferences of user.
+func (r *userRepository) UpdateProfile(ctx context.Context, user *domain.User) error {
+	res := r.db.WithContext(ctx).Model(user).Select("name", "phone", "preferences").Updates(user)
+	if res.Error != nil {
+		return wrapError(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// UpdateEmail changes a user's email address and marks it unverified.
+// It returns domain.ErrEmailTaken if another user has the address,
+// which callers may have checked for already but can lose a race on.
+func (r *userRepository) UpdateEmail(ctx context.Context, id uint, email string) error {
+	res := r.db.WithContext(ctx).Model(&domain.User{}).Where("id = ?", id).
+		Updates(map[string]any{"email": domain.NormalizeEmail(email), "email_verified_at": nil})
+	if err := wrapError(res.Error); errors.Is(err, domain.ErrConflict) {
+		return domain.ErrEmailTaken.WithCause(err)
+	} else if err != nil {
+		return err
+	}
+	if res.RowsAffected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// Delete removes a user.  Their lending records, tokens and two-factor
+// settings go with them through the foreign keys.
+func (r *userRepository) Delete(ctx context.Context, id uint) error {
+	res := r.db.WithContext(ctx).Delete(&domain.User{}, id)
+	if res.Error != nil {
+		return wrapError(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// likeEscaper escapes LIKE wildcards with "!", which every supported
+// database accepts as an ESCAPE character.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// List returns the users matching filter, ordered by id, along with the
+// total number of matches.  Offset and limit control pagination.
+func (r *userRepository) List(ctx context.Context, filter domain.UserFilter, offset, limit int) ([]domain.User, int64, error) {
+	query := r.db.WithContext(ctx).Model(&domain.User{})
+	if filter.Query != "" {
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(filter.Query)) + "%"
+		query = query.Where("LOWER(email) LIKE ? ESCAPE '!' OR LOWER(name) LIKE ? ESCAPE '!'", pattern, pattern)
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	switch filter.Status {
+	case domain.UserStatusActive:
+		query = query.Where("suspended_at IS NULL")
+	case domain.UserStatusSuspended:
+		query = query.Where("suspended_at IS NOT NULL")
+	case domain.UserStatusUnverified:
+		query = query.Where("email_verified_at IS NULL")
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, wrapError(err)
+	}
+	var users []domain.User
+	if err := query.Order("id").Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+		return nil, 0, wrapError(err)
+	}
+	return users, total, nil
+}
+
+// SetSuspended suspends a user as of at, or lifts the suspension when
+// at is nil.
+func (r *userRepository) SetSuspended(ctx context.Context, id uint, at *time.Time) error {
+	return r.updateColumn(ctx, id, "suspended_at", at)
+}
+
+func (r *userRepository) ResetBorrowLimit(ctx context.Context, id uint, at time.Time) error {
+	return r.updateColumn(ctx, id, "borrow_limit_reset_at", at)
+}
+
+// updateColumn sets one column of a user, returning domain.ErrNotFound
+// when no such user exists.
+func (r *userRepository) updateColumn(ctx context.Context, id uint, column string, value any) error {
+	res := r.db.WithContext(ctx).Model(&domain.User{}).Where("id = ?", id).Update(column, value)
+	if res.Error != nil {
+		return wrapError(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
@@ -2,45 +2,197 @@
 package repository
 
 import (
+	"book-lending-api/internal/config"
+	"book-lending-api/internal/database"
 	"book-lending-api/internal/domain"
+	"book-lending-api/internal/migrate"
+	"book-lending-api/migrations"
+	"context"
+	"errors"
+	"slices"
 	"testing"
+	"time"
 
-	"github.com/glebarez/sqlite"
 	"gorm.io/gorm"
 )
 
-// setupTestDB prepares an in-memory sqlite database and migrates schema
+// setupTestDB prepares an in-memory sqlite database and applies the
+// sqlite migration set
 func setupTestDB(t *testing.T) *gorm.DB {
 	t.Helper()
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	db, err := database.Open(config.DatabaseConfig{Driver: "sqlite", Path: ":memory:"})
 	if err != nil {
 		t.Fatalf("failed to open sqlite: %v", err)
 	}
-	if err := db.AutoMigrate(&domain.User{}); err != nil {
+	scripts, err := migrations.For("sqlite")
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	m, err := migrate.New(db, scripts)
+	if err == nil {
+		err = m.Up()
+	}
+	if err != nil {
 		t.Fatalf("failed to migrate: %v", err)
 	}
 	return db
 }
 
 func TestUserRepositoryCRUD(t *testing.T) {
+	ctx := context.Background()
 	db := setupTestDB(t)
 	repo := NewUserRepository(db)
 
 	user := &domain.User{Email: "alice@example.com", PasswordHash: "hash"}
-	if err := repo.Create(user); err != nil {
+	if err := repo.Create(ctx, user); err != nil {
 		t.Fatalf("create failed: %v", err)
 	}
 	if user.ID == 0 {
 		t.Fatalf("expected ID to be set after create")
 	}
 
-	gotByEmail, err := repo.GetByEmail("alice@example.com")
+	gotByEmail, err := repo.GetByEmail(ctx, "alice@example.com")
 	if err != nil || gotByEmail == nil || gotByEmail.Email != user.Email {
 		t.Fatalf("get by email failed: user=%v err=%v", gotByEmail, err)
 	}
 
-	gotByID, err := repo.GetByID(user.ID)
+	gotByID, err := repo.GetByID(ctx, user.ID)
 	if err != nil || gotByID == nil || gotByID.Email != user.Email {
 		t.Fatalf("get by id failed: user=%v err=%v", gotByID, err)
 	}
+	if gotByID.Role != domain.RoleMember {
+		t.Fatalf("expected new users to default to member, got %q", gotByID.Role)
+	}
+	if gotByID.EmailVerified() {
+		t.Fatal("expected new users to be unverified")
+	}
+
+	verifiedAt := time.Now().UTC().Truncate(time.Second)
+	if err := repo.MarkEmailVerified(ctx, user.ID, verifiedAt); err != nil {
+		t.Fatalf("mark verified failed: %v", err)
+	}
+	if got, _ := repo.GetByID(ctx, user.ID); got.EmailVerifiedAt == nil || !got.EmailVerifiedAt.Equal(verifiedAt) {
+		t.Fatalf("expected verified at %v, got %v", verifiedAt, got.EmailVerifiedAt)
+	}
+
+	if err := repo.UpdateRole(ctx, user.ID, domain.RoleAdmin); err != nil {
+		t.Fatalf("update role failed: %v", err)
+	}
+	if got, _ := repo.GetByID(ctx, user.ID); got.Role != domain.RoleAdmin {
+		t.Fatalf("expected admin role, got %q", got.Role)
+	}
+	if err := repo.UpdateRole(ctx, 999, domain.RoleAdmin); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	profile := &domain.User{ID: user.ID, Name: "Alice", Phone: "+6281234567890", Preferences: domain.UserPreferences{Language: "id"}}
+	if err := repo.UpdateProfile(ctx, profile); err != nil {
+		t.Fatalf("update profile failed: %v", err)
+	}
+	if got, _ := repo.GetByID(ctx, user.ID); got.Name != "Alice" || got.Phone != profile.Phone || got.Preferences.Language != "id" || got.Role != domain.RoleAdmin {
+		t.Fatalf("unexpected user after profile update: %+v", got)
+	}
+
+	if err := repo.Create(ctx, &domain.User{Email: " Bob@Example.com", PasswordHash: "hash"}); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if got, err := repo.GetByEmail(ctx, "BOB@example.com"); err != nil || got.Email != "bob@example.com" {
+		t.Fatalf("expected a normalized address found in any case, got %+v err=%v", got, err)
+	}
+	if err := repo.Create(ctx, &domain.User{Email: "bob@example.COM", PasswordHash: "hash"}); !errors.Is(err, domain.ErrConflict) {
+		t.Fatalf("expected ErrConflict for the same address in another case, got %v", err)
+	}
+	if err := repo.UpdateEmail(ctx, user.ID, "Bob@example.com"); !errors.Is(err, domain.ErrEmailTaken) {
+		t.Fatalf("expected ErrEmailTaken, got %v", err)
+	}
+	if err := repo.UpdateEmail(ctx, user.ID, "Alice@Example.org"); err != nil {
+		t.Fatalf("update email failed: %v", err)
+	}
+	if got, _ := repo.GetByID(ctx, user.ID); got.Email != "alice@example.org" || got.EmailVerified() {
+		t.Fatalf("expected an unverified new address, got %+v", got)
+	}
+
+	if err := repo.Delete(ctx, user.ID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, err := repo.GetByID(ctx, user.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+	if err := repo.Delete(ctx, user.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound deleting twice, got %v", err)
+	}
+}
+
+func TestUserRepositoryList(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository(setupTestDB(t))
+	for _, u := range []*domain.User{
+		{Email: "alice@example.com", Name: "Alice Liddell"},
+		{Email: "bob@example.com", Name: "Bob 100%"},
+		{Email: "carol@example.com", Name: "Carol", Role: domain.RoleLibrarian},
+	} {
+		if err := repo.Create(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.SetSuspended(ctx, 1, &time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.MarkEmailVerified(ctx, 2, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter domain.UserFilter
+		want   []uint
+	}{
+		{"all", domain.UserFilter{}, []uint{1, 2, 3}},
+		{"email or name, any case", domain.UserFilter{Query: "LIDDELL"}, []uint{1}},
+		{"wildcards are literal", domain.UserFilter{Query: "%"}, []uint{2}},
+		{"search and role", domain.UserFilter{Query: "example", Role: domain.RoleLibrarian}, []uint{3}},
+		{"suspended", domain.UserFilter{Status: domain.UserStatusSuspended}, []uint{1}},
+		{"active", domain.UserFilter{Status: domain.UserStatusActive}, []uint{2, 3}},
+		{"unverified", domain.UserFilter{Status: domain.UserStatusUnverified}, []uint{1, 3}},
+	}
+	for _, tt := range tests {
+		users, total, err := repo.List(ctx, tt.filter, 0, 10)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var got []uint
+		for _, u := range users {
+			got = append(got, u.ID)
+		}
+		if total != int64(len(tt.want)) || !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %v (total %d), want %v", tt.name, got, total, tt.want)
+		}
+	}
+
+	users, total, err := repo.List(ctx, domain.UserFilter{}, 1, 1)
+	if err != nil || total != 3 || len(users) != 1 || users[0].ID != 2 {
+		t.Fatalf("unexpected second page %+v total=%d err=%v", users, total, err)
+	}
+	if err := repo.SetSuspended(ctx, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := repo.GetByID(ctx, 1); got.Suspended() {
+		t.Fatal("expected the suspension to be lifted")
+	}
+}
+
+func TestRepositoryHonoursContext(t *testing.T) {
+	repo := NewUserRepository(setupTestDB(t))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := repo.GetByEmail(ctx, "alice@example.com"); !errors.Is(err, domain.ErrRequestCanceled) {
+		t.Fatalf("expected ErrRequestCanceled, got %v", err)
+	}
+
+	ctx, cancel = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	if _, err := repo.GetByID(ctx, 1); !errors.Is(err, domain.ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
 }
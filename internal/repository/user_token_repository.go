@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"book-lending-api/internal/domain"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserTokenRepository stores single-use user tokens.
+type UserTokenRepository interface {
+	Create(ctx context.Context, token *domain.UserToken) error
+	Consume(ctx context.Context, purpose, tokenHash string, now time.Time) (*domain.UserToken, error)
+	DeleteForUser(ctx context.Context, userID uint, purpose string) error
+}
+
+type userTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewUserTokenRepository returns an implementation of
+// UserTokenRepository backed by a gorm.DB instance.
+func NewUserTokenRepository(db *gorm.DB) UserTokenRepository {
+	return &userTokenRepository{db: db}
+}
+
+func (r *userTokenRepository) Create(ctx context.Context, token *domain.UserToken) error {
+	return wrapError(r.db.WithContext(ctx).Create(token).Error)
+}
+
+// Consume marks the unused, unexpired token with the given purpose and
+// hash as used and returns it.  The update is conditional so that two
+// concurrent requests cannot both consume the same token; the loser
+// gets domain.ErrNotFound.
+func (r *userTokenRepository) Consume(ctx context.Context, purpose, tokenHash string, now time.Time) (*domain.UserToken, error) {
+	var token domain.UserToken
+	err := r.db.WithContext(ctx).
+		Where("purpose = ? AND token_hash = ? AND used_at IS NULL AND expires_at > ?", purpose, tokenHash, now).
+		First(&token).Error
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	res := r.db.WithContext(ctx).Model(&domain.UserToken{}).
+		Where("id = ? AND used_at IS NULL", token.ID).
+		Update("used_at", now)
+	if res.Error != nil {
+		return nil, wrapError(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, domain.ErrNotFound
+	}
+	token.UsedAt = &now
+	return &token, nil
+}
+
+// DeleteForUser removes every token of the given purpose issued to a
+// user.
+func (r *userTokenRepository) DeleteForUser(ctx context.Context, userID uint, purpose string) error {
+	return wrapError(r.db.WithContext(ctx).
+		Where("user_id = ? AND purpose = ?", userID, purpose).
+		Delete(&domain.UserToken{}).Error)
+}
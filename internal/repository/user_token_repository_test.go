@@ -0,0 +1,54 @@
+// Unit tests for UserTokenRepository using sqlite in-memory
+package repository
+
+import (
+	"book-lending-api/internal/domain"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUserTokenRepositoryConsume(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	users := NewUserRepository(db)
+	repo := NewUserTokenRepository(db)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	user := &domain.User{Email: "alice@example.com", PasswordHash: "hash"}
+	if err := users.Create(ctx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	for _, tok := range []*domain.UserToken{
+		{UserID: user.ID, Purpose: domain.TokenPasswordReset, TokenHash: "live", ExpiresAt: now.Add(time.Hour), CreatedAt: now},
+		{UserID: user.ID, Purpose: domain.TokenPasswordReset, TokenHash: "stale", ExpiresAt: now.Add(-time.Second), CreatedAt: now},
+	} {
+		if err := repo.Create(ctx, tok); err != nil {
+			t.Fatalf("create token: %v", err)
+		}
+	}
+
+	got, err := repo.Consume(ctx, domain.TokenPasswordReset, "live", now)
+	if err != nil || got.UserID != user.ID || got.UsedAt == nil {
+		t.Fatalf("unexpected token %+v err=%v", got, err)
+	}
+	if _, err := repo.Consume(ctx, domain.TokenPasswordReset, "live", now); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected a used token to be rejected, got %v", err)
+	}
+	if _, err := repo.Consume(ctx, domain.TokenPasswordReset, "stale", now); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected an expired token to be rejected, got %v", err)
+	}
+	if _, err := repo.Consume(ctx, "other", "live", now); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected the purpose to be checked, got %v", err)
+	}
+
+	if err := repo.DeleteForUser(ctx, user.ID, domain.TokenPasswordReset); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	var n int64
+	db.Model(&domain.UserToken{}).Count(&n)
+	if n != 0 {
+		t.Fatalf("expected no tokens left, found %d", n)
+	}
+}
@@ -0,0 +1,61 @@
+// Unit tests for the totp package.
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 test key from RFC 6238, "12345678901234567890",
+// in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeMatchesRFC6238(t *testing.T) {
+	// The RFC lists eight digit codes; ours are their last six digits.
+	for unix, want := range map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	} {
+		got, err := Code(rfcSecret, Step(time.Unix(unix, 0)))
+		if err != nil || got != want {
+			t.Errorf("T=%d: got %q err=%v, want %q", unix, got, err, want)
+		}
+	}
+}
+
+func TestValidateAllowsSkew(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	for _, offset := range []time.Duration{-Period, 0, Period} {
+		code, _ := Code(rfcSecret, Step(now.Add(offset)))
+		step, ok := Validate(rfcSecret, code, now)
+		if !ok || step != Step(now.Add(offset)) {
+			t.Errorf("offset %v: expected the code to validate", offset)
+		}
+	}
+	old, _ := Code(rfcSecret, Step(now.Add(-2*Period)))
+	if _, ok := Validate(rfcSecret, old, now); ok {
+		t.Error("expected a code two steps old to be refused")
+	}
+	if _, ok := Validate(rfcSecret, "12345", now); ok {
+		t.Error("expected a short code to be refused")
+	}
+}
+
+func TestGenerateSecretAndURI(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil || len(secret) != 32 {
+		t.Fatalf("unexpected secret %q err=%v", secret, err)
+	}
+	if _, err := Code(secret, 1); err != nil {
+		t.Fatal(err)
+	}
+	uri := URI("Book Lending", "alice@example.com", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/Book%20Lending:alice@example.com?") || !strings.Contains(uri, "secret="+secret) || !strings.Contains(uri, "issuer=Book%20Lending") {
+		t.Fatalf("unexpected URI %q", uri)
+	}
+}
@@ -0,0 +1,117 @@
+// Unit tests for span creation and W3C trace context propagation
+package tracing
+
+import (
+	"book-lending-api/internal/config"
+	"book-lending-api/internal/database"
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/usecase"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/gorm"
+)
+
+const (
+	traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	traceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+)
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return rec
+}
+
+// queryBooks is a BookUseCase whose GetBookByID runs a real query so
+// that the GORM plugin produces a span.
+type queryBooks struct {
+	usecase.BookUseCase
+	db *gorm.DB
+}
+
+func (q queryBooks) GetBookByID(ctx context.Context, id uint) (*domain.Book, error) {
+	var book domain.Book
+	if err := q.db.WithContext(ctx).Raw("SELECT 1 AS id").Scan(&book).Error; err != nil {
+		return nil, err
+	}
+	if id != 1 {
+		return nil, domain.ErrBookNotFound
+	}
+	return &book, nil
+}
+
+func TestSpansFollowRequestThroughLayers(t *testing.T) {
+	rec := setupRecorder(t)
+	db, err := database.Open(config.DatabaseConfig{Driver: "sqlite", Path: ":memory:"})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.Use(GormPlugin{Driver: "sqlite"}); err != nil {
+		t.Fatalf("register plugin: %v", err)
+	}
+	books := Books(queryBooks{db: db})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/books/:id", func(c *gin.Context) {
+		id := uint(1)
+		if c.Param("id") != "1" {
+			id = 2
+		}
+		if _, err := books.GetBookByID(c.Request.Context(), id); err != nil {
+			_ = c.Error(err)
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/books/1", nil)
+	req.Header.Set("traceparent", traceparent)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := rec.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	byName := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range spans {
+		if s.SpanContext().TraceID().String() != traceID {
+			t.Errorf("span %q did not continue the incoming trace", s.Name())
+		}
+		byName[s.Name()] = s
+	}
+	server, uc := byName["GET /books/:id"], byName["BookUseCase.GetBookByID"]
+	query := byName["db.row"]
+	if server == nil || uc == nil || query == nil {
+		t.Fatalf("unexpected span names: %v", byName)
+	}
+	if uc.Parent().SpanID() != server.SpanContext().SpanID() || query.Parent().SpanID() != uc.SpanContext().SpanID() {
+		t.Fatal("spans are not nested server > use case > query")
+	}
+
+	rec2 := setupRecorder(t)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/books/2", nil))
+	for _, s := range rec2.Ended() {
+		if s.Status().Code == codes.Error {
+			t.Errorf("expected domain error should not fail span %q", s.Name())
+		}
+	}
+}
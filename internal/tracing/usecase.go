@@ -0,0 +1,425 @@
+package tracing
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/usecase"
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+type tracedAuth struct{ next usecase.AuthUseCase }
+
+// Auth wraps uc so that every method runs in its own span.
+func Auth(uc usecase.AuthUseCase) usecase.AuthUseCase { return &tracedAuth{next: uc} }
+
+func (t *tracedAuth) Register(ctx context.Context, req domain.RegisterRequest) (*domain.User, error) {
+	ctx, span := start(ctx, "AuthUseCase.Register")
+	user, err := t.next.Register(ctx, req)
+	finish(span, err)
+	return user, err
+}
+
+func (t *tracedAuth) Login(ctx context.Context, req domain.LoginRequest) (*domain.LoginResult, error) {
+	ctx, span := start(ctx, "AuthUseCase.Login")
+	result, err := t.next.Login(ctx, req)
+	finish(span, err)
+	return result, err
+}
+
+func (t *tracedAuth) LoginMFA(ctx context.Context, userID uint, code, ip string) (*domain.User, error) {
+	ctx, span := start(ctx, "AuthUseCase.LoginMFA", attribute.Int("user.id", int(userID)))
+	user, err := t.next.LoginMFA(ctx, userID, code, ip)
+	finish(span, err)
+	return user, err
+}
+
+func (t *tracedAuth) UnlockAccount(ctx context.Context, userID uint) error {
+	ctx, span := start(ctx, "AuthUseCase.UnlockAccount", attribute.Int("user.id", int(userID)))
+	err := t.next.UnlockAccount(ctx, userID)
+	finish(span, err)
+	return err
+}
+
+type tracedBooks struct{ next usecase.BookUseCase }
+
+// Books wraps uc so that every method runs in its own span.
+func Books(uc usecase.BookUseCase) usecase.BookUseCase { return &tracedBooks{next: uc} }
+
+func (t *tracedBooks) CreateBook(ctx context.Context, req domain.CreateBookRequest) (*domain.Book, error) {
+	ctx, span := start(ctx, "BookUseCase.CreateBook")
+	book, err := t.next.CreateBook(ctx, req)
+	finish(span, err)
+	return book, err
+}
+
+func (t *tracedBooks) GetBookByID(ctx context.Context, id uint) (*domain.Book, error) {
+	ctx, span := start(ctx, "BookUseCase.GetBookByID", attribute.Int("book.id", int(id)))
+	book, err := t.next.GetBookByID(ctx, id)
+	finish(span, err)
+	return book, err
+}
+
+func (t *tracedBooks) UpdateBook(ctx context.Context, id uint, req domain.UpdateBookRequest) (*domain.Book, error) {
+	ctx, span := start(ctx, "BookUseCase.UpdateBook", attribute.Int("book.id", int(id)))
+	book, err := t.next.UpdateBook(ctx, id, req)
+	finish(span, err)
+	return book, err
+}
+
+func (t *tracedBooks) DeleteBook(ctx context.Context, id uint) error {
+	ctx, span := start(ctx, "BookUseCase.DeleteBook", attribute.Int("book.id", int(id)))
+	err := t.next.DeleteBook(ctx, id)
+	finish(span, err)
+	return err
+}
+
+func (t *tracedBooks) ListBooks(ctx context.Context, page, limit int) (*domain.PaginatedResponse, error) {
+	ctx, span := start(ctx, "BookUseCase.ListBooks", attribute.Int("page", page), attribute.Int("limit", limit))
+	resp, err := t.next.ListBooks(ctx, page, limit)
+	finish(span, err)
+	return resp, err
+}
+
+func (t *tracedBooks) ListBooksByCursor(ctx context.Context, cursor string, limit int) (*domain.CursorPaginatedResponse, error) {
+	ctx, span := start(ctx, "BookUseCase.ListBooksByCursor", attribute.Int("limit", limit))
+	resp, err := t.next.ListBooksByCursor(ctx, cursor, limit)
+	finish(span, err)
+	return resp, err
+}
+
+type tracedLending struct{ next usecase.LendingUseCase }
+
+// Lending wraps uc so that every method runs in its own span.
+func Lending(uc usecase.LendingUseCase) usecase.LendingUseCase { return &tracedLending{next: uc} }
+
+func (t *tracedLending) BorrowBook(ctx context.Context, userID, bookID uint) (*domain.LendingRecord, error) {
+	ctx, span := start(ctx, "LendingUseCase.BorrowBook", attribute.Int("user.id", int(userID)), attribute.Int("book.id", int(bookID)))
+	record, err := t.next.BorrowBook(ctx, userID, bookID)
+	finish(span, err)
+	return record, err
+}
+
+func (t *tracedLending) ReturnBook(ctx context.Context, userID, recordID uint) (*domain.LendingRecord, error) {
+	ctx, span := start(ctx, "LendingUseCase.ReturnBook", attribute.Int("user.id", int(userID)), attribute.Int("lending_record.id", int(recordID)))
+	record, err := t.next.ReturnBook(ctx, userID, recordID)
+	finish(span, err)
+	return record, err
+}
+
+func (t *tracedLending) GetUserBorrowingHistory(ctx context.Context, userID uint, page, limit int, include []string) (*domain.PaginatedResponse, error) {
+	ctx, span := start(ctx, "LendingUseCase.GetUserBorrowingHistory", attribute.Int("user.id", int(userID)), attribute.Int("page", page), attribute.Int("limit", limit))
+	resp, err := t.next.GetUserBorrowingHistory(ctx, userID, page, limit, include)
+	finish(span, err)
+	return resp, err
+}
+
+func (t *tracedLending) GetUserBorrowingHistoryByCursor(ctx context.Context, userID uint, cursor string, limit int, include []string) (*domain.CursorPaginatedResponse, error) {
+	ctx, span := start(ctx, "LendingUseCase.GetUserBorrowingHistoryByCursor", attribute.Int("user.id", int(userID)), attribute.Int("limit", limit))
+	resp, err := t.next.GetUserBorrowingHistoryByCursor(ctx, userID, cursor, limit, include)
+	finish(span, err)
+	return resp, err
+}
+
+func (t *tracedLending) GetActiveBorrowings(ctx context.Context, userID uint, include []string) ([]domain.LendingRecord, error) {
+	ctx, span := start(ctx, "LendingUseCase.GetActiveBorrowings", attribute.Int("user.id", int(userID)))
+	records, err := t.next.GetActiveBorrowings(ctx, userID, include)
+	finish(span, err)
+	return records, err
+}
+
+func (t *tracedLending) CountOverdueLoans(ctx context.Context) (int64, error) {
+	ctx, span := start(ctx, "LendingUseCase.CountOverdueLoans")
+	n, err := t.next.CountOverdueLoans(ctx)
+	finish(span, err)
+	return n, err
+}
+
+type tracedPassword struct{ next usecase.PasswordUseCase }
+
+// Password wraps uc so that every method runs in its own span.
+func Password(uc usecase.PasswordUseCase) usecase.PasswordUseCase {
+	return &tracedPassword{next: uc}
+}
+
+func (t *tracedPassword) ForgotPassword(ctx context.Context, email string) error {
+	ctx, span := start(ctx, "PasswordUseCase.ForgotPassword")
+	err := t.next.ForgotPassword(ctx, email)
+	finish(span, err)
+	return err
+}
+
+func (t *tracedPassword) ResetPassword(ctx context.Context, token, password string) error {
+	ctx, span := start(ctx, "PasswordUseCase.ResetPassword")
+	err := t.next.ResetPassword(ctx, token, password)
+	finish(span, err)
+	return err
+}
+
+type tracedVerification struct{ next usecase.VerificationUseCase }
+
+// Verification wraps uc so that every method runs in its own span.
+func Verification(uc usecase.VerificationUseCase) usecase.VerificationUseCase {
+	return &tracedVerification{next: uc}
+}
+
+func (t *tracedVerification) SendVerification(ctx context.Context, user *domain.User) error {
+	ctx, span := start(ctx, "VerificationUseCase.SendVerification", attribute.Int("user.id", int(user.ID)))
+	err := t.next.SendVerification(ctx, user)
+	finish(span, err)
+	return err
+}
+
+func (t *tracedVerification) ResendVerification(ctx context.Context, userID uint) error {
+	ctx, span := start(ctx, "VerificationUseCase.ResendVerification", attribute.Int("user.id", int(userID)))
+	err := t.next.ResendVerification(ctx, userID)
+	finish(span, err)
+	return err
+}
+
+func (t *tracedVerification) VerifyEmail(ctx context.Context, token string) (*domain.User, error) {
+	ctx, span := start(ctx, "VerificationUseCase.VerifyEmail")
+	user, err := t.next.VerifyEmail(ctx, token)
+	finish(span, err)
+	return user, err
+}
+
+type tracedMFA struct{ next usecase.MFAUseCase }
+
+// MFA wraps uc so that every method runs in its own span.
+func MFA(uc usecase.MFAUseCase) usecase.MFAUseCase { return &tracedMFA{next: uc} }
+
+func (t *tracedMFA) Enroll(ctx context.Context, userID uint) (*domain.MFAEnrollment, error) {
+	ctx, span := start(ctx, "MFAUseCase.Enroll", attribute.Int("user.id", int(userID)))
+	enrollment, err := t.next.Enroll(ctx, userID)
+	finish(span, err)
+	return enrollment, err
+}
+
+func (t *tracedMFA) Activate(ctx context.Context, userID uint, code string) ([]string, error) {
+	ctx, span := start(ctx, "MFAUseCase.Activate", attribute.Int("user.id", int(userID)))
+	codes, err := t.next.Activate(ctx, userID, code)
+	finish(span, err)
+	return codes, err
+}
+
+func (t *tracedMFA) Disable(ctx context.Context, userID uint, code string) error {
+	ctx, span := start(ctx, "MFAUseCase.Disable", attribute.Int("user.id", int(userID)))
+	err := t.next.Disable(ctx, userID, code)
+	finish(span, err)
+	return err
+}
+
+func (t *tracedMFA) RegenerateRecoveryCodes(ctx context.Context, userID uint, code string) ([]string, error) {
+	ctx, span := start(ctx, "MFAUseCase.RegenerateRecoveryCodes", attribute.Int("user.id", int(userID)))
+	codes, err := t.next.RegenerateRecoveryCodes(ctx, userID, code)
+	finish(span, err)
+	return codes, err
+}
+
+func (t *tracedMFA) Enabled(ctx context.Context, userID uint) (bool, error) {
+	ctx, span := start(ctx, "MFAUseCase.Enabled", attribute.Int("user.id", int(userID)))
+	enabled, err := t.next.Enabled(ctx, userID)
+	finish(span, err)
+	return enabled, err
+}
+
+func (t *tracedMFA) Verify(ctx context.Context, userID uint, code string) error {
+	ctx, span := start(ctx, "MFAUseCase.Verify", attribute.Int("user.id", int(userID)))
+	err := t.next.Verify(ctx, userID, code)
+	finish(span, err)
+	return err
+}
+
+type tracedProfile struct{ next usecase.ProfileUseCase }
+
+// Profile wraps uc so that every method runs in its own span.
+func Profile(uc usecase.ProfileUseCase) usecase.ProfileUseCase { return &tracedProfile{next: uc} }
+
+func (t *tracedProfile) GetProfile(ctx context.Context, userID uint) (*domain.User, error) {
+	ctx, span := start(ctx, "ProfileUseCase.GetProfile", attribute.Int("user.id", int(userID)))
+	user, err := t.next.GetProfile(ctx, userID)
+	finish(span, err)
+	return user, err
+}
+
+func (t *tracedProfile) UpdateProfile(ctx context.Context, userID uint, req domain.UpdateProfileRequest) (*domain.User, error) {
+	ctx, span := start(ctx, "ProfileUseCase.UpdateProfile", attribute.Int("user.id", int(userID)))
+	user, err := t.next.UpdateProfile(ctx, userID, req)
+	finish(span, err)
+	return user, err
+}
+
+func (t *tracedProfile) ChangePassword(ctx context.Context, userID, sessionID uint, req domain.ChangePasswordRequest) error {
+	ctx, span := start(ctx, "ProfileUseCase.ChangePassword", attribute.Int("user.id", int(userID)))
+	err := t.next.ChangePassword(ctx, userID, sessionID, req)
+	finish(span, err)
+	return err
+}
+
+func (t *tracedProfile) ChangeEmail(ctx context.Context, userID, sessionID uint, req domain.ChangeEmailRequest) (*domain.User, error) {
+	ctx, span := start(ctx, "ProfileUseCase.ChangeEmail", attribute.Int("user.id", int(userID)))
+	user, err := t.next.ChangeEmail(ctx, userID, sessionID, req)
+	finish(span, err)
+	return user, err
+}
+
+func (t *tracedProfile) DeleteAccount(ctx context.Context, userID uint, password string) error {
+	ctx, span := start(ctx, "ProfileUseCase.DeleteAccount", attribute.Int("user.id", int(userID)))
+	err := t.next.DeleteAccount(ctx, userID, password)
+	finish(span, err)
+	return err
+}
+
+type tracedUserAdmin struct{ next usecase.UserAdminUseCase }
+
+// UserAdmin wraps uc so that every method runs in its own span.
+func UserAdmin(uc usecase.UserAdminUseCase) usecase.UserAdminUseCase {
+	return &tracedUserAdmin{next: uc}
+}
+
+func (t *tracedUserAdmin) ListUsers(ctx context.Context, filter domain.UserFilter, page, limit int) (*domain.PaginatedResponse, error) {
+	ctx, span := start(ctx, "UserAdminUseCase.ListUsers", attribute.Int("page", page), attribute.Int("limit", limit))
+	resp, err := t.next.ListUsers(ctx, filter, page, limit)
+	finish(span, err)
+	return resp, err
+}
+
+func (t *tracedUserAdmin) GetUser(ctx context.Context, id uint) (*domain.User, error) {
+	ctx, span := start(ctx, "UserAdminUseCase.GetUser", attribute.Int("user.id", int(id)))
+	user, err := t.next.GetUser(ctx, id)
+	finish(span, err)
+	return user, err
+}
+
+func (t *tracedUserAdmin) ChangeRole(ctx context.Context, actor domain.Actor, id uint, role string) (*domain.User, error) {
+	ctx, span := start(ctx, "UserAdminUseCase.ChangeRole", attribute.Int("user.id", int(id)), attribute.String("user.role", role))
+	user, err := t.next.ChangeRole(ctx, actor, id, role)
+	finish(span, err)
+	return user, err
+}
+
+func (t *tracedUserAdmin) Suspend(ctx context.Context, actor domain.Actor, id uint) (*domain.User, error) {
+	ctx, span := start(ctx, "UserAdminUseCase.Suspend", attribute.Int("user.id", int(id)))
+	user, err := t.next.Suspend(ctx, actor, id)
+	finish(span, err)
+	return user, err
+}
+
+func (t *tracedUserAdmin) Reactivate(ctx context.Context, actor domain.Actor, id uint) (*domain.User, error) {
+	ctx, span := start(ctx, "UserAdminUseCase.Reactivate", attribute.Int("user.id", int(id)))
+	user, err := t.next.Reactivate(ctx, actor, id)
+	finish(span, err)
+	return user, err
+}
+
+func (t *tracedUserAdmin) ResetBorrowLimit(ctx context.Context, actor domain.Actor, id uint) (*domain.User, error) {
+	ctx, span := start(ctx, "UserAdminUseCase.ResetBorrowLimit", attribute.Int("user.id", int(id)))
+	user, err := t.next.ResetBorrowLimit(ctx, actor, id)
+	finish(span, err)
+	return user, err
+}
+
+type tracedAPIKeys struct{ next usecase.APIKeyUseCase }
+
+// APIKeys wraps uc so that every method runs in its own span.
+func APIKeys(uc usecase.APIKeyUseCase) usecase.APIKeyUseCase { return &tracedAPIKeys{next: uc} }
+
+func (t *tracedAPIKeys) Create(ctx context.Context, userID uint, mfa bool, req domain.CreateAPIKeyRequest) (*domain.CreatedAPIKey, error) {
+	ctx, span := start(ctx, "APIKeyUseCase.Create", attribute.Int("user.id", int(userID)))
+	key, err := t.next.Create(ctx, userID, mfa, req)
+	finish(span, err)
+	return key, err
+}
+
+func (t *tracedAPIKeys) List(ctx context.Context, userID uint) ([]domain.APIKey, error) {
+	ctx, span := start(ctx, "APIKeyUseCase.List", attribute.Int("user.id", int(userID)))
+	keys, err := t.next.List(ctx, userID)
+	finish(span, err)
+	return keys, err
+}
+
+func (t *tracedAPIKeys) Revoke(ctx context.Context, userID, id uint) error {
+	ctx, span := start(ctx, "APIKeyUseCase.Revoke", attribute.Int("user.id", int(userID)), attribute.Int("api_key.id", int(id)))
+	err := t.next.Revoke(ctx, userID, id)
+	finish(span, err)
+	return err
+}
+
+func (t *tracedAPIKeys) Authenticate(ctx context.Context, raw string) (*domain.APIKey, *domain.User, error) {
+	ctx, span := start(ctx, "APIKeyUseCase.Authenticate")
+	key, user, err := t.next.Authenticate(ctx, raw)
+	finish(span, err)
+	return key, user, err
+}
+
+type tracedSSO struct{ next usecase.SSOUseCase }
+
+// SSO wraps uc so that every method runs in its own span.
+func SSO(uc usecase.SSOUseCase) usecase.SSOUseCase { return &tracedSSO{next: uc} }
+
+func (t *tracedSSO) Begin(ctx context.Context) (string, string, error) {
+	ctx, span := start(ctx, "SSOUseCase.Begin")
+	authURL, state, err := t.next.Begin(ctx)
+	finish(span, err)
+	return authURL, state, err
+}
+
+func (t *tracedSSO) Complete(ctx context.Context, state, code string) (*domain.LoginResult, error) {
+	ctx, span := start(ctx, "SSOUseCase.Complete")
+	result, err := t.next.Complete(ctx, state, code)
+	finish(span, err)
+	return result, err
+}
+
+type tracedSessions struct{ next usecase.SessionUseCase }
+
+// Sessions wraps uc so that every method runs in its own span.
+func Sessions(uc usecase.SessionUseCase) usecase.SessionUseCase { return &tracedSessions{next: uc} }
+
+func (t *tracedSessions) Create(ctx context.Context, userID uint, ip, userAgent string) (*domain.Session, error) {
+	ctx, span := start(ctx, "SessionUseCase.Create", attribute.Int("user.id", int(userID)))
+	session, err := t.next.Create(ctx, userID, ip, userAgent)
+	finish(span, err)
+	return session, err
+}
+
+func (t *tracedSessions) List(ctx context.Context, userID, currentID uint) ([]domain.Session, error) {
+	ctx, span := start(ctx, "SessionUseCase.List", attribute.Int("user.id", int(userID)))
+	sessions, err := t.next.List(ctx, userID, currentID)
+	finish(span, err)
+	return sessions, err
+}
+
+func (t *tracedSessions) Revoke(ctx context.Context, userID, id uint) error {
+	ctx, span := start(ctx, "SessionUseCase.Revoke", attribute.Int("user.id", int(userID)), attribute.Int("session.id", int(id)))
+	err := t.next.Revoke(ctx, userID, id)
+	finish(span, err)
+	return err
+}
+
+func (t *tracedSessions) RevokeOthers(ctx context.Context, userID, currentID uint) (int64, error) {
+	ctx, span := start(ctx, "SessionUseCase.RevokeOthers", attribute.Int("user.id", int(userID)), attribute.Int("session.id", int(currentID)))
+	n, err := t.next.RevokeOthers(ctx, userID, currentID)
+	finish(span, err)
+	return n, err
+}
+
+func (t *tracedSessions) RevokeAll(ctx context.Context, userID uint) (int64, error) {
+	ctx, span := start(ctx, "SessionUseCase.RevokeAll", attribute.Int("user.id", int(userID)))
+	n, err := t.next.RevokeAll(ctx, userID)
+	finish(span, err)
+	return n, err
+}
+
+func (t *tracedSessions) Validate(ctx context.Context, userID, id uint, ip string) (*domain.User, error) {
+	ctx, span := start(ctx, "SessionUseCase.Validate", attribute.Int("user.id", int(userID)), attribute.Int("session.id", int(id)))
+	user, err := t.next.Validate(ctx, userID, id, ip)
+	finish(span, err)
+	return user, err
+}
@@ -0,0 +1,129 @@
+package usecase
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/logging"
+	"book-lending-api/internal/repository"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// apiKeyPrefix starts every API key, so that leaked keys are easy
+	// to recognise and scan for.
+	apiKeyPrefix = "blk_"
+	// apiKeyShownLength is how much of a key is kept in the clear to
+	// tell keys apart.
+	apiKeyShownLength = len(apiKeyPrefix) + 8
+	// maxAPIKeys is how many keys a user may hold at once.
+	maxAPIKeys = 20
+	// apiKeyTouchInterval is how often a key's last use is recorded.
+	apiKeyTouchInterval = time.Minute
+)
+
+// APIKeyUseCase defines the operations for personal API keys.
+type APIKeyUseCase interface {
+	Create(ctx context.Context, userID uint, mfa bool, req domain.CreateAPIKeyRequest) (*domain.CreatedAPIKey, error)
+	List(ctx context.Context, userID uint) ([]domain.APIKey, error)
+	Revoke(ctx context.Context, userID, id uint) error
+	Authenticate(ctx context.Context, key string) (*domain.APIKey, *domain.User, error)
+}
+
+type apiKeyUseCase struct {
+	userRepo repository.UserRepository
+	keyRepo  repository.APIKeyRepository
+	now      func() time.Time
+}
+
+// NewAPIKeyUseCase constructs a new API key use case.
+func NewAPIKeyUseCase(userRepo repository.UserRepository, keyRepo repository.APIKeyRepository) APIKeyUseCase {
+	return &apiKeyUseCase{userRepo: userRepo, keyRepo: keyRepo, now: time.Now}
+}
+
+// Create issues a new key to a user.  mfa tells whether the user's
+// session passed two-factor authentication, which the key inherits.
+// The key itself is only returned here; afterwards only its prefix can
+// be seen.
+func (uc *apiKeyUseCase) Create(ctx context.Context, userID uint, mfa bool, req domain.CreateAPIKeyRequest) (*domain.CreatedAPIKey, error) {
+	now := uc.now()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		return nil, domain.ErrAPIKeyExpiryInPast
+	}
+	count, err := uc.keyRepo.CountByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxAPIKeys {
+		return nil, domain.ErrAPIKeyLimitExceeded.WithParams(map[string]string{"max": strconv.Itoa(maxAPIKeys)})
+	}
+	token, _, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	raw := apiKeyPrefix + token
+	key := domain.APIKey{
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    raw[:apiKeyShownLength],
+		KeyHash:   hashToken(raw),
+		Scopes:    req.Scopes,
+		MFA:       mfa,
+		ExpiresAt: req.ExpiresAt,
+		CreatedAt: now,
+	}
+	if err := uc.keyRepo.Create(ctx, &key); err != nil {
+		return nil, err
+	}
+	return &domain.CreatedAPIKey{APIKey: key, Key: raw}, nil
+}
+
+func (uc *apiKeyUseCase) List(ctx context.Context, userID uint) ([]domain.APIKey, error) {
+	return uc.keyRepo.ListByUser(ctx, userID)
+}
+
+// Revoke deletes one of a user's keys, which stops working at once.
+func (uc *apiKeyUseCase) Revoke(ctx context.Context, userID, id uint) error {
+	err := uc.keyRepo.Delete(ctx, userID, id)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.ErrAPIKeyNotFound
+	}
+	return err
+}
+
+// Authenticate returns the key and its owner for a key presented by a
+// client, and records that the key was used.  Unknown, expired and
+// revoked keys, and keys of deleted users, all yield
+// domain.ErrInvalidAPIKey; keys of suspended users yield
+// domain.ErrAccountSuspended.
+func (uc *apiKeyUseCase) Authenticate(ctx context.Context, raw string) (*domain.APIKey, *domain.User, error) {
+	if !strings.HasPrefix(raw, apiKeyPrefix) {
+		return nil, nil, domain.ErrInvalidAPIKey
+	}
+	now := uc.now()
+	key, err := uc.keyRepo.GetByHash(ctx, hashToken(raw))
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, nil, domain.ErrInvalidAPIKey
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if key.Expired(now) {
+		return nil, nil, domain.ErrInvalidAPIKey
+	}
+	user, err := uc.userRepo.GetByID(ctx, key.UserID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, nil, domain.ErrInvalidAPIKey
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if user.Suspended() {
+		return nil, nil, domain.ErrAccountSuspended
+	}
+	// Failing to record the use should not fail the request.
+	if err := uc.keyRepo.Touch(ctx, key.ID, now, now.Add(-apiKeyTouchInterval)); err != nil {
+		logging.FromContext(ctx).Error("recording API key use failed", "api_key_id", key.ID, "error", err)
+	}
+	return key, user, nil
+}
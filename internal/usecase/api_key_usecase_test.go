@@ -0,0 +1,163 @@
+// Unit tests for APIKeyUseCase
+package usecase
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/repository"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mockAPIKeyRepo struct {
+	keys    []domain.APIKey
+	touches int
+}
+
+var _ repository.APIKeyRepository = (*mockAPIKeyRepo)(nil)
+
+func (m *mockAPIKeyRepo) Create(ctx context.Context, key *domain.APIKey) error {
+	key.ID = uint(len(m.keys) + 1)
+	m.keys = append(m.keys, *key)
+	return nil
+}
+
+func (m *mockAPIKeyRepo) ListByUser(ctx context.Context, userID uint) ([]domain.APIKey, error) {
+	var keys []domain.APIKey
+	for _, k := range m.keys {
+		if k.UserID == userID {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+func (m *mockAPIKeyRepo) CountByUser(ctx context.Context, userID uint) (int64, error) {
+	keys, _ := m.ListByUser(ctx, userID)
+	return int64(len(keys)), nil
+}
+
+func (m *mockAPIKeyRepo) GetByHash(ctx context.Context, keyHash string) (*domain.APIKey, error) {
+	for i := range m.keys {
+		if m.keys[i].KeyHash == keyHash {
+			k := m.keys[i]
+			return &k, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (m *mockAPIKeyRepo) Delete(ctx context.Context, userID, id uint) error {
+	for i, k := range m.keys {
+		if k.ID == id && k.UserID == userID {
+			m.keys = slices.Delete(m.keys, i, i+1)
+			return nil
+		}
+	}
+	return domain.ErrNotFound
+}
+
+func (m *mockAPIKeyRepo) DeleteByUser(ctx context.Context, userID uint) (int64, error) {
+	before := len(m.keys)
+	m.keys = slices.DeleteFunc(m.keys, func(k domain.APIKey) bool { return k.UserID == userID })
+	return int64(before - len(m.keys)), nil
+}
+
+func (m *mockAPIKeyRepo) Touch(ctx context.Context, id uint, now, staleBefore time.Time) error {
+	for i := range m.keys {
+		k := &m.keys[i]
+		if k.ID == id && (k.LastUsedAt == nil || k.LastUsedAt.Before(staleBefore)) {
+			k.LastUsedAt = &now
+			m.touches++
+		}
+	}
+	return nil
+}
+
+// newAPIKeyTest returns a use case over alice, a member, and bob, who
+// is suspended.
+func newAPIKeyTest() (*apiKeyUseCase, *mockAPIKeyRepo) {
+	suspendedAt := time.Unix(1_600_000_000, 0)
+	users := &mockUserRepo{users: map[string]*domain.User{
+		"alice@example.com": {ID: 1, Email: "alice@example.com", Role: domain.RoleMember},
+		"bob@example.com":   {ID: 2, Email: "bob@example.com", Role: domain.RoleMember, SuspendedAt: &suspendedAt},
+	}}
+	keys := &mockAPIKeyRepo{}
+	uc := NewAPIKeyUseCase(users, keys).(*apiKeyUseCase)
+	uc.now = func() time.Time { return time.Unix(1_700_000_000, 0) }
+	return uc, keys
+}
+
+func TestAPIKeyCreateAndAuthenticate(t *testing.T) {
+	uc, keys := newAPIKeyTest()
+	ctx := context.Background()
+
+	created, err := uc.Create(ctx, 1, false, domain.CreateAPIKeyRequest{Name: "backup", Scopes: []string{domain.ScopeLendingRead}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(created.Key, created.Prefix) || keys.keys[0].KeyHash == created.Key {
+		t.Fatalf("expected only the hash of %q to be stored, got %+v", created.Key, keys.keys[0])
+	}
+	key, user, err := uc.Authenticate(ctx, created.Key)
+	if err != nil || key.ID != created.ID || user.ID != 1 {
+		t.Fatalf("unexpected key %+v user %+v err=%v", key, user, err)
+	}
+	// Uses within a minute of each other are recorded once.
+	_, _, _ = uc.Authenticate(ctx, created.Key)
+	if keys.touches != 1 {
+		t.Fatalf("expected one recorded use, got %d", keys.touches)
+	}
+	if _, _, err := uc.Authenticate(ctx, created.Key+"x"); !errors.Is(err, domain.ErrInvalidAPIKey) {
+		t.Fatalf("expected invalid API key, got %v", err)
+	}
+
+	if err := uc.Revoke(ctx, 2, created.ID); !errors.Is(err, domain.ErrAPIKeyNotFound) {
+		t.Fatalf("expected another user's revoke to be refused, got %v", err)
+	}
+	if err := uc.Revoke(ctx, 1, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := uc.Authenticate(ctx, created.Key); !errors.Is(err, domain.ErrInvalidAPIKey) {
+		t.Fatalf("expected a revoked key to be refused, got %v", err)
+	}
+}
+
+func TestAPIKeyRefusals(t *testing.T) {
+	uc, keys := newAPIKeyTest()
+	ctx := context.Background()
+	req := domain.CreateAPIKeyRequest{Name: "sync", Scopes: []string{domain.ScopeBooksRead}}
+
+	past := uc.now().Add(-time.Second)
+	if _, err := uc.Create(ctx, 1, false, domain.CreateAPIKeyRequest{Name: "old", Scopes: req.Scopes, ExpiresAt: &past}); !errors.Is(err, domain.ErrAPIKeyExpiryInPast) {
+		t.Fatalf("expected expiry in past, got %v", err)
+	}
+
+	soon := uc.now().Add(time.Hour)
+	expiring, err := uc.Create(ctx, 1, false, domain.CreateAPIKeyRequest{Name: "soon", Scopes: req.Scopes, ExpiresAt: &soon})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uc.now = func() time.Time { return soon }
+	if _, _, err := uc.Authenticate(ctx, expiring.Key); !errors.Is(err, domain.ErrInvalidAPIKey) {
+		t.Fatalf("expected an expired key to be refused, got %v", err)
+	}
+
+	suspended, err := uc.Create(ctx, 2, false, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := uc.Authenticate(ctx, suspended.Key); !errors.Is(err, domain.ErrAccountSuspended) {
+		t.Fatalf("expected a suspended owner to be refused, got %v", err)
+	}
+
+	for len(keys.keys) < maxAPIKeys+1 {
+		keys.keys = append(keys.keys, domain.APIKey{ID: uint(len(keys.keys) + 1), UserID: 1})
+	}
+	if _, err := uc.Create(ctx, 1, false, req); !errors.Is(err, domain.ErrAPIKeyLimitExceeded) {
+		t.Fatalf("expected the key limit to be enforced, got %v", err)
+	}
+}
@@ -2,55 +2,236 @@ package usecase
 
 import (
 	"book-lending-api/internal/domain"
+	"book-lending-api/internal/logging"
 	"book-lending-api/internal/repository"
+	"context"
 	"errors"
+	"math"
+	"strconv"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 // AuthUseCase defines the operations available for authentication.
 type AuthUseCase interface {
-	Register(req domain.RegisterRequest) (*domain.User, error)
-	Login(req domain.LoginRequest) (*domain.User, error)
+	Register(ctx context.Context, req domain.RegisterRequest) (*domain.User, error)
+	Login(ctx context.Context, req domain.LoginRequest) (*domain.LoginResult, error)
+	LoginMFA(ctx context.Context, userID uint, code, ip string) (*domain.User, error)
+	UnlockAccount(ctx context.Context, userID uint) error
 }
 
 type authUseCase struct {
-	userRepo repository.UserRepository
+	throttler
+	userRepo     repository.UserRepository
+	verification VerificationUseCase
+	mfa          MFAUseCase
+	now          func() time.Time
 }
 
-// NewAuthUseCase constructs a new authentication use case.
-func NewAuthUseCase(userRepo repository.UserRepository) AuthUseCase {
-	return &authUseCase{userRepo: userRepo}
+// NewAuthUseCase constructs a new authentication use case.  New users
+// are sent a verification email through verification, users with
+// two-factor authentication enabled are checked by mfa, and policy
+// decides when repeated failed logins lock an account or address.
+func NewAuthUseCase(userRepo repository.UserRepository, throttles repository.LoginThrottleRepository, verification VerificationUseCase, mfa MFAUseCase, policy domain.LoginPolicy) AuthUseCase {
+	return &authUseCase{
+		throttler:    throttler{throttles: throttles, policy: policy},
+		userRepo:     userRepo,
+		verification: verification,
+		mfa:          mfa,
+		now:          time.Now,
+	}
 }
 
 // Register registers a new user.  It hashes the password using bcrypt
-// and returns an error if the email is already taken.
-func (uc *authUseCase) Register(req domain.RegisterRequest) (*domain.User, error) {
-	if existing, _ := uc.userRepo.GetByEmail(req.Email); existing != nil {
-		return nil, errors.New("user with this email already exists")
+// and returns domain.ErrEmailTaken if the email is already taken.  The
+// new account starts unverified and a verification email is sent; if
+// that fails the user is still created and can ask for another.
+func (uc *authUseCase) Register(ctx context.Context, req domain.RegisterRequest) (*domain.User, error) {
+	if _, err := uc.userRepo.GetByEmail(ctx, req.Email); err == nil {
+		return nil, domain.ErrEmailTaken
+	} else if !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
 	}
 	hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
 	}
 	user := &domain.User{
-		Email:        req.Email,
+		Email:        domain.NormalizeEmail(req.Email),
 		PasswordHash: string(hashed),
+		Role:         domain.RoleMember,
 	}
-	if err := uc.userRepo.Create(user); err != nil {
+	if err := uc.userRepo.Create(ctx, user); errors.Is(err, domain.ErrConflict) {
+		// Someone registered the address since the check above.
+		return nil, domain.ErrEmailTaken.WithCause(err)
+	} else if err != nil {
 		return nil, err
 	}
+	if err := uc.verification.SendVerification(ctx, user); err != nil {
+		logging.FromContext(ctx).Error("issuing verification token failed", "user_id", user.ID, "error", err)
+	}
 	return user, nil
 }
 
 // Login authenticates a user by checking the provided credentials.
-func (uc *authUseCase) Login(req domain.LoginRequest) (*domain.User, error) {
-	user, err := uc.userRepo.GetByEmail(req.Email)
+// Unknown emails and wrong passwords both yield
+// domain.ErrInvalidCredentials.  Failures are counted per account and
+// per client address; while either is locked the password is not
+// checked at all and domain.ErrAccountLocked or domain.ErrTooManyLogins
+// is returned with the remaining lock time.  Suspended users get
+// domain.ErrAccountSuspended once their password is right.  For users
+// with two-factor authentication the result asks for a code, to be
+// passed to LoginMFA.
+func (uc *authUseCase) Login(ctx context.Context, req domain.LoginRequest) (*domain.LoginResult, error) {
+	now := uc.now()
+	subjects := uc.loginSubjects(req)
+	for _, s := range subjects {
+		if err := uc.checkLock(ctx, s, now); err != nil {
+			return nil, err
+		}
+	}
+	user, err := uc.userRepo.GetByEmail(ctx, req.Email)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+	if err != nil || bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)) != nil {
+		for _, s := range subjects {
+			if err := uc.recordFailure(ctx, s, now); err != nil {
+				return nil, err
+			}
+		}
+		return nil, domain.ErrInvalidCredentials
+	}
+	if user.Suspended() {
+		return nil, domain.ErrAccountSuspended
+	}
+	enabled, err := uc.mfa.Enabled(ctx, user.ID)
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		return nil, err
+	}
+	if enabled {
+		// The failure count is kept until the code is entered too, so
+		// that knowing the password does not allow unlimited guesses
+		// at codes.
+		return &domain.LoginResult{User: user, MFARequired: true}, nil
 	}
-	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)) != nil {
-		return nil, errors.New("invalid credentials")
+	// The address counter is left alone so that logging in to one's
+	// own account does not reset guesses against others.
+	if err := uc.throttles.Delete(ctx, accountSubject(req.Email)); err != nil {
+		return nil, err
+	}
+	return &domain.LoginResult{User: user}, nil
+}
+
+// LoginMFA completes the login of a user who has entered the right
+// password by checking a TOTP or recovery code.  Wrong codes count as
+// failed logins, with the same lockouts as wrong passwords, and yield
+// domain.ErrInvalidMFACode.
+func (uc *authUseCase) LoginMFA(ctx context.Context, userID uint, code, ip string) (*domain.User, error) {
+	user, err := uc.userRepo.GetByID(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.Suspended() {
+		return nil, domain.ErrAccountSuspended
+	}
+	now := uc.now()
+	subjects := uc.loginSubjects(domain.LoginRequest{Email: user.Email, IP: ip})
+	for _, s := range subjects {
+		if err := uc.checkLock(ctx, s, now); err != nil {
+			return nil, err
+		}
+	}
+	if err := uc.mfa.Verify(ctx, user.ID, code); err != nil {
+		if errors.Is(err, domain.ErrInvalidMFACode) {
+			for _, s := range subjects {
+				if err := uc.recordFailure(ctx, s, now); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return nil, err
+	}
+	if err := uc.throttles.Delete(ctx, accountSubject(user.Email)); err != nil {
+		return nil, err
 	}
 	return user, nil
 }
+
+// UnlockAccount clears the failed login count of a user, lifting any
+// lockout.
+func (uc *authUseCase) UnlockAccount(ctx context.Context, userID uint) error {
+	user, err := uc.userRepo.GetByID(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return uc.throttles.Delete(ctx, accountSubject(user.Email))
+}
+
+// loginSubject is something failed logins are counted against.
+type loginSubject struct {
+	key    string
+	limit  int
+	locked *domain.Error
+}
+
+func (uc *authUseCase) loginSubjects(req domain.LoginRequest) []loginSubject {
+	subjects := []loginSubject{{accountSubject(req.Email), uc.policy.MaxFailures, domain.ErrAccountLocked}}
+	if req.IP != "" {
+		subjects = append(subjects, loginSubject{"ip:" + req.IP, uc.policy.IPMaxFailures, domain.ErrTooManyLogins})
+	}
+	return subjects
+}
+
+// accountSubject keys throttles by email rather than user ID so that
+// unknown addresses are throttled exactly like real accounts.
+func accountSubject(email string) string {
+	return "account:" + domain.NormalizeEmail(email)
+}
+
+// throttler counts failed logins against subjects and locks them out
+// as its policy decides.
+type throttler struct {
+	throttles repository.LoginThrottleRepository
+	policy    domain.LoginPolicy
+}
+
+func (t *throttler) checkLock(ctx context.Context, s loginSubject, now time.Time) error {
+	throttle, err := t.throttles.Get(ctx, s.key)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if throttle.LockedUntil == nil || !now.Before(*throttle.LockedUntil) {
+		return nil
+	}
+	wait := int(math.Ceil(throttle.LockedUntil.Sub(now).Seconds()))
+	return s.locked.WithParams(map[string]string{"retry_after": strconv.Itoa(wait)})
+}
+
+// recordFailure counts a failure against s and locks it if that takes
+// it over its limit.  Expired throttles of every subject are cleared
+// out on the way.
+func (t *throttler) recordFailure(ctx context.Context, s loginSubject, now time.Time) error {
+	resetBefore := now.Add(-t.policy.ResetAfter)
+	if err := t.throttles.DeleteExpired(ctx, resetBefore); err != nil {
+		return err
+	}
+	throttle, err := t.throttles.RecordFailure(ctx, s.key, now, resetBefore)
+	if err != nil {
+		return err
+	}
+	if d := t.policy.LockoutFor(throttle.Failures, s.limit); d > 0 {
+		return t.throttles.Lock(ctx, s.key, now.Add(d))
+	}
+	return nil
+}
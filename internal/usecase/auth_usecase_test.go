@@ -0,0 +1,287 @@
+// Unit tests for AuthUseCase
+package usecase
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/repository"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// mockUserRepo keeps users in memory, keyed by email.
+type mockUserRepo struct{ users map[string]*domain.User }
+
+func (m *mockUserRepo) Create(ctx context.Context, user *domain.User) error {
+	user.Email = domain.NormalizeEmail(user.Email)
+	if m.users[user.Email] != nil {
+		return domain.ErrConflict
+	}
+	user.ID = uint(len(m.users) + 1)
+	m.users[user.Email] = user
+	return nil
+}
+func (m *mockUserRepo) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	if u := m.users[domain.NormalizeEmail(email)]; u != nil {
+		return u, nil
+	}
+	return nil, domain.ErrNotFound
+}
+func (m *mockUserRepo) GetByID(ctx context.Context, id uint) (*domain.User, error) {
+	for _, u := range m.users {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+func (m *mockUserRepo) UpdateRole(ctx context.Context, id uint, role string) error {
+	u, err := m.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	u.Role = role
+	return nil
+}
+func (m *mockUserRepo) UpdatePassword(ctx context.Context, id uint, hash string) error {
+	u, err := m.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	u.PasswordHash = hash
+	return nil
+}
+func (m *mockUserRepo) MarkEmailVerified(ctx context.Context, id uint, at time.Time) error {
+	u, err := m.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	u.EmailVerifiedAt = &at
+	return nil
+}
+func (m *mockUserRepo) UpdateProfile(ctx context.Context, user *domain.User) error {
+	u, err := m.GetByID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	u.Name, u.Phone, u.Preferences = user.Name, user.Phone, user.Preferences
+	return nil
+}
+func (m *mockUserRepo) UpdateEmail(ctx context.Context, id uint, email string) error {
+	u, err := m.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	delete(m.users, u.Email)
+	u.Email, u.EmailVerifiedAt = email, nil
+	m.users[email] = u
+	return nil
+}
+func (m *mockUserRepo) Delete(ctx context.Context, id uint) error {
+	u, err := m.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	delete(m.users, u.Email)
+	return nil
+}
+func (m *mockUserRepo) List(ctx context.Context, filter domain.UserFilter, offset, limit int) ([]domain.User, int64, error) {
+	q := strings.ToLower(filter.Query)
+	var matched []domain.User
+	for _, u := range m.users {
+		status := map[string]bool{
+			"":                          true,
+			domain.UserStatusActive:     !u.Suspended(),
+			domain.UserStatusSuspended:  u.Suspended(),
+			domain.UserStatusUnverified: !u.EmailVerified(),
+		}
+		if (q == "" || strings.Contains(strings.ToLower(u.Email), q) || strings.Contains(strings.ToLower(u.Name), q)) &&
+			(filter.Role == "" || u.Role == filter.Role) && status[filter.Status] {
+			matched = append(matched, *u)
+		}
+	}
+	slices.SortFunc(matched, func(a, b domain.User) int { return int(a.ID) - int(b.ID) })
+	total := int64(len(matched))
+	matched = matched[min(offset, len(matched)):]
+	return matched[:min(limit, len(matched))], total, nil
+}
+func (m *mockUserRepo) SetSuspended(ctx context.Context, id uint, at *time.Time) error {
+	u, err := m.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	u.SuspendedAt = at
+	return nil
+}
+func (m *mockUserRepo) ResetBorrowLimit(ctx context.Context, id uint, at time.Time) error {
+	u, err := m.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	u.BorrowLimitResetAt = &at
+	return nil
+}
+
+var _ repository.UserRepository = (*mockUserRepo)(nil)
+
+// mockThrottleRepo keeps login throttles in memory.
+type mockThrottleRepo struct {
+	throttles map[string]domain.LoginThrottle
+}
+
+func (m *mockThrottleRepo) Get(ctx context.Context, subject string) (*domain.LoginThrottle, error) {
+	t, ok := m.throttles[subject]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return &t, nil
+}
+func (m *mockThrottleRepo) RecordFailure(ctx context.Context, subject string, now, resetBefore time.Time) (*domain.LoginThrottle, error) {
+	t, ok := m.throttles[subject]
+	if !ok || m.expired(t, resetBefore) {
+		t = domain.LoginThrottle{Subject: subject, LockedUntil: t.LockedUntil}
+	}
+	t.Failures++
+	t.LastFailureAt = now
+	m.throttles[subject] = t
+	return &t, nil
+}
+func (m *mockThrottleRepo) Lock(ctx context.Context, subject string, until time.Time) error {
+	if t, ok := m.throttles[subject]; ok && (t.LockedUntil == nil || t.LockedUntil.Before(until)) {
+		t.LockedUntil = &until
+		m.throttles[subject] = t
+	}
+	return nil
+}
+func (m *mockThrottleRepo) Delete(ctx context.Context, subject string) error {
+	delete(m.throttles, subject)
+	return nil
+}
+func (m *mockThrottleRepo) DeleteExpired(ctx context.Context, before time.Time) error {
+	for subject, t := range m.throttles {
+		if m.expired(t, before) {
+			delete(m.throttles, subject)
+		}
+	}
+	return nil
+}
+func (m *mockThrottleRepo) expired(t domain.LoginThrottle, before time.Time) bool {
+	return t.LastFailureAt.Before(before) && (t.LockedUntil == nil || t.LockedUntil.Before(before))
+}
+
+var _ repository.LoginThrottleRepository = (*mockThrottleRepo)(nil)
+
+// newLockoutTestUseCase returns a use case with one user, alice, whose
+// password is "secret123", and a controllable clock.
+func newLockoutTestUseCase(t *testing.T, policy domain.LoginPolicy) (*authUseCase, *time.Time) {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := &mockUserRepo{users: map[string]*domain.User{
+		"alice@example.com": {ID: 1, Email: "alice@example.com", PasswordHash: string(hash)},
+	}}
+	now := time.Unix(1_700_000_000, 0)
+	verification := NewVerificationUseCase(users, &mockTokenRepo{}, &recordingMailer{}, time.Hour, "")
+	throttles := &mockThrottleRepo{throttles: map[string]domain.LoginThrottle{}}
+	mfa := NewMFAUseCase(users, newMockMFARepo(), throttles, policy, "Test", nil)
+	uc := NewAuthUseCase(users, throttles, verification, mfa, policy).(*authUseCase)
+	uc.now = func() time.Time { return now }
+	return uc, &now
+}
+
+func login(uc AuthUseCase, email, password, ip string) error {
+	_, err := uc.Login(context.Background(), domain.LoginRequest{Email: email, Password: password, IP: ip})
+	return err
+}
+
+func TestAuthUseCaseLocksAccountWithBackoff(t *testing.T) {
+	policy := domain.LoginPolicy{MaxFailures: 3, IPMaxFailures: 100, Lockout: time.Minute, MaxLockout: time.Hour, ResetAfter: 15 * time.Minute}
+	uc, now := newLockoutTestUseCase(t, policy)
+
+	for i := 0; i < 3; i++ {
+		if err := login(uc, "alice@example.com", "wrong", "10.0.0.1"); !errors.Is(err, domain.ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: expected invalid credentials, got %v", i, err)
+		}
+	}
+	// Even the right password is refused while locked, from any address.
+	err := login(uc, "alice@example.com", "secret123", "10.0.0.2")
+	var de *domain.Error
+	if !errors.As(err, &de) || !errors.Is(err, domain.ErrAccountLocked) || de.Params["retry_after"] != "60" {
+		t.Fatalf("expected a 60s lockout, got %v", err)
+	}
+
+	// One more failure after the lock ends doubles the next lockout.
+	*now = now.Add(time.Minute)
+	if err := login(uc, "alice@example.com", "wrong", "10.0.0.1"); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Fatalf("expected invalid credentials, got %v", err)
+	}
+	if err := login(uc, "alice@example.com", "secret123", "10.0.0.1"); !errors.As(err, &de) || de.Params["retry_after"] != "120" {
+		t.Fatalf("expected a 120s lockout, got %v", err)
+	}
+
+	// A successful login after the lock clears the count.
+	*now = now.Add(2 * time.Minute)
+	if err := login(uc, "alice@example.com", "secret123", "10.0.0.1"); err != nil {
+		t.Fatalf("expected login to succeed, got %v", err)
+	}
+	if err := login(uc, "alice@example.com", "wrong", "10.0.0.1"); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Fatalf("expected a fresh count after success, got %v", err)
+	}
+}
+
+func TestAuthUseCaseThrottlesAddress(t *testing.T) {
+	policy := domain.LoginPolicy{MaxFailures: 100, IPMaxFailures: 2, Lockout: time.Minute, MaxLockout: time.Hour, ResetAfter: 15 * time.Minute}
+	uc, _ := newLockoutTestUseCase(t, policy)
+
+	// Guessing across many accounts, known or not, trips the address limit.
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if err := login(uc, email, "wrong", "10.0.0.1"); !errors.Is(err, domain.ErrInvalidCredentials) {
+			t.Fatalf("expected invalid credentials, got %v", err)
+		}
+	}
+	if err := login(uc, "alice@example.com", "secret123", "10.0.0.1"); !errors.Is(err, domain.ErrTooManyLogins) {
+		t.Fatalf("expected the address to be throttled, got %v", err)
+	}
+	if err := login(uc, "alice@example.com", "secret123", "10.0.0.2"); err != nil {
+		t.Fatalf("other addresses should not be affected, got %v", err)
+	}
+}
+
+func TestAuthUseCaseUnlockAccount(t *testing.T) {
+	policy := domain.LoginPolicy{MaxFailures: 1, IPMaxFailures: 100, Lockout: time.Hour, MaxLockout: time.Hour, ResetAfter: time.Hour}
+	uc, _ := newLockoutTestUseCase(t, policy)
+
+	_ = login(uc, "alice@example.com", "wrong", "10.0.0.1")
+	if err := login(uc, "alice@example.com", "secret123", "10.0.0.1"); !errors.Is(err, domain.ErrAccountLocked) {
+		t.Fatalf("expected the account to be locked, got %v", err)
+	}
+	if err := uc.UnlockAccount(context.Background(), 1); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if err := login(uc, "alice@example.com", "secret123", "10.0.0.1"); err != nil {
+		t.Fatalf("expected login after unlock, got %v", err)
+	}
+	if err := uc.UnlockAccount(context.Background(), 42); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Fatalf("expected user not found, got %v", err)
+	}
+}
+
+func TestAuthUseCaseRefusesSuspendedAccount(t *testing.T) {
+	uc, now := newLockoutTestUseCase(t, domain.DefaultLoginPolicy)
+	suspendedAt := *now
+	uc.userRepo.(*mockUserRepo).users["alice@example.com"].SuspendedAt = &suspendedAt
+
+	if err := login(uc, "alice@example.com", "wrong", "10.0.0.1"); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Fatalf("a wrong password should not reveal the suspension, got %v", err)
+	}
+	if err := login(uc, "alice@example.com", "secret123", "10.0.0.1"); !errors.Is(err, domain.ErrAccountSuspended) {
+		t.Fatalf("expected account suspended, got %v", err)
+	}
+}
@@ -3,32 +3,38 @@ package usecase
 import (
 	"book-lending-api/internal/domain"
 	"book-lending-api/internal/repository"
+	"book-lending-api/pkg"
+	"context"
 	"errors"
 	"math"
 )
 
+// booksCursorScope names the book listing in its cursors.
+const booksCursorScope = "books"
+
 // BookUseCase defines business logic operations for books.
 type BookUseCase interface {
-	CreateBook(req domain.CreateBookRequest) (*domain.Book, error)
-	GetBookByID(id uint) (*domain.Book, error)
-	UpdateBook(id uint, req domain.UpdateBookRequest) (*domain.Book, error)
-	DeleteBook(id uint) error
-	ListBooks(page, limit int) (*domain.PaginatedResponse, error)
+	CreateBook(ctx context.Context, req domain.CreateBookRequest) (*domain.Book, error)
+	GetBookByID(ctx context.Context, id uint) (*domain.Book, error)
+	UpdateBook(ctx context.Context, id uint, req domain.UpdateBookRequest) (*domain.Book, error)
+	DeleteBook(ctx context.Context, id uint) error
+	ListBooks(ctx context.Context, page, limit int) (*domain.PaginatedResponse, error)
+	ListBooksByCursor(ctx context.Context, cursor string, limit int) (*domain.CursorPaginatedResponse, error)
 }
 
 type bookUseCase struct {
 	bookRepo repository.BookRepository
+	cursors  *pkg.CursorCodec
 }
 
 // NewBookUseCase constructs a new book use case.
-func NewBookUseCase(bookRepo repository.BookRepository) BookUseCase {
-	return &bookUseCase{bookRepo: bookRepo}
+func NewBookUseCase(bookRepo repository.BookRepository, cursors *pkg.CursorCodec) BookUseCase {
+	return &bookUseCase{bookRepo: bookRepo, cursors: cursors}
 }
 
-func (uc *bookUseCase) CreateBook(req domain.CreateBookRequest) (*domain.Book, error) {
-	// check for duplicate ISBN
-	if existing, _ := uc.bookRepo.GetByISBN(req.ISBN); existing != nil {
-		return nil, errors.New("book with this ISBN already exists")
+func (uc *bookUseCase) CreateBook(ctx context.Context, req domain.CreateBookRequest) (*domain.Book, error) {
+	if err := uc.ensureISBNFree(ctx, req.ISBN); err != nil {
+		return nil, err
 	}
 	book := &domain.Book{
 		Title:    req.Title,
@@ -37,29 +43,25 @@ func (uc *bookUseCase) CreateBook(req domain.CreateBookRequest) (*domain.Book, e
 		Quantity: req.Quantity,
 		Category: req.Category,
 	}
-	if err := uc.bookRepo.Create(book); err != nil {
-		return nil, err
+	if err := uc.bookRepo.Create(ctx, book); err != nil {
+		return nil, isbnConflict(err)
 	}
 	return book, nil
 }
 
-func (uc *bookUseCase) GetBookByID(id uint) (*domain.Book, error) {
-	book, err := uc.bookRepo.GetByID(id)
-	if err != nil {
-		return nil, errors.New("book not found")
-	}
-	return book, nil
+func (uc *bookUseCase) GetBookByID(ctx context.Context, id uint) (*domain.Book, error) {
+	return uc.getBook(ctx, id)
 }
 
-func (uc *bookUseCase) UpdateBook(id uint, req domain.UpdateBookRequest) (*domain.Book, error) {
-	book, err := uc.bookRepo.GetByID(id)
+func (uc *bookUseCase) UpdateBook(ctx context.Context, id uint, req domain.UpdateBookRequest) (*domain.Book, error) {
+	book, err := uc.getBook(ctx, id)
 	if err != nil {
-		return nil, errors.New("book not found")
+		return nil, err
 	}
 	// handle ISBN change
 	if req.ISBN != nil && *req.ISBN != book.ISBN {
-		if existing, _ := uc.bookRepo.GetByISBN(*req.ISBN); existing != nil {
-			return nil, errors.New("book with this ISBN already exists")
+		if err := uc.ensureISBNFree(ctx, *req.ISBN); err != nil {
+			return nil, err
 		}
 		book.ISBN = *req.ISBN
 	}
@@ -75,22 +77,58 @@ func (uc *bookUseCase) UpdateBook(id uint, req domain.UpdateBookRequest) (*domai
 	if req.Category != nil {
 		book.Category = *req.Category
 	}
-	if err := uc.bookRepo.Update(book); err != nil {
+	if err := uc.bookRepo.Update(ctx, book); err != nil {
+		return nil, isbnConflict(err)
+	}
+	return book, nil
+}
+
+func (uc *bookUseCase) DeleteBook(ctx context.Context, id uint) error {
+	if _, err := uc.getBook(ctx, id); err != nil {
+		return err
+	}
+	return uc.bookRepo.Delete(ctx, id)
+}
+
+// isbnConflict reports a unique constraint violation as
+// domain.ErrDuplicateISBN.  ensureISBNFree catches most duplicates, but
+// another request can take the ISBN between the check and the write.
+func isbnConflict(err error) error {
+	if errors.Is(err, domain.ErrConflict) {
+		return domain.ErrDuplicateISBN.WithCause(err)
+	}
+	return err
+}
+
+// getBook loads a book, translating a missing row into
+// domain.ErrBookNotFound.
+func (uc *bookUseCase) getBook(ctx context.Context, id uint) (*domain.Book, error) {
+	book, err := uc.bookRepo.GetByID(ctx, id)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrBookNotFound
+	}
+	if err != nil {
 		return nil, err
 	}
 	return book, nil
 }
 
-func (uc *bookUseCase) DeleteBook(id uint) error {
-	if _, err := uc.bookRepo.GetByID(id); err != nil {
-		return errors.New("book not found")
+// ensureISBNFree returns domain.ErrDuplicateISBN when a book with the
+// given ISBN already exists.
+func (uc *bookUseCase) ensureISBNFree(ctx context.Context, isbn string) error {
+	_, err := uc.bookRepo.GetByISBN(ctx, isbn)
+	if err == nil {
+		return domain.ErrDuplicateISBN
+	}
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
 	}
-	return uc.bookRepo.Delete(id)
+	return err
 }
 
-func (uc *bookUseCase) ListBooks(page, limit int) (*domain.PaginatedResponse, error) {
+func (uc *bookUseCase) ListBooks(ctx context.Context, page, limit int) (*domain.PaginatedResponse, error) {
 	offset := (page - 1) * limit
-	books, total, err := uc.bookRepo.List(offset, limit)
+	books, total, err := uc.bookRepo.List(ctx, offset, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -103,3 +141,38 @@ func (uc *bookUseCase) ListBooks(page, limit int) (*domain.PaginatedResponse, er
 		TotalPages: totalPages,
 	}, nil
 }
+
+// ListBooksByCursor lists books using keyset pagination.  An empty
+// cursor returns the first page.
+func (uc *bookUseCase) ListBooksByCursor(ctx context.Context, cursor string, limit int) (*domain.CursorPaginatedResponse, error) {
+	var pos *domain.Cursor
+	if cursor != "" {
+		decoded, err := uc.cursors.Decode(booksCursorScope, cursor)
+		if err != nil {
+			return nil, err
+		}
+		pos = decoded
+	}
+	books, hasMore, err := uc.bookRepo.ListByCursor(ctx, pos, limit)
+	if err != nil {
+		return nil, err
+	}
+	resp := &domain.CursorPaginatedResponse{Data: books, Limit: limit}
+	if len(books) == 0 {
+		return resp, nil
+	}
+	backward := pos != nil && pos.Backward
+	if hasMore || backward {
+		last := books[len(books)-1]
+		if resp.NextCursor, err = uc.cursors.Encode(booksCursorScope, domain.Cursor{ID: last.ID}); err != nil {
+			return nil, err
+		}
+	}
+	if (hasMore && backward) || (pos != nil && !backward) {
+		first := books[0]
+		if resp.PrevCursor, err = uc.cursors.Encode(booksCursorScope, domain.Cursor{ID: first.ID, Backward: true}); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
@@ -4,35 +4,84 @@ package usecase
 import (
 	"book-lending-api/internal/domain"
 	"book-lending-api/internal/repository"
+	"book-lending-api/pkg"
+	"context"
 	"errors"
 	"testing"
 )
 
-// mockBookRepo for usecase tests
-type mockBookRepo struct{ existingByISBN map[string]*domain.Book }
+// mockBookRepo for usecase tests.  writeErr is returned by Create and
+// Update.
+type mockBookRepo struct {
+	existingByISBN map[string]*domain.Book
+	writeErr       error
+}
 
-func (m *mockBookRepo) Create(book *domain.Book) error        { return nil }
-func (m *mockBookRepo) GetByID(id uint) (*domain.Book, error) { return &domain.Book{ID: id}, nil }
-func (m *mockBookRepo) GetByISBN(isbn string) (*domain.Book, error) {
+func (m *mockBookRepo) Create(ctx context.Context, book *domain.Book) error { return m.writeErr }
+func (m *mockBookRepo) GetByID(ctx context.Context, id uint) (*domain.Book, error) {
+	return &domain.Book{ID: id}, nil
+}
+func (m *mockBookRepo) GetByISBN(ctx context.Context, isbn string) (*domain.Book, error) {
 	if b := m.existingByISBN[isbn]; b != nil {
 		return b, nil
 	}
-	return nil, errors.New("not found")
+	return nil, domain.ErrNotFound
+}
+func (m *mockBookRepo) Update(ctx context.Context, book *domain.Book) error { return m.writeErr }
+func (m *mockBookRepo) Delete(ctx context.Context, id uint) error           { return nil }
+func (m *mockBookRepo) List(ctx context.Context, offset, limit int) ([]domain.Book, int64, error) {
+	return nil, 0, nil
+}
+func (m *mockBookRepo) ListByCursor(ctx context.Context, cursor *domain.Cursor, limit int) ([]domain.Book, bool, error) {
+	return nil, false, nil
+}
+func (m *mockBookRepo) GetAvailableQuantity(ctx context.Context, bookID uint) (int, error) {
+	return 1, nil
+}
+func (m *mockBookRepo) UpdateQuantity(ctx context.Context, bookID uint, quantity int) error {
+	return nil
 }
-func (m *mockBookRepo) Update(book *domain.Book) error                       { return nil }
-func (m *mockBookRepo) Delete(id uint) error                                 { return nil }
-func (m *mockBookRepo) List(offset, limit int) ([]domain.Book, int64, error) { return nil, 0, nil }
-func (m *mockBookRepo) GetAvailableQuantity(bookID uint) (int, error)        { return 1, nil }
-func (m *mockBookRepo) UpdateQuantity(bookID uint, quantity int) error       { return nil }
 
 var _ repository.BookRepository = (*mockBookRepo)(nil)
 
 func TestBookUseCaseCreateBookDuplicateISBN(t *testing.T) {
 	repo := &mockBookRepo{existingByISBN: map[string]*domain.Book{"123": {ID: 1, ISBN: "123"}}}
-	uc := NewBookUseCase(repo)
+	uc := NewBookUseCase(repo, pkg.NewCursorCodec("test"))
 
-	_, err := uc.CreateBook(domain.CreateBookRequest{Title: "T", Author: "A", ISBN: "123", Quantity: 1, Category: "C"})
-	if err == nil || err.Error() != "book with this ISBN already exists" {
+	_, err := uc.CreateBook(context.Background(), domain.CreateBookRequest{Title: "T", Author: "A", ISBN: "123", Quantity: 1, Category: "C"})
+	if !errors.Is(err, domain.ErrDuplicateISBN) {
 		t.Fatalf("expected duplicate ISBN error, got %v", err)
 	}
 }
+
+func TestBookUseCaseReportsISBNTakenConcurrently(t *testing.T) {
+	// The ISBN is free when checked but taken by the time it is written.
+	repo := &mockBookRepo{writeErr: domain.ErrConflict}
+	uc := NewBookUseCase(repo, pkg.NewCursorCodec("test"))
+	ctx := context.Background()
+
+	if _, err := uc.CreateBook(ctx, domain.CreateBookRequest{Title: "T", Author: "A", ISBN: "123", Quantity: 1, Category: "C"}); !errors.Is(err, domain.ErrDuplicateISBN) {
+		t.Fatalf("expected duplicate ISBN error on create, got %v", err)
+	}
+	isbn := "456"
+	if _, err := uc.UpdateBook(ctx, 1, domain.UpdateBookRequest{ISBN: &isbn}); !errors.Is(err, domain.ErrDuplicateISBN) {
+		t.Fatalf("expected duplicate ISBN error on update, got %v", err)
+	}
+}
+
+func TestBookUseCaseRejectsCursorOfAnotherListing(t *testing.T) {
+	cursors := pkg.NewCursorCodec("test")
+	uc := NewBookUseCase(&mockBookRepo{}, cursors)
+
+	history, err := cursors.Encode(historyCursorScope(1), domain.Cursor{ID: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uc.ListBooksByCursor(context.Background(), history, 10); !errors.Is(err, domain.ErrInvalidCursor) {
+		t.Fatalf("expected a history cursor to be refused, got %v", err)
+	}
+	books, _ := cursors.Encode(booksCursorScope, domain.Cursor{ID: 5})
+	if _, err := uc.ListBooksByCursor(context.Background(), books, 10); err != nil {
+		t.Fatalf("expected a book cursor to be accepted, got %v", err)
+	}
+}
@@ -3,91 +3,133 @@ package usecase
 import (
 	"book-lending-api/internal/domain"
 	"book-lending-api/internal/repository"
+	"book-lending-api/pkg"
+	"context"
 	"errors"
 	"math"
+	"strconv"
 	"time"
 )
 
 // LendingUseCase defines the operations for borrowing and returning books.
 type LendingUseCase interface {
-	BorrowBook(userID, bookID uint) (*domain.LendingRecord, error)
-	ReturnBook(userID, recordID uint) (*domain.LendingRecord, error)
-	GetUserBorrowingHistory(userID uint, page, limit int) (*domain.PaginatedResponse, error)
-	GetActiveBorrowings(userID uint) ([]domain.LendingRecord, error)
+	BorrowBook(ctx context.Context, userID, bookID uint) (*domain.LendingRecord, error)
+	ReturnBook(ctx context.Context, userID, recordID uint) (*domain.LendingRecord, error)
+	GetUserBorrowingHistory(ctx context.Context, userID uint, page, limit int, include []string) (*domain.PaginatedResponse, error)
+	GetUserBorrowingHistoryByCursor(ctx context.Context, userID uint, cursor string, limit int, include []string) (*domain.CursorPaginatedResponse, error)
+	GetActiveBorrowings(ctx context.Context, userID uint, include []string) ([]domain.LendingRecord, error)
+	CountOverdueLoans(ctx context.Context) (int64, error)
 }
 
 type lendingUseCase struct {
 	lendingRepo repository.LendingRepository
 	bookRepo    repository.BookRepository
+	userRepo    repository.UserRepository
+	cursors     *pkg.CursorCodec
+	policy      domain.LoanPolicy
 }
 
-// NewLendingUseCase constructs a new lending use case.
-func NewLendingUseCase(lendingRepo repository.LendingRepository, bookRepo repository.BookRepository) LendingUseCase {
+// NewLendingUseCase constructs a new lending use case that enforces
+// policy on every borrow.  Only users with a verified email address
+// whose account is not suspended may borrow.
+func NewLendingUseCase(lendingRepo repository.LendingRepository, bookRepo repository.BookRepository, userRepo repository.UserRepository, cursors *pkg.CursorCodec, policy domain.LoanPolicy) LendingUseCase {
 	return &lendingUseCase{
 		lendingRepo: lendingRepo,
 		bookRepo:    bookRepo,
+		userRepo:    userRepo,
+		cursors:     cursors,
+		policy:      policy,
 	}
 }
 
-func (uc *lendingUseCase) BorrowBook(userID, bookID uint) (*domain.LendingRecord, error) {
+func (uc *lendingUseCase) BorrowBook(ctx context.Context, userID, bookID uint) (*domain.LendingRecord, error) {
+	// the account must be active and the address verified; checked
+	// here rather than from the token so that changes take effect
+	// without logging in again
+	user, err := uc.userRepo.GetByID(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrUserNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if user.Suspended() {
+		return nil, domain.ErrAccountSuspended
+	}
+	if !user.EmailVerified() {
+		return nil, domain.ErrEmailNotVerified
+	}
 	// verify book exists
-	if _, err := uc.bookRepo.GetByID(bookID); err != nil {
-		return nil, errors.New("book not found")
+	if _, err := uc.bookRepo.GetByID(ctx, bookID); errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrBookNotFound
+	} else if err != nil {
+		return nil, err
 	}
 	// ensure user hasn't borrowed this book already
-	if rec, _ := uc.lendingRepo.GetActiveByUserAndBook(userID, bookID); rec != nil {
-		return nil, errors.New("you have already borrowed this book")
+	if _, err := uc.lendingRepo.GetActiveByUserAndBook(ctx, userID, bookID); err == nil {
+		return nil, domain.ErrAlreadyBorrowed
+	} else if !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
 	}
-	// enforce weekly borrow limit
-	sevenDaysAgo := time.Now().AddDate(0, 0, -7)
-	count, err := uc.lendingRepo.CountUserBorrowsInPeriod(userID, sevenDaysAgo)
+	// enforce the rolling borrow limit, counting only borrows made
+	// since staff last reset it
+	since := time.Now().Add(-uc.policy.Window)
+	if reset := user.BorrowLimitResetAt; reset != nil && reset.After(since) {
+		since = *reset
+	}
+	count, err := uc.lendingRepo.CountUserBorrowsInPeriod(ctx, userID, since)
 	if err != nil {
 		return nil, err
 	}
-	if count >= 5 {
-		return nil, errors.New("borrowing limit exceeded: maximum 5 books per week")
+	if count >= int64(uc.policy.MaxBorrows) {
+		return nil, domain.ErrBorrowLimitExceeded.WithParams(map[string]string{
+			"max":  strconv.Itoa(uc.policy.MaxBorrows),
+			"days": strconv.Itoa(int(uc.policy.Window.Hours() / 24)),
+		})
 	}
 	// ensure availability
-	available, err := uc.bookRepo.GetAvailableQuantity(bookID)
+	available, err := uc.bookRepo.GetAvailableQuantity(ctx, bookID)
 	if err != nil {
 		return nil, err
 	}
 	if available <= 0 {
-		return nil, errors.New("book is not available for borrowing")
+		return nil, domain.ErrBookUnavailable
 	}
 	record := &domain.LendingRecord{
 		BookID:     bookID,
 		UserID:     userID,
-		BorrowDate: time.Now(),
+		BorrowDate: time.Now().Truncate(domain.TimestampPrecision),
 	}
-	if err := uc.lendingRepo.Create(record); err != nil {
+	if err := uc.lendingRepo.Create(ctx, record); err != nil {
 		return nil, err
 	}
-	return uc.lendingRepo.GetByID(record.ID)
+	return uc.lendingRepo.GetByID(ctx, record.ID)
 }
 
-func (uc *lendingUseCase) ReturnBook(userID, recordID uint) (*domain.LendingRecord, error) {
-	record, err := uc.lendingRepo.GetByID(recordID)
+func (uc *lendingUseCase) ReturnBook(ctx context.Context, userID, recordID uint) (*domain.LendingRecord, error) {
+	record, err := uc.lendingRepo.GetByID(ctx, recordID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrLendingRecordNotFound
+	}
 	if err != nil {
-		return nil, errors.New("lending record not found")
+		return nil, err
 	}
 	if record.UserID != userID {
-		return nil, errors.New("unauthorized: this lending record does not belong to you")
+		return nil, domain.ErrNotRecordOwner
 	}
 	if record.ReturnDate != nil {
-		return nil, errors.New("book has already been returned")
+		return nil, domain.ErrAlreadyReturned
 	}
 	now := time.Now()
 	record.ReturnDate = &now
-	if err := uc.lendingRepo.Update(record); err != nil {
+	if err := uc.lendingRepo.Update(ctx, record); err != nil {
 		return nil, err
 	}
 	return record, nil
 }
 
-func (uc *lendingUseCase) GetUserBorrowingHistory(userID uint, page, limit int) (*domain.PaginatedResponse, error) {
+func (uc *lendingUseCase) GetUserBorrowingHistory(ctx context.Context, userID uint, page, limit int, include []string) (*domain.PaginatedResponse, error) {
 	offset := (page - 1) * limit
-	records, total, err := uc.lendingRepo.GetUserBorrowingHistory(userID, offset, limit)
+	records, total, err := uc.lendingRepo.GetUserBorrowingHistory(ctx, userID, offset, limit, include)
 	if err != nil {
 		return nil, err
 	}
@@ -101,6 +143,56 @@ func (uc *lendingUseCase) GetUserBorrowingHistory(userID uint, page, limit int)
 	}, nil
 }
 
-func (uc *lendingUseCase) GetActiveBorrowings(userID uint) ([]domain.LendingRecord, error) {
-	return uc.lendingRepo.GetActiveBorrowingsByUser(userID)
+// historyCursorScope names a user's borrowing history in its cursors,
+// so that cursors cannot be carried from one user's history to
+// another's.
+func historyCursorScope(userID uint) string {
+	return "history:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// GetUserBorrowingHistoryByCursor pages through a user's history using
+// keyset pagination.  An empty cursor returns the most recent records.
+func (uc *lendingUseCase) GetUserBorrowingHistoryByCursor(ctx context.Context, userID uint, cursor string, limit int, include []string) (*domain.CursorPaginatedResponse, error) {
+	scope := historyCursorScope(userID)
+	var pos *domain.Cursor
+	if cursor != "" {
+		decoded, err := uc.cursors.Decode(scope, cursor)
+		if err != nil {
+			return nil, err
+		}
+		pos = decoded
+	}
+	records, hasMore, err := uc.lendingRepo.GetUserBorrowingHistoryByCursor(ctx, userID, pos, limit, include)
+	if err != nil {
+		return nil, err
+	}
+	resp := &domain.CursorPaginatedResponse{Data: records, Limit: limit}
+	if len(records) == 0 {
+		return resp, nil
+	}
+	backward := pos != nil && pos.Backward
+	if hasMore || backward {
+		last := records[len(records)-1]
+		next := domain.Cursor{ID: last.ID, Timestamp: last.BorrowDate}
+		if resp.NextCursor, err = uc.cursors.Encode(scope, next); err != nil {
+			return nil, err
+		}
+	}
+	if (hasMore && backward) || (pos != nil && !backward) {
+		first := records[0]
+		prev := domain.Cursor{ID: first.ID, Timestamp: first.BorrowDate, Backward: true}
+		if resp.PrevCursor, err = uc.cursors.Encode(scope, prev); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+func (uc *lendingUseCase) GetActiveBorrowings(ctx context.Context, userID uint, include []string) ([]domain.LendingRecord, error) {
+	return uc.lendingRepo.GetActiveBorrowingsByUser(ctx, userID, include)
+}
+
+// CountOverdueLoans counts open loans older than the loan period.
+func (uc *lendingUseCase) CountOverdueLoans(ctx context.Context) (int64, error) {
+	return uc.lendingRepo.CountActiveBorrowedBefore(ctx, time.Now().Add(-uc.policy.LoanPeriod))
 }
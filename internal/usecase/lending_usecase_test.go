@@ -0,0 +1,131 @@
+// Unit tests for LendingUseCase
+package usecase
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/repository"
+	"book-lending-api/pkg"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// mockLendingRepo keeps lending records in memory.  Methods the tests
+// do not need are left to the embedded nil interface.
+type mockLendingRepo struct {
+	repository.LendingRepository
+	records []domain.LendingRecord
+}
+
+func (m *mockLendingRepo) Create(ctx context.Context, record *domain.LendingRecord) error {
+	record.ID = uint(len(m.records) + 1)
+	m.records = append(m.records, *record)
+	return nil
+}
+func (m *mockLendingRepo) GetByID(ctx context.Context, id uint) (*domain.LendingRecord, error) {
+	for _, r := range m.records {
+		if r.ID == id {
+			return &r, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+func (m *mockLendingRepo) GetActiveByUserAndBook(ctx context.Context, userID, bookID uint) (*domain.LendingRecord, error) {
+	for _, r := range m.records {
+		if r.UserID == userID && r.BookID == bookID && r.ReturnDate == nil {
+			return &r, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+func (m *mockLendingRepo) CountUserBorrowsInPeriod(ctx context.Context, userID uint, since time.Time) (int64, error) {
+	var n int64
+	for _, r := range m.records {
+		if r.UserID == userID && !r.BorrowDate.Before(since) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// newLendingTest returns a use case allowing two borrows a week, with
+// one verified member, alice.
+func newLendingTest() (LendingUseCase, *mockUserRepo, *mockLendingRepo) {
+	verifiedAt := time.Now().Add(-time.Hour)
+	users := &mockUserRepo{users: map[string]*domain.User{
+		"alice@example.com": {ID: 1, Email: "alice@example.com", EmailVerifiedAt: &verifiedAt},
+	}}
+	lending := &mockLendingRepo{}
+	policy := domain.LoanPolicy{MaxBorrows: 2, Window: 7 * 24 * time.Hour, LoanPeriod: 14 * 24 * time.Hour}
+	uc := NewLendingUseCase(lending, &mockBookRepo{}, users, pkg.NewCursorCodec("test"), policy)
+	return uc, users, lending
+}
+
+func TestBorrowBookRequiresActiveVerifiedAccount(t *testing.T) {
+	ctx := context.Background()
+	uc, users, lending := newLendingTest()
+	alice := users.users["alice@example.com"]
+
+	if _, err := uc.BorrowBook(ctx, 99, 1); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+
+	verifiedAt := alice.EmailVerifiedAt
+	alice.EmailVerifiedAt = nil
+	if _, err := uc.BorrowBook(ctx, alice.ID, 1); !errors.Is(err, domain.ErrEmailNotVerified) {
+		t.Fatalf("expected ErrEmailNotVerified, got %v", err)
+	}
+	alice.EmailVerifiedAt = verifiedAt
+
+	suspendedAt := time.Now()
+	alice.SuspendedAt = &suspendedAt
+	if _, err := uc.BorrowBook(ctx, alice.ID, 1); !errors.Is(err, domain.ErrAccountSuspended) {
+		t.Fatalf("expected ErrAccountSuspended, got %v", err)
+	}
+	if len(lending.records) != 0 {
+		t.Fatalf("expected nothing to be borrowed, got %+v", lending.records)
+	}
+
+	alice.SuspendedAt = nil
+	record, err := uc.BorrowBook(ctx, alice.ID, 1)
+	if err != nil || record.UserID != alice.ID || record.BookID != 1 {
+		t.Fatalf("expected alice to borrow once reinstated, got %+v err=%v", record, err)
+	}
+}
+
+func TestBorrowBookCountsOnlyBorrowsSinceLimitReset(t *testing.T) {
+	ctx := context.Background()
+	uc, users, lending := newLendingTest()
+	alice := users.users["alice@example.com"]
+	for bookID := uint(1); bookID <= 2; bookID++ {
+		if _, err := uc.BorrowBook(ctx, alice.ID, bookID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A borrow from before the window no longer counts.
+	lending.records[0].BorrowDate = time.Now().Add(-8 * 24 * time.Hour)
+	if _, err := uc.BorrowBook(ctx, alice.ID, 3); err != nil {
+		t.Fatalf("expected a borrow outside the window not to count, got %v", err)
+	}
+	if _, err := uc.BorrowBook(ctx, alice.ID, 4); !errors.Is(err, domain.ErrBorrowLimitExceeded) {
+		t.Fatalf("expected ErrBorrowLimitExceeded, got %v", err)
+	}
+
+	// The other two were borrowed before the reset.
+	for i := range lending.records[1:] {
+		lending.records[i+1].BorrowDate = time.Now().Add(-time.Hour)
+	}
+	if err := users.ResetBorrowLimit(ctx, alice.ID, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uc.BorrowBook(ctx, alice.ID, 4); err != nil {
+		t.Fatalf("expected the reset to lift the limit, got %v", err)
+	}
+	if _, err := uc.BorrowBook(ctx, alice.ID, 5); err != nil {
+		t.Fatalf("expected a second borrow after the reset, got %v", err)
+	}
+	if _, err := uc.BorrowBook(ctx, alice.ID, 6); !errors.Is(err, domain.ErrBorrowLimitExceeded) {
+		t.Fatalf("expected borrows since the reset to count, got %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+package usecase
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/repository"
+	"book-lending-api/internal/totp"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// recoveryCodeCount is how many recovery codes a user is given at a
+// time.
+const recoveryCodeCount = 10
+
+// MFAUseCase defines the operations for TOTP two-factor
+// authentication.
+type MFAUseCase interface {
+	Enroll(ctx context.Context, userID uint) (*domain.MFAEnrollment, error)
+	Activate(ctx context.Context, userID uint, code string) ([]string, error)
+	Disable(ctx context.Context, userID uint, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID uint, code string) ([]string, error)
+	Enabled(ctx context.Context, userID uint) (bool, error)
+	Verify(ctx context.Context, userID uint, code string) error
+}
+
+type mfaUseCase struct {
+	throttler
+	userRepo      repository.UserRepository
+	mfaRepo       repository.MFARepository
+	issuer        string
+	requiredRoles []string
+	now           func() time.Time
+}
+
+// NewMFAUseCase constructs a new two-factor authentication use case.
+// Wrong codes given to change settings count as failed logins against
+// throttles under policy.  issuer names the service in authenticator
+// apps.  Users with one of requiredRoles may not disable two-factor
+// authentication.
+func NewMFAUseCase(userRepo repository.UserRepository, mfaRepo repository.MFARepository, throttles repository.LoginThrottleRepository, policy domain.LoginPolicy, issuer string, requiredRoles []string) MFAUseCase {
+	return &mfaUseCase{
+		throttler:     throttler{throttles: throttles, policy: policy},
+		userRepo:      userRepo,
+		mfaRepo:       mfaRepo,
+		issuer:        issuer,
+		requiredRoles: requiredRoles,
+		now:           time.Now,
+	}
+}
+
+// Enroll generates a new TOTP secret for a user, replacing any pending
+// one.  The secret takes effect once confirmed with Activate.  It
+// returns domain.ErrMFAAlreadyEnabled if the user already has an
+// active secret.
+func (uc *mfaUseCase) Enroll(ctx context.Context, userID uint) (*domain.MFAEnrollment, error) {
+	user, err := uc.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if existing, err := uc.mfaRepo.Get(ctx, userID); err == nil && existing.Enabled() {
+		return nil, domain.ErrMFAAlreadyEnabled
+	} else if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.mfaRepo.Save(ctx, &domain.UserMFA{UserID: userID, Secret: secret, CreatedAt: uc.now()}); err != nil {
+		return nil, err
+	}
+	return &domain.MFAEnrollment{Secret: secret, URI: totp.URI(uc.issuer, user.Email, secret)}, nil
+}
+
+// Activate enables the pending secret of a user once code shows that
+// their authenticator app produces the right codes, and returns a
+// fresh set of recovery codes.  They are only shown this once.
+func (uc *mfaUseCase) Activate(ctx context.Context, userID uint, code string) ([]string, error) {
+	mfa, err := uc.mfaRepo.Get(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrMFANotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if mfa.Enabled() {
+		return nil, domain.ErrMFAAlreadyEnabled
+	}
+	now := uc.now()
+	step, ok := totp.Validate(mfa.Secret, code, now)
+	if !ok {
+		return nil, domain.ErrInvalidMFACode
+	}
+	mfa.EnabledAt = &now
+	mfa.LastUsedStep = step
+	if err := uc.mfaRepo.Save(ctx, mfa); err != nil {
+		return nil, err
+	}
+	return uc.newRecoveryCodes(ctx, userID)
+}
+
+// Disable turns two-factor authentication off after checking code with
+// verifyThrottled.  It returns domain.ErrMFAMandatory for users whose
+// role requires it.
+func (uc *mfaUseCase) Disable(ctx context.Context, userID uint, code string) error {
+	user, err := uc.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(uc.requiredRoles, user.Role) {
+		return domain.ErrMFAMandatory
+	}
+	if err := uc.verifyThrottled(ctx, user, code); err != nil {
+		return err
+	}
+	return uc.mfaRepo.Delete(ctx, userID)
+}
+
+// RegenerateRecoveryCodes replaces a user's recovery codes after
+// checking code with verifyThrottled.
+func (uc *mfaUseCase) RegenerateRecoveryCodes(ctx context.Context, userID uint, code string) ([]string, error) {
+	user, err := uc.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.verifyThrottled(ctx, user, code); err != nil {
+		return nil, err
+	}
+	return uc.newRecoveryCodes(ctx, userID)
+}
+
+// verifyThrottled checks code like Verify, counting wrong codes as
+// failed logins against the user's account so that a stolen session
+// cannot be used to guess codes.  A locked account yields
+// domain.ErrAccountLocked, as it does when logging in.
+func (uc *mfaUseCase) verifyThrottled(ctx context.Context, user *domain.User, code string) error {
+	now := uc.now()
+	s := loginSubject{accountSubject(user.Email), uc.policy.MaxFailures, domain.ErrAccountLocked}
+	if err := uc.checkLock(ctx, s, now); err != nil {
+		return err
+	}
+	if err := uc.Verify(ctx, user.ID, code); err != nil {
+		if errors.Is(err, domain.ErrInvalidMFACode) {
+			if err := uc.recordFailure(ctx, s, now); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+	return uc.throttles.Delete(ctx, s.key)
+}
+
+// Enabled reports whether a user must enter a code to log in.
+func (uc *mfaUseCase) Enabled(ctx context.Context, userID uint) (bool, error) {
+	mfa, err := uc.mfaRepo.Get(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return mfa.Enabled(), nil
+}
+
+// Verify checks a TOTP code, or failing that a recovery code, for a
+// user with two-factor authentication enabled.  Each code is accepted
+// only once.  It returns domain.ErrInvalidMFACode for a wrong or reused
+// code and domain.ErrMFANotEnabled if the user has no active secret.
+func (uc *mfaUseCase) Verify(ctx context.Context, userID uint, code string) error {
+	mfa, err := uc.mfaRepo.Get(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.ErrMFANotEnabled
+	}
+	if err != nil {
+		return err
+	}
+	if !mfa.Enabled() {
+		return domain.ErrMFANotEnabled
+	}
+	now := uc.now()
+	code = strings.TrimSpace(code)
+	if step, ok := totp.Validate(mfa.Secret, code, now); ok {
+		err = uc.mfaRepo.UseStep(ctx, userID, step)
+	} else {
+		err = uc.mfaRepo.UseRecoveryCode(ctx, userID, hashToken(normalizeRecoveryCode(code)), now)
+	}
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.ErrInvalidMFACode
+	}
+	return err
+}
+
+func (uc *mfaUseCase) getUser(ctx context.Context, userID uint) (*domain.User, error) {
+	user, err := uc.userRepo.GetByID(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrUserNotFound
+	}
+	return user, err
+}
+
+// recoveryEncoding spells recovery codes in lower case base32, which
+// avoids characters that are easily confused when copied by hand.
+var recoveryEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+func (uc *mfaUseCase) newRecoveryCodes(ctx context.Context, userID uint) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := recoveryEncoding.EncodeToString(b)[:10]
+		codes[i] = raw[:5] + "-" + raw[5:]
+		hashes[i] = hashToken(raw)
+	}
+	if err := uc.mfaRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode accepts recovery codes typed in either case and
+// with or without the separating hyphen.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,251 @@
+// Unit tests for MFAUseCase and two-step login
+package usecase
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/repository"
+	"book-lending-api/internal/totp"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// mockMFARepo keeps secrets and recovery code hashes in memory.
+type mockMFARepo struct {
+	secrets map[uint]domain.UserMFA
+	codes   map[uint]map[string]bool // hash -> used
+}
+
+func newMockMFARepo() *mockMFARepo {
+	return &mockMFARepo{secrets: map[uint]domain.UserMFA{}, codes: map[uint]map[string]bool{}}
+}
+
+func (m *mockMFARepo) Get(ctx context.Context, userID uint) (*domain.UserMFA, error) {
+	s, ok := m.secrets[userID]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return &s, nil
+}
+func (m *mockMFARepo) Save(ctx context.Context, mfa *domain.UserMFA) error {
+	m.secrets[mfa.UserID] = *mfa
+	return nil
+}
+func (m *mockMFARepo) Delete(ctx context.Context, userID uint) error {
+	delete(m.secrets, userID)
+	delete(m.codes, userID)
+	return nil
+}
+func (m *mockMFARepo) UseStep(ctx context.Context, userID uint, step int64) error {
+	s, ok := m.secrets[userID]
+	if !ok || s.LastUsedStep >= step {
+		return domain.ErrNotFound
+	}
+	s.LastUsedStep = step
+	m.secrets[userID] = s
+	return nil
+}
+func (m *mockMFARepo) ReplaceRecoveryCodes(ctx context.Context, userID uint, codeHashes []string) error {
+	m.codes[userID] = map[string]bool{}
+	for _, h := range codeHashes {
+		m.codes[userID][h] = false
+	}
+	return nil
+}
+func (m *mockMFARepo) UseRecoveryCode(ctx context.Context, userID uint, codeHash string, now time.Time) error {
+	used, ok := m.codes[userID][codeHash]
+	if !ok || used {
+		return domain.ErrNotFound
+	}
+	m.codes[userID][codeHash] = true
+	return nil
+}
+
+var _ repository.MFARepository = (*mockMFARepo)(nil)
+
+type mfaTest struct {
+	auth *authUseCase
+	mfa  *mfaUseCase
+	now  *time.Time
+}
+
+// newMFATest returns use cases sharing one user, librarian alice with
+// password "secret123", and a controllable clock.  Librarians are
+// required to use two-factor authentication.
+func newMFATest(t *testing.T) *mfaTest {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := &mockUserRepo{users: map[string]*domain.User{
+		"alice@example.com": {ID: 1, Email: "alice@example.com", PasswordHash: string(hash), Role: domain.RoleLibrarian},
+	}}
+	now := time.Unix(1_700_000_000, 0)
+	clock := func() time.Time { return now }
+	throttles := &mockThrottleRepo{throttles: map[string]domain.LoginThrottle{}}
+	policy := domain.LoginPolicy{MaxFailures: 3, IPMaxFailures: 100, Lockout: time.Minute, MaxLockout: time.Hour, ResetAfter: time.Hour}
+	mfa := NewMFAUseCase(users, newMockMFARepo(), throttles, policy, "Test", []string{domain.RoleLibrarian}).(*mfaUseCase)
+	mfa.now = clock
+	verification := NewVerificationUseCase(users, &mockTokenRepo{}, &recordingMailer{}, time.Hour, "")
+	auth := NewAuthUseCase(users, throttles, verification, mfa, policy).(*authUseCase)
+	auth.now = clock
+	return &mfaTest{auth: auth, mfa: mfa, now: &now}
+}
+
+// code returns the current TOTP code for secret, after moving the
+// clock on a step so that each call yields a fresh code.
+func (mt *mfaTest) code(t *testing.T, secret string) string {
+	t.Helper()
+	*mt.now = mt.now.Add(totp.Period)
+	code, err := totp.Code(secret, totp.Step(*mt.now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// enable enrols alice and returns her secret and recovery codes.
+func (mt *mfaTest) enable(t *testing.T) (string, []string) {
+	t.Helper()
+	ctx := context.Background()
+	enrollment, err := mt.mfa.Enroll(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enabled, _ := mt.mfa.Enabled(ctx, 1); enabled {
+		t.Fatal("a pending secret should not be enabled")
+	}
+	if _, err := mt.mfa.Activate(ctx, 1, "000000"); !errors.Is(err, domain.ErrInvalidMFACode) {
+		t.Fatalf("expected a wrong code to be refused, got %v", err)
+	}
+	codes, err := mt.mfa.Activate(ctx, 1, mt.code(t, enrollment.Secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("expected %d recovery codes, got %d", recoveryCodeCount, len(codes))
+	}
+	return enrollment.Secret, codes
+}
+
+func TestTwoStepLogin(t *testing.T) {
+	ctx := context.Background()
+	mt := newMFATest(t)
+
+	result, err := mt.auth.Login(ctx, domain.LoginRequest{Email: "alice@example.com", Password: "secret123", IP: "10.0.0.1"})
+	if err != nil || result.MFARequired {
+		t.Fatalf("expected a one-step login before enrolment, got %+v err=%v", result, err)
+	}
+
+	secret, _ := mt.enable(t)
+	result, err = mt.auth.Login(ctx, domain.LoginRequest{Email: "alice@example.com", Password: "secret123", IP: "10.0.0.1"})
+	if err != nil || !result.MFARequired {
+		t.Fatalf("expected a code to be required, got %+v err=%v", result, err)
+	}
+	code := mt.code(t, secret)
+	if _, err := mt.auth.LoginMFA(ctx, 1, code, "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	// The same code cannot be used twice.
+	if _, err := mt.auth.LoginMFA(ctx, 1, code, "10.0.0.1"); !errors.Is(err, domain.ErrInvalidMFACode) {
+		t.Fatalf("expected a replayed code to be refused, got %v", err)
+	}
+}
+
+func TestLoginMFALocksAfterWrongCodes(t *testing.T) {
+	ctx := context.Background()
+	mt := newMFATest(t)
+	secret, _ := mt.enable(t)
+
+	for i := 0; i < 3; i++ {
+		if _, err := mt.auth.LoginMFA(ctx, 1, "000000", "10.0.0.1"); !errors.Is(err, domain.ErrInvalidMFACode) {
+			t.Fatalf("attempt %d: expected invalid code, got %v", i, err)
+		}
+	}
+	if _, err := mt.auth.LoginMFA(ctx, 1, mt.code(t, secret), "10.0.0.1"); !errors.Is(err, domain.ErrAccountLocked) {
+		t.Fatalf("expected the account to be locked, got %v", err)
+	}
+}
+
+func TestMFASettingsLockAfterWrongCodes(t *testing.T) {
+	ctx := context.Background()
+	mt := newMFATest(t)
+	secret, _ := mt.enable(t)
+	mt.mfa.requiredRoles = nil
+
+	if _, err := mt.mfa.RegenerateRecoveryCodes(ctx, 1, "000000"); !errors.Is(err, domain.ErrInvalidMFACode) {
+		t.Fatalf("expected invalid code, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := mt.mfa.Disable(ctx, 1, "000000"); !errors.Is(err, domain.ErrInvalidMFACode) {
+			t.Fatalf("attempt %d: expected invalid code, got %v", i, err)
+		}
+	}
+	if err := mt.mfa.Disable(ctx, 1, mt.code(t, secret)); !errors.Is(err, domain.ErrAccountLocked) {
+		t.Fatalf("expected the account to be locked, got %v", err)
+	}
+	if _, err := mt.auth.LoginMFA(ctx, 1, "000000", "10.0.0.1"); !errors.Is(err, domain.ErrAccountLocked) {
+		t.Fatalf("expected logins to be locked too, got %v", err)
+	}
+
+	*mt.now = mt.now.Add(time.Minute)
+	if _, err := mt.mfa.RegenerateRecoveryCodes(ctx, 1, mt.code(t, secret)); err != nil {
+		t.Fatalf("expected the lockout to expire, got %v", err)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	ctx := context.Background()
+	mt := newMFATest(t)
+	secret, codes := mt.enable(t)
+
+	// Recovery codes are accepted in any case, without the hyphen, once.
+	if err := mt.mfa.Verify(ctx, 1, codes[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := mt.mfa.Verify(ctx, 1, codes[0]); !errors.Is(err, domain.ErrInvalidMFACode) {
+		t.Fatalf("expected a used recovery code to be refused, got %v", err)
+	}
+	if _, err := mt.auth.LoginMFA(ctx, 1, normalizeRecoveryCode(codes[1]), "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	fresh, err := mt.mfa.RegenerateRecoveryCodes(ctx, 1, mt.code(t, secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mt.mfa.Verify(ctx, 1, codes[2]); !errors.Is(err, domain.ErrInvalidMFACode) {
+		t.Fatalf("expected old recovery codes to be revoked, got %v", err)
+	}
+	if err := mt.mfa.Verify(ctx, 1, fresh[0]); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDisableMFA(t *testing.T) {
+	ctx := context.Background()
+	mt := newMFATest(t)
+	secret, _ := mt.enable(t)
+
+	if err := mt.mfa.Disable(ctx, 1, mt.code(t, secret)); !errors.Is(err, domain.ErrMFAMandatory) {
+		t.Fatalf("expected librarians to keep two-factor authentication, got %v", err)
+	}
+	if _, err := mt.mfa.Enroll(ctx, 1); !errors.Is(err, domain.ErrMFAAlreadyEnabled) {
+		t.Fatalf("expected re-enrolment to be refused, got %v", err)
+	}
+
+	mt.mfa.requiredRoles = nil
+	if err := mt.mfa.Disable(ctx, 1, "000000"); !errors.Is(err, domain.ErrInvalidMFACode) {
+		t.Fatalf("expected a code to be required, got %v", err)
+	}
+	if err := mt.mfa.Disable(ctx, 1, mt.code(t, secret)); err != nil {
+		t.Fatal(err)
+	}
+	if enabled, _ := mt.mfa.Enabled(ctx, 1); enabled {
+		t.Fatal("expected two-factor authentication to be off")
+	}
+}
@@ -0,0 +1,135 @@
+package usecase
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/logging"
+	"book-lending-api/internal/mail"
+	"book-lending-api/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordUseCase defines the operations for resetting a forgotten
+// password.
+type PasswordUseCase interface {
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, password string) error
+}
+
+type passwordUseCase struct {
+	userRepo  repository.UserRepository
+	tokens    repository.UserTokenRepository
+	throttles repository.LoginThrottleRepository
+	sessions  SessionUseCase
+	mailer    mail.Mailer
+	ttl       time.Duration
+	resetURL  string
+	now       func() time.Time
+	// sending tracks reset emails still being sent in the background.
+	sending sync.WaitGroup
+}
+
+// NewPasswordUseCase constructs a new password reset use case.  Reset
+// tokens are valid for ttl.  resetURL, if not empty, is a link template
+// in which {token} is replaced and which is included in reset emails.
+// Resetting a password logs the user out of every session in sessions.
+func NewPasswordUseCase(userRepo repository.UserRepository, tokens repository.UserTokenRepository, throttles repository.LoginThrottleRepository, sessions SessionUseCase, mailer mail.Mailer, ttl time.Duration, resetURL string) PasswordUseCase {
+	return &passwordUseCase{
+		userRepo:  userRepo,
+		tokens:    tokens,
+		throttles: throttles,
+		sessions:  sessions,
+		mailer:    mailer,
+		ttl:       ttl,
+		resetURL:  resetURL,
+		now:       time.Now,
+	}
+}
+
+// ForgotPassword emails a reset token to the user with the given email,
+// replacing any token sent earlier.  It succeeds without sending
+// anything for unknown emails.  The token is issued and sent in the
+// background, so that known emails take no longer to answer than
+// unknown ones, and failures are logged rather than returned, so that
+// callers cannot tell which emails have accounts.
+func (uc *passwordUseCase) ForgotPassword(ctx context.Context, email string) error {
+	user, err := uc.userRepo.GetByEmail(ctx, email)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// The request may be over before the email is sent.
+	ctx = context.WithoutCancel(ctx)
+	uc.sending.Add(1)
+	go func() {
+		defer uc.sending.Done()
+		if err := uc.sendReset(ctx, user); err != nil {
+			logging.FromContext(ctx).Error("sending password reset email failed", "user_id", user.ID, "error", err)
+		}
+	}()
+	return nil
+}
+
+func (uc *passwordUseCase) sendReset(ctx context.Context, user *domain.User) error {
+	token, err := issueToken(ctx, uc.tokens, user.ID, domain.TokenPasswordReset, uc.ttl, uc.now())
+	if err != nil {
+		return err
+	}
+	return uc.mailer.Send(ctx, uc.resetMessage(user.Email, token))
+}
+
+func (uc *passwordUseCase) resetMessage(to, token string) mail.Message {
+	var b strings.Builder
+	b.WriteString("Someone asked to reset the password for your account.\n\n")
+	if uc.resetURL != "" {
+		fmt.Fprintf(&b, "To choose a new password, open this link:\n\n%s\n\n", tokenLink(uc.resetURL, token))
+	} else {
+		fmt.Fprintf(&b, "To choose a new password, use this reset token:\n\n%s\n\n", token)
+	}
+	fmt.Fprintf(&b, "It can be used once and expires in %s.  If you did not ask for this, you can ignore this email.\n", uc.ttl)
+	return mail.Message{To: to, Subject: "Reset your password", Body: b.String()}
+}
+
+// ResetPassword sets a new password for the user a reset token was
+// issued to.  The token is used up, along with any other reset tokens
+// of the same user, all of the user's sessions are revoked and any
+// login lockout on the account is lifted.  It returns domain.ErrInvalidResetToken for tokens that are unknown,
+// expired or already used.
+func (uc *passwordUseCase) ResetPassword(ctx context.Context, token, password string) error {
+	consumed, err := uc.tokens.Consume(ctx, domain.TokenPasswordReset, hashToken(token), uc.now())
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	user, err := uc.userRepo.GetByID(ctx, consumed.UserID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := uc.userRepo.UpdatePassword(ctx, user.ID, string(hashed)); err != nil {
+		return err
+	}
+	if err := uc.tokens.DeleteForUser(ctx, user.ID, domain.TokenPasswordReset); err != nil {
+		return err
+	}
+	if _, err := uc.sessions.RevokeAll(ctx, user.ID); err != nil {
+		return err
+	}
+	return uc.throttles.Delete(ctx, accountSubject(user.Email))
+}
@@ -0,0 +1,211 @@
+// Unit tests for PasswordUseCase
+package usecase
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/mail"
+	"book-lending-api/internal/repository"
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// mockTokenRepo keeps user tokens in memory.
+type mockTokenRepo struct{ tokens []domain.UserToken }
+
+func (m *mockTokenRepo) Create(ctx context.Context, token *domain.UserToken) error {
+	token.ID = uint(len(m.tokens) + 1)
+	m.tokens = append(m.tokens, *token)
+	return nil
+}
+func (m *mockTokenRepo) Consume(ctx context.Context, purpose, tokenHash string, now time.Time) (*domain.UserToken, error) {
+	for i := range m.tokens {
+		t := &m.tokens[i]
+		if t.Purpose == purpose && t.TokenHash == tokenHash && t.UsedAt == nil && t.ExpiresAt.After(now) {
+			t.UsedAt = &now
+			consumed := *t
+			return &consumed, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+func (m *mockTokenRepo) DeleteForUser(ctx context.Context, userID uint, purpose string) error {
+	kept := m.tokens[:0]
+	for _, t := range m.tokens {
+		if t.UserID != userID || t.Purpose != purpose {
+			kept = append(kept, t)
+		}
+	}
+	m.tokens = kept
+	return nil
+}
+
+var _ repository.UserTokenRepository = (*mockTokenRepo)(nil)
+
+// recordingMailer keeps every message it is asked to send.
+type recordingMailer struct{ sent []mail.Message }
+
+func (m *recordingMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+var tokenPattern = regexp.MustCompile(`token=([A-Za-z0-9_-]{43})`)
+
+type passwordTest struct {
+	uc        *passwordUseCase
+	users     *mockUserRepo
+	tokens    *mockTokenRepo
+	throttles *mockThrottleRepo
+	sessions  SessionUseCase
+	mailer    *recordingMailer
+	now       *time.Time
+}
+
+func newPasswordTest(t *testing.T) *passwordTest {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt := &passwordTest{
+		users: &mockUserRepo{users: map[string]*domain.User{
+			"alice@example.com": {ID: 1, Email: "alice@example.com", PasswordHash: string(hash)},
+		}},
+		tokens:    &mockTokenRepo{},
+		throttles: &mockThrottleRepo{throttles: map[string]domain.LoginThrottle{}},
+		mailer:    &recordingMailer{},
+	}
+	now := time.Unix(1_700_000_000, 0)
+	pt.now = &now
+	pt.sessions = NewSessionUseCase(pt.users, &mockSessionRepo{}, time.Hour)
+	pt.uc = NewPasswordUseCase(pt.users, pt.tokens, pt.throttles, pt.sessions, pt.mailer, time.Hour, "https://library.example.com/reset?token={token}").(*passwordUseCase)
+	pt.uc.now = func() time.Time { return *pt.now }
+	return pt
+}
+
+// forgot requests a reset for alice and returns the token from the
+// email.
+func (pt *passwordTest) forgot(t *testing.T) string {
+	t.Helper()
+	if err := pt.uc.ForgotPassword(context.Background(), "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	pt.uc.sending.Wait()
+	msg := pt.mailer.sent[len(pt.mailer.sent)-1]
+	m := tokenPattern.FindStringSubmatch(msg.Body)
+	if msg.To != "alice@example.com" || m == nil {
+		t.Fatalf("unexpected reset email %+v", msg)
+	}
+	return m[1]
+}
+
+func TestPasswordResetFlow(t *testing.T) {
+	pt := newPasswordTest(t)
+	pt.throttles.throttles["account:alice@example.com"] = domain.LoginThrottle{Subject: "account:alice@example.com", Failures: 5}
+	session, err := pt.sessions.Create(context.Background(), 1, "192.0.2.1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := pt.forgot(t)
+	if stored := pt.tokens.tokens[0].TokenHash; stored == token || stored != hashToken(token) {
+		t.Fatalf("expected only the token hash to be stored, got %q", stored)
+	}
+	if err := pt.uc.ResetPassword(context.Background(), token, "newpass456"); err != nil {
+		t.Fatal(err)
+	}
+	hash := pt.users.users["alice@example.com"].PasswordHash
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte("newpass456")) != nil {
+		t.Fatal("password was not changed")
+	}
+	if _, ok := pt.throttles.throttles["account:alice@example.com"]; ok {
+		t.Fatal("expected the login lockout to be lifted")
+	}
+	if _, err := pt.sessions.Validate(context.Background(), 1, session.ID, "192.0.2.1"); !errors.Is(err, domain.ErrSessionRevoked) {
+		t.Fatalf("expected the old session to be revoked, got %v", err)
+	}
+	// Tokens are single use.
+	if err := pt.uc.ResetPassword(context.Background(), token, "again789"); !errors.Is(err, domain.ErrInvalidResetToken) {
+		t.Fatalf("expected a used token to be refused, got %v", err)
+	}
+}
+
+func TestPasswordResetTokenExpires(t *testing.T) {
+	pt := newPasswordTest(t)
+	token := pt.forgot(t)
+	*pt.now = pt.now.Add(time.Hour)
+	if err := pt.uc.ResetPassword(context.Background(), token, "newpass456"); !errors.Is(err, domain.ErrInvalidResetToken) {
+		t.Fatalf("expected an expired token to be refused, got %v", err)
+	}
+}
+
+func TestForgotPasswordReplacesEarlierTokens(t *testing.T) {
+	pt := newPasswordTest(t)
+	first := pt.forgot(t)
+	second := pt.forgot(t)
+	if err := pt.uc.ResetPassword(context.Background(), first, "newpass456"); !errors.Is(err, domain.ErrInvalidResetToken) {
+		t.Fatalf("expected the earlier token to be revoked, got %v", err)
+	}
+	if err := pt.uc.ResetPassword(context.Background(), second, "newpass456"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestForgotPasswordUnknownEmail(t *testing.T) {
+	pt := newPasswordTest(t)
+	if err := pt.uc.ForgotPassword(context.Background(), "nobody@example.com"); err != nil {
+		t.Fatalf("expected unknown emails to succeed silently, got %v", err)
+	}
+	pt.uc.sending.Wait()
+	if len(pt.mailer.sent) != 0 || len(pt.tokens.tokens) != 0 {
+		t.Fatal("expected nothing to be sent or stored")
+	}
+}
+
+// blockingMailer holds every email until release is closed, and drops
+// it if its context has been canceled by then.
+type blockingMailer struct {
+	recordingMailer
+	release chan struct{}
+}
+
+func (m *blockingMailer) Send(ctx context.Context, msg mail.Message) error {
+	<-m.release
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.recordingMailer.Send(ctx, msg)
+}
+
+func TestForgotPasswordSendsInBackground(t *testing.T) {
+	pt := newPasswordTest(t)
+	mailer := &blockingMailer{release: make(chan struct{})}
+	pt.uc.mailer = mailer
+
+	// The request is answered, and over, before the email goes out.
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := pt.uc.ForgotPassword(ctx, "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	close(mailer.release)
+	pt.uc.sending.Wait()
+	if len(mailer.sent) != 1 || mailer.sent[0].To != "alice@example.com" {
+		t.Fatalf("expected the reset email to be sent, got %+v", mailer.sent)
+	}
+}
+
+func TestResetMessageWithoutURL(t *testing.T) {
+	pt := newPasswordTest(t)
+	pt.uc.resetURL = ""
+	msg := pt.uc.resetMessage("alice@example.com", "abc")
+	if !strings.Contains(msg.Body, "\n\nabc\n\n") || strings.Contains(msg.Body, "http") {
+		t.Fatalf("expected the bare token in the body, got %q", msg.Body)
+	}
+}
@@ -0,0 +1,201 @@
+package usecase
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/logging"
+	"book-lending-api/internal/mail"
+	"book-lending-api/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ProfileUseCase defines the operations users perform on their own
+// account.
+type ProfileUseCase interface {
+	GetProfile(ctx context.Context, userID uint) (*domain.User, error)
+	UpdateProfile(ctx context.Context, userID uint, req domain.UpdateProfileRequest) (*domain.User, error)
+	ChangePassword(ctx context.Context, userID, sessionID uint, req domain.ChangePasswordRequest) error
+	ChangeEmail(ctx context.Context, userID, sessionID uint, req domain.ChangeEmailRequest) (*domain.User, error)
+	DeleteAccount(ctx context.Context, userID uint, password string) error
+}
+
+type profileUseCase struct {
+	userRepo     repository.UserRepository
+	lendingRepo  repository.LendingRepository
+	tokens       repository.UserTokenRepository
+	verification VerificationUseCase
+	sessions     SessionUseCase
+	mailer       mail.Mailer
+}
+
+// NewProfileUseCase constructs a new profile use case.  A changed email
+// address is verified again through verification, changing the
+// password or email address logs out of other sessions through
+// sessions, and notices of both changes are sent through mailer.
+func NewProfileUseCase(userRepo repository.UserRepository, lendingRepo repository.LendingRepository, tokens repository.UserTokenRepository, verification VerificationUseCase, sessions SessionUseCase, mailer mail.Mailer) ProfileUseCase {
+	return &profileUseCase{
+		userRepo:     userRepo,
+		lendingRepo:  lendingRepo,
+		tokens:       tokens,
+		verification: verification,
+		sessions:     sessions,
+		mailer:       mailer,
+	}
+}
+
+func (uc *profileUseCase) GetProfile(ctx context.Context, userID uint) (*domain.User, error) {
+	user, err := uc.userRepo.GetByID(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrUserNotFound
+	}
+	return user, err
+}
+
+// UpdateProfile applies the fields present in req and returns the
+// updated user.
+func (uc *profileUseCase) UpdateProfile(ctx context.Context, userID uint, req domain.UpdateProfileRequest) (*domain.User, error) {
+	user, err := uc.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name != nil {
+		user.Name = *req.Name
+	}
+	if req.Phone != nil {
+		user.Phone = *req.Phone
+	}
+	if req.Preferences != nil {
+		user.Preferences = *req.Preferences
+	}
+	if err := uc.userRepo.UpdateProfile(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ChangePassword replaces the user's password after checking the
+// current one, which yields domain.ErrIncorrectPassword when wrong.
+// Outstanding password reset links stop working, sessions other than
+// sessionID are revoked and the user is sent a notice.
+func (uc *profileUseCase) ChangePassword(ctx context.Context, userID, sessionID uint, req domain.ChangePasswordRequest) error {
+	user, err := uc.authenticate(ctx, userID, req.CurrentPassword)
+	if err != nil {
+		return err
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := uc.userRepo.UpdatePassword(ctx, user.ID, string(hashed)); err != nil {
+		return err
+	}
+	if err := uc.tokens.DeleteForUser(ctx, user.ID, domain.TokenPasswordReset); err != nil {
+		return err
+	}
+	if _, err := uc.sessions.RevokeOthers(ctx, user.ID, sessionID); err != nil {
+		return err
+	}
+	uc.notify(ctx, user, mail.Message{
+		To:      user.Email,
+		Subject: "Your password was changed",
+		Body:    "The password for your account was just changed.  If you did not do this, reset your password straight away.\n",
+	})
+	return nil
+}
+
+// ChangeEmail moves the account to a new address after checking the
+// password.  The new address starts unverified and is sent a
+// verification email, the old address is told about the change and
+// sessions other than sessionID are revoked.  It returns
+// domain.ErrEmailTaken if another account uses the address.
+func (uc *profileUseCase) ChangeEmail(ctx context.Context, userID, sessionID uint, req domain.ChangeEmailRequest) (*domain.User, error) {
+	user, err := uc.authenticate(ctx, userID, req.Password)
+	if err != nil {
+		return nil, err
+	}
+	req.Email = domain.NormalizeEmail(req.Email)
+	if req.Email == user.Email {
+		return user, nil
+	}
+	if _, err := uc.userRepo.GetByEmail(ctx, req.Email); err == nil {
+		return nil, domain.ErrEmailTaken
+	} else if !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+	old := user.Email
+	if err := uc.userRepo.UpdateEmail(ctx, user.ID, req.Email); err != nil {
+		return nil, err
+	}
+	// Reset links went to the old address and should not outlive it.
+	if err := uc.tokens.DeleteForUser(ctx, user.ID, domain.TokenPasswordReset); err != nil {
+		return nil, err
+	}
+	if _, err := uc.sessions.RevokeOthers(ctx, user.ID, sessionID); err != nil {
+		return nil, err
+	}
+	user.Email = req.Email
+	user.EmailVerifiedAt = nil
+	uc.notify(ctx, user, mail.Message{
+		To:      old,
+		Subject: "Your email address was changed",
+		Body:    fmt.Sprintf("The email address for your account was changed to %s.  If you did not do this, contact the library straight away.\n", req.Email),
+	})
+	if err := uc.verification.SendVerification(ctx, user); err != nil {
+		logging.FromContext(ctx).Error("issuing verification token failed", "user_id", user.ID, "error", err)
+	}
+	return user, nil
+}
+
+// DeleteAccount removes the user and their history after checking the
+// password.  It returns domain.ErrActiveLoans while the user still has
+// books that have not been returned.
+func (uc *profileUseCase) DeleteAccount(ctx context.Context, userID uint, password string) error {
+	user, err := uc.authenticate(ctx, userID, password)
+	if err != nil {
+		return err
+	}
+	active, err := uc.lendingRepo.GetActiveBorrowingsByUser(ctx, user.ID, []string{})
+	if err != nil {
+		return err
+	}
+	if len(active) > 0 {
+		return domain.ErrActiveLoans
+	}
+	if err := uc.userRepo.Delete(ctx, user.ID); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// authenticate loads the user and checks password against theirs.
+// Users without a password get domain.ErrPasswordNotSet, telling them
+// to set one with a password reset, which proves they own the email
+// address; letting them set one here would let a stolen token take the
+// account over.
+func (uc *profileUseCase) authenticate(ctx context.Context, userID uint, password string) (*domain.User, error) {
+	user, err := uc.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.HasPassword() {
+		return nil, domain.ErrPasswordNotSet
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return nil, domain.ErrIncorrectPassword
+	}
+	return user, nil
+}
+
+// notify sends msg, logging rather than returning a failure since the
+// change it reports has already been made.
+func (uc *profileUseCase) notify(ctx context.Context, user *domain.User, msg mail.Message) {
+	if err := uc.mailer.Send(ctx, msg); err != nil {
+		logging.FromContext(ctx).Error("sending account notice failed", "user_id", user.ID, "error", err)
+	}
+}
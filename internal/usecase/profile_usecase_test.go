@@ -0,0 +1,200 @@
+// Unit tests for ProfileUseCase
+package usecase
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/repository"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// stubLendingRepo reports a fixed set of active loans.  Methods the
+// profile use case does not call are left to the nil embedded
+// interface.
+type stubLendingRepo struct {
+	repository.LendingRepository
+	active []domain.LendingRecord
+}
+
+func (s *stubLendingRepo) GetActiveBorrowingsByUser(ctx context.Context, userID uint, include []string) ([]domain.LendingRecord, error) {
+	return s.active, nil
+}
+
+type profileTest struct {
+	uc       ProfileUseCase
+	users    *mockUserRepo
+	tokens   *mockTokenRepo
+	lending  *stubLendingRepo
+	sessions SessionUseCase
+	mailer   *recordingMailer
+}
+
+// newProfileTest returns a use case with one verified user, alice,
+// whose password is "secret123".
+func newProfileTest(t *testing.T) *profileTest {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifiedAt := time.Unix(1_700_000_000, 0)
+	pt := &profileTest{
+		users: &mockUserRepo{users: map[string]*domain.User{
+			"alice@example.com": {ID: 1, Email: "alice@example.com", PasswordHash: string(hash), EmailVerifiedAt: &verifiedAt},
+		}},
+		tokens:  &mockTokenRepo{},
+		lending: &stubLendingRepo{},
+		mailer:  &recordingMailer{},
+	}
+	verification := NewVerificationUseCase(pt.users, pt.tokens, pt.mailer, time.Hour, "https://library.example.com/verify?token={token}")
+	pt.sessions = NewSessionUseCase(pt.users, &mockSessionRepo{}, time.Hour)
+	pt.uc = NewProfileUseCase(pt.users, pt.lending, pt.tokens, verification, pt.sessions, pt.mailer)
+	return pt
+}
+
+// login starts a session for alice.
+func (pt *profileTest) login(t *testing.T) *domain.Session {
+	t.Helper()
+	session, err := pt.sessions.Create(context.Background(), 1, "192.0.2.1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+// checkOtherSessionsRevoked checks that alice's current session still
+// works and other no longer does.
+func (pt *profileTest) checkOtherSessionsRevoked(t *testing.T, current, other *domain.Session) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := pt.sessions.Validate(ctx, 1, current.ID, "192.0.2.1"); err != nil {
+		t.Fatalf("expected the current session to be kept, got %v", err)
+	}
+	if _, err := pt.sessions.Validate(ctx, 1, other.ID, "192.0.2.1"); !errors.Is(err, domain.ErrSessionRevoked) {
+		t.Fatalf("expected the other session to be revoked, got %v", err)
+	}
+}
+
+func TestUpdateProfileChangesOnlyGivenFields(t *testing.T) {
+	ctx := context.Background()
+	pt := newProfileTest(t)
+
+	name, phone := "Alice", "+6281234567890"
+	prefs := domain.UserPreferences{Language: "id", Timezone: "Asia/Jakarta"}
+	if _, err := pt.uc.UpdateProfile(ctx, 1, domain.UpdateProfileRequest{Name: &name, Phone: &phone, Preferences: &prefs}); err != nil {
+		t.Fatal(err)
+	}
+	newName := "Alice Liddell"
+	user, err := pt.uc.UpdateProfile(ctx, 1, domain.UpdateProfileRequest{Name: &newName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != newName || user.Phone != phone || user.Preferences != prefs {
+		t.Fatalf("unexpected profile %+v", user)
+	}
+	if _, err := pt.uc.UpdateProfile(ctx, 42, domain.UpdateProfileRequest{Name: &name}); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Fatalf("expected user not found, got %v", err)
+	}
+}
+
+func TestChangePasswordRequiresCurrentPassword(t *testing.T) {
+	ctx := context.Background()
+	pt := newProfileTest(t)
+	_ = pt.tokens.Create(ctx, &domain.UserToken{UserID: 1, Purpose: domain.TokenPasswordReset, TokenHash: "reset", ExpiresAt: time.Now().Add(time.Hour)})
+	current, other := pt.login(t), pt.login(t)
+
+	err := pt.uc.ChangePassword(ctx, 1, current.ID, domain.ChangePasswordRequest{CurrentPassword: "wrong", NewPassword: "newsecret"})
+	if !errors.Is(err, domain.ErrIncorrectPassword) {
+		t.Fatalf("expected incorrect password, got %v", err)
+	}
+	if err := pt.uc.ChangePassword(ctx, 1, current.ID, domain.ChangePasswordRequest{CurrentPassword: "secret123", NewPassword: "newsecret"}); err != nil {
+		t.Fatal(err)
+	}
+	user := pt.users.users["alice@example.com"]
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte("newsecret")) != nil {
+		t.Fatal("expected the new password to be stored")
+	}
+	if len(pt.tokens.tokens) != 0 {
+		t.Fatalf("expected reset tokens to be revoked, found %d", len(pt.tokens.tokens))
+	}
+	if len(pt.mailer.sent) != 1 || pt.mailer.sent[0].To != "alice@example.com" {
+		t.Fatalf("expected a notice to alice, got %+v", pt.mailer.sent)
+	}
+	pt.checkOtherSessionsRevoked(t, current, other)
+}
+
+func TestChangeEmailRequiresReverification(t *testing.T) {
+	ctx := context.Background()
+	pt := newProfileTest(t)
+	pt.users.users["bob@example.com"] = &domain.User{ID: 2, Email: "bob@example.com"}
+	current, other := pt.login(t), pt.login(t)
+
+	if _, err := pt.uc.ChangeEmail(ctx, 1, current.ID, domain.ChangeEmailRequest{Email: "alice@example.org", Password: "wrong"}); !errors.Is(err, domain.ErrIncorrectPassword) {
+		t.Fatalf("expected incorrect password, got %v", err)
+	}
+	if _, err := pt.uc.ChangeEmail(ctx, 1, current.ID, domain.ChangeEmailRequest{Email: "bob@example.com", Password: "secret123"}); !errors.Is(err, domain.ErrEmailTaken) {
+		t.Fatalf("expected email taken, got %v", err)
+	}
+
+	user, err := pt.uc.ChangeEmail(ctx, 1, current.ID, domain.ChangeEmailRequest{Email: "alice@example.org", Password: "secret123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "alice@example.org" || user.EmailVerified() {
+		t.Fatalf("expected an unverified new address, got %+v", user)
+	}
+	if len(pt.mailer.sent) != 2 || pt.mailer.sent[0].To != "alice@example.com" || pt.mailer.sent[1].To != "alice@example.org" {
+		t.Fatalf("expected a notice to the old address and verification to the new, got %+v", pt.mailer.sent)
+	}
+	pt.checkOtherSessionsRevoked(t, current, other)
+	if _, err := NewVerificationUseCase(pt.users, pt.tokens, pt.mailer, time.Hour, "").VerifyEmail(ctx, lastVerifyToken(t, pt.mailer)); err != nil {
+		t.Fatalf("verifying the new address: %v", err)
+	}
+	if !pt.users.users["alice@example.org"].EmailVerified() {
+		t.Fatal("expected the new address to be verified")
+	}
+}
+
+func TestDeleteAccountRefusedWithActiveLoans(t *testing.T) {
+	ctx := context.Background()
+	pt := newProfileTest(t)
+	pt.lending.active = []domain.LendingRecord{{ID: 1, UserID: 1, BookID: 1}}
+
+	if err := pt.uc.DeleteAccount(ctx, 1, "wrong"); !errors.Is(err, domain.ErrIncorrectPassword) {
+		t.Fatalf("expected incorrect password, got %v", err)
+	}
+	if err := pt.uc.DeleteAccount(ctx, 1, "secret123"); !errors.Is(err, domain.ErrActiveLoans) {
+		t.Fatalf("expected active loans, got %v", err)
+	}
+	pt.lending.active = nil
+	if err := pt.uc.DeleteAccount(ctx, 1, "secret123"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pt.uc.GetProfile(ctx, 1); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Fatalf("expected the user to be gone, got %v", err)
+	}
+}
+
+func TestAccountChangesRefusedWithoutPassword(t *testing.T) {
+	ctx := context.Background()
+	pt := newProfileTest(t)
+	// As for a user provisioned by single sign-on.
+	pt.users.users["alice@example.com"].PasswordHash = ""
+
+	if err := pt.uc.ChangePassword(ctx, 1, 0, domain.ChangePasswordRequest{CurrentPassword: "", NewPassword: "newsecret"}); !errors.Is(err, domain.ErrPasswordNotSet) {
+		t.Fatalf("expected password not set, got %v", err)
+	}
+	if _, err := pt.uc.ChangeEmail(ctx, 1, 0, domain.ChangeEmailRequest{Email: "alice@example.org", Password: "anything"}); !errors.Is(err, domain.ErrPasswordNotSet) {
+		t.Fatalf("expected password not set, got %v", err)
+	}
+	if err := pt.uc.DeleteAccount(ctx, 1, "anything"); !errors.Is(err, domain.ErrPasswordNotSet) {
+		t.Fatalf("expected password not set, got %v", err)
+	}
+	if pt.users.users["alice@example.com"].PasswordHash != "" {
+		t.Fatal("expected no password to be set")
+	}
+}
@@ -0,0 +1,197 @@
+package usecase
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/logging"
+	"book-lending-api/internal/repository"
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	// sessionTouchInterval is how often a session's last use is
+	// recorded.
+	sessionTouchInterval = time.Minute
+	// maxUserAgentLength is how much of a client's user agent is kept.
+	maxUserAgentLength = 512
+)
+
+// SessionUseCase defines the operations for users' login sessions.
+type SessionUseCase interface {
+	Create(ctx context.Context, userID uint, ip, userAgent string) (*domain.Session, error)
+	List(ctx context.Context, userID, currentID uint) ([]domain.Session, error)
+	Revoke(ctx context.Context, userID, id uint) error
+	RevokeOthers(ctx context.Context, userID, currentID uint) (int64, error)
+	RevokeAll(ctx context.Context, userID uint) (int64, error)
+	Validate(ctx context.Context, userID, id uint, ip string) (*domain.User, error)
+}
+
+type sessionUseCase struct {
+	userRepo    repository.UserRepository
+	sessionRepo repository.SessionRepository
+	ttl         time.Duration
+	now         func() time.Time
+}
+
+// NewSessionUseCase constructs a new session use case.  Sessions last
+// ttl, which should match the lifetime of the access tokens issued for
+// them.
+func NewSessionUseCase(userRepo repository.UserRepository, sessionRepo repository.SessionRepository, ttl time.Duration) SessionUseCase {
+	return &sessionUseCase{userRepo: userRepo, sessionRepo: sessionRepo, ttl: ttl, now: time.Now}
+}
+
+// Create starts a session for a user logging in from ip with the given
+// user agent.  The user's expired sessions are cleared out first.
+func (uc *sessionUseCase) Create(ctx context.Context, userID uint, ip, userAgent string) (*domain.Session, error) {
+	now := uc.now()
+	if err := uc.sessionRepo.DeleteExpired(ctx, userID, now); err != nil {
+		return nil, err
+	}
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = strings.ToValidUTF8(userAgent[:maxUserAgentLength], "")
+	}
+	session := domain.Session{
+		UserID:     userID,
+		Device:     describeDevice(userAgent),
+		IP:         ip,
+		UserAgent:  userAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(uc.ttl),
+	}
+	if err := uc.sessionRepo.Create(ctx, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// List returns a user's active sessions, marking currentID as the
+// current one.
+func (uc *sessionUseCase) List(ctx context.Context, userID, currentID uint) ([]domain.Session, error) {
+	sessions, err := uc.sessionRepo.ListByUser(ctx, userID, uc.now())
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+	return sessions, nil
+}
+
+// Revoke ends one of a user's sessions, whose token stops working at
+// once.  Users may revoke their current session to log out.
+func (uc *sessionUseCase) Revoke(ctx context.Context, userID, id uint) error {
+	err := uc.sessionRepo.Delete(ctx, userID, id)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.ErrSessionNotFound
+	}
+	return err
+}
+
+// RevokeOthers ends all of a user's sessions except currentID and
+// returns how many were ended.
+func (uc *sessionUseCase) RevokeOthers(ctx context.Context, userID, currentID uint) (int64, error) {
+	n, err := uc.sessionRepo.DeleteOthers(ctx, userID, currentID)
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		logging.FromContext(ctx).Info("revoked other sessions", "user_id", userID, "count", n)
+	}
+	return n, nil
+}
+
+// RevokeAll ends all of a user's sessions and returns how many were
+// ended.
+func (uc *sessionUseCase) RevokeAll(ctx context.Context, userID uint) (int64, error) {
+	n, err := uc.sessionRepo.DeleteByUser(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		logging.FromContext(ctx).Info("revoked all sessions", "user_id", userID, "count", n)
+	}
+	return n, nil
+}
+
+// Validate checks that a token's session is still active, records that
+// it was used from ip and returns the session's user as they are now,
+// so that changes to their role apply at once.  Revoked and expired
+// sessions, sessions of other users and sessions of deleted users yield
+// domain.ErrSessionRevoked; sessions of suspended users yield
+// domain.ErrAccountSuspended.
+func (uc *sessionUseCase) Validate(ctx context.Context, userID, id uint, ip string) (*domain.User, error) {
+	now := uc.now()
+	_, err := uc.sessionRepo.Get(ctx, userID, id, now)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrSessionRevoked
+	} else if err != nil {
+		return nil, err
+	}
+	user, err := uc.userRepo.GetByID(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrSessionRevoked
+	} else if err != nil {
+		return nil, err
+	}
+	if user.Suspended() {
+		return nil, domain.ErrAccountSuspended
+	}
+	// Failing to record the use should not fail the request.
+	if err := uc.sessionRepo.Touch(ctx, id, ip, now, now.Add(-sessionTouchInterval)); err != nil {
+		logging.FromContext(ctx).Error("recording session use failed", "session_id", id, "error", err)
+	}
+	return user, nil
+}
+
+// userAgentBrowsers and userAgentPlatforms map markers found in user
+// agents to the names shown to users, most specific first: Edge and
+// Opera also claim to be Chrome, which claims to be Safari, and Android
+// claims to be Linux.
+var (
+	userAgentBrowsers = []struct{ marker, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"CriOS/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	}
+	userAgentPlatforms = []struct{ marker, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	}
+)
+
+// describeDevice names the browser and platform a user agent belongs
+// to, such as "Firefox on Linux", for users to recognise their
+// sessions by.
+func describeDevice(userAgent string) string {
+	find := func(markers []struct{ marker, name string }) string {
+		for _, m := range markers {
+			if strings.Contains(userAgent, m.marker) {
+				return m.name
+			}
+		}
+		return ""
+	}
+	browser, platform := find(userAgentBrowsers), find(userAgentPlatforms)
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	default:
+		return "Unknown device"
+	}
+}
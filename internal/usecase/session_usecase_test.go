@@ -0,0 +1,195 @@
+// Unit tests for SessionUseCase
+package usecase
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/repository"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mockSessionRepo struct {
+	sessions []domain.Session
+	nextID   uint
+}
+
+var _ repository.SessionRepository = (*mockSessionRepo)(nil)
+
+func (m *mockSessionRepo) Create(ctx context.Context, session *domain.Session) error {
+	m.nextID++
+	session.ID = m.nextID
+	m.sessions = append(m.sessions, *session)
+	return nil
+}
+
+func (m *mockSessionRepo) ListByUser(ctx context.Context, userID uint, now time.Time) ([]domain.Session, error) {
+	var sessions []domain.Session
+	for _, s := range m.sessions {
+		if s.UserID == userID && s.ExpiresAt.After(now) {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions, nil
+}
+
+func (m *mockSessionRepo) Get(ctx context.Context, userID, id uint, now time.Time) (*domain.Session, error) {
+	for _, s := range m.sessions {
+		if s.ID == id && s.UserID == userID && s.ExpiresAt.After(now) {
+			return &s, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (m *mockSessionRepo) Delete(ctx context.Context, userID, id uint) error {
+	for i, s := range m.sessions {
+		if s.ID == id && s.UserID == userID {
+			m.sessions = slices.Delete(m.sessions, i, i+1)
+			return nil
+		}
+	}
+	return domain.ErrNotFound
+}
+
+func (m *mockSessionRepo) DeleteOthers(ctx context.Context, userID, keepID uint) (int64, error) {
+	before := len(m.sessions)
+	m.sessions = slices.DeleteFunc(m.sessions, func(s domain.Session) bool { return s.UserID == userID && s.ID != keepID })
+	return int64(before - len(m.sessions)), nil
+}
+
+func (m *mockSessionRepo) DeleteByUser(ctx context.Context, userID uint) (int64, error) {
+	before := len(m.sessions)
+	m.sessions = slices.DeleteFunc(m.sessions, func(s domain.Session) bool { return s.UserID == userID })
+	return int64(before - len(m.sessions)), nil
+}
+
+func (m *mockSessionRepo) DeleteExpired(ctx context.Context, userID uint, now time.Time) error {
+	m.sessions = slices.DeleteFunc(m.sessions, func(s domain.Session) bool { return s.UserID == userID && !s.ExpiresAt.After(now) })
+	return nil
+}
+
+func (m *mockSessionRepo) Touch(ctx context.Context, id uint, ip string, now, staleBefore time.Time) error {
+	for i := range m.sessions {
+		s := &m.sessions[i]
+		if s.ID == id && (s.LastSeenAt.Before(staleBefore) || s.IP != ip) {
+			s.LastSeenAt = now
+			s.IP = ip
+		}
+	}
+	return nil
+}
+
+// newSessionTest returns a use case for two users, alice and bob, and
+// a controllable clock.
+func newSessionTest() (*sessionUseCase, *mockSessionRepo, *mockUserRepo, *time.Time) {
+	users := &mockUserRepo{users: map[string]*domain.User{
+		"alice@example.com": {ID: 1, Email: "alice@example.com"},
+		"bob@example.com":   {ID: 2, Email: "bob@example.com"},
+	}}
+	repo := &mockSessionRepo{}
+	now := time.Unix(1_700_000_000, 0)
+	uc := NewSessionUseCase(users, repo, time.Hour).(*sessionUseCase)
+	uc.now = func() time.Time { return now }
+	return uc, repo, users, &now
+}
+
+const firefoxOnLinux = "Mozilla/5.0 (X11; Linux x86_64; rv:131.0) Gecko/20100101 Firefox/131.0"
+
+func TestSessionLifecycle(t *testing.T) {
+	ctx := context.Background()
+	uc, repo, _, now := newSessionTest()
+
+	laptop, err := uc.Create(ctx, 1, "192.0.2.1", firefoxOnLinux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if laptop.Device != "Firefox on Linux" || !laptop.ExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Fatalf("unexpected session %+v", laptop)
+	}
+	phone, _ := uc.Create(ctx, 1, "192.0.2.2", "curl/8.0")
+	other, _ := uc.Create(ctx, 2, "192.0.2.3", "")
+
+	*now = now.Add(2 * time.Minute)
+	if _, err := uc.Validate(ctx, 1, phone.ID, "192.0.2.9"); err != nil {
+		t.Fatal(err)
+	}
+	if s := repo.sessions[1]; s.IP != "192.0.2.9" || !s.LastSeenAt.Equal(*now) {
+		t.Fatalf("expected the use to be recorded, got %+v", s)
+	}
+	if _, err := uc.Validate(ctx, 1, other.ID, "192.0.2.1"); !errors.Is(err, domain.ErrSessionRevoked) {
+		t.Fatalf("expected another user's session to be refused, got %v", err)
+	}
+
+	sessions, err := uc.List(ctx, 1, laptop.ID)
+	if err != nil || len(sessions) != 2 || !sessions[0].Current || sessions[1].Current {
+		t.Fatalf("expected the laptop to be current, got %+v err=%v", sessions, err)
+	}
+
+	if err := uc.Revoke(ctx, 2, phone.ID); !errors.Is(err, domain.ErrSessionNotFound) {
+		t.Fatalf("expected another user's revoke to be refused, got %v", err)
+	}
+	if n, err := uc.RevokeOthers(ctx, 1, laptop.ID); err != nil || n != 1 {
+		t.Fatalf("expected the phone to be revoked, got %d err=%v", n, err)
+	}
+	if _, err := uc.Validate(ctx, 1, phone.ID, "192.0.2.2"); !errors.Is(err, domain.ErrSessionRevoked) {
+		t.Fatalf("expected a revoked session to be refused, got %v", err)
+	}
+	if err := uc.Revoke(ctx, 1, laptop.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uc.Validate(ctx, 1, laptop.ID, "192.0.2.1"); !errors.Is(err, domain.ErrSessionRevoked) {
+		t.Fatalf("expected a logged out session to be refused, got %v", err)
+	}
+
+	*now = now.Add(time.Hour)
+	if _, err := uc.Validate(ctx, 2, other.ID, "192.0.2.3"); !errors.Is(err, domain.ErrSessionRevoked) {
+		t.Fatalf("expected an expired session to be refused, got %v", err)
+	}
+	if _, err := uc.Create(ctx, 2, "192.0.2.3", strings.Repeat("x", 1000)); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.sessions) != 1 || len(repo.sessions[0].UserAgent) != maxUserAgentLength {
+		t.Fatalf("expected expired sessions to be cleared and the user agent truncated, got %+v", repo.sessions)
+	}
+}
+
+func TestSessionValidateChecksUser(t *testing.T) {
+	ctx := context.Background()
+	uc, _, users, _ := newSessionTest()
+
+	alice, _ := uc.Create(ctx, 1, "192.0.2.1", "")
+	bob, _ := uc.Create(ctx, 2, "192.0.2.2", "")
+	suspendedAt := time.Unix(1_700_000_000, 0)
+	if err := users.SetSuspended(ctx, 1, &suspendedAt); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uc.Validate(ctx, 1, alice.ID, "192.0.2.1"); !errors.Is(err, domain.ErrAccountSuspended) {
+		t.Fatalf("expected a suspended user's session to be refused, got %v", err)
+	}
+	if err := users.Delete(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uc.Validate(ctx, 2, bob.ID, "192.0.2.2"); !errors.Is(err, domain.ErrSessionRevoked) {
+		t.Fatalf("expected a deleted user's session to be refused, got %v", err)
+	}
+}
+
+func TestDescribeDevice(t *testing.T) {
+	for ua, want := range map[string]string{
+		firefoxOnLinux: "Firefox on Linux",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15":                          "Safari on macOS",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36 Edg/129.0.0.0":                  "Edge on Windows",
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Mobile Safari/537.36":                          "Chrome on Android",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/129.0.6668.69 Mobile/15E148 Safari/604.1": "Chrome on iOS",
+		"curl/8.5.0": "curl",
+		"":           "Unknown device",
+	} {
+		if got := describeDevice(ua); got != want {
+			t.Errorf("describeDevice(%q) = %q, want %q", ua, got, want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package usecase
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/logging"
+	"book-lending-api/internal/oidc"
+	"book-lending-api/internal/repository"
+	"context"
+	"errors"
+	"time"
+)
+
+// SSOUseCase defines single sign-on through an OpenID Connect
+// provider.
+type SSOUseCase interface {
+	Begin(ctx context.Context) (authURL, state string, err error)
+	Complete(ctx context.Context, state, code string) (*domain.LoginResult, error)
+}
+
+type ssoUseCase struct {
+	userRepo    repository.UserRepository
+	ssoRepo     repository.SSORepository
+	credentials userCredentials
+	provider    oidc.Provider
+	mfa         MFAUseCase
+	loginTTL    time.Duration
+	now         func() time.Time
+}
+
+// userCredentials are the stores holding what lets someone act as a
+// user besides their password.
+type userCredentials struct {
+	sessions repository.SessionRepository
+	apiKeys  repository.APIKeyRepository
+	mfa      repository.MFARepository
+	tokens   repository.UserTokenRepository
+}
+
+// NewSSOUseCase constructs a new single sign-on use case.  Users have
+// loginTTL to sign in at provider and come back, and users with
+// two-factor authentication enabled are checked by mfa afterwards.
+// When the owner of an address takes over an account registered with
+// it but never verified, that account's sessions, API keys, two-factor
+// settings and tokens are cleared from the given repositories.
+func NewSSOUseCase(userRepo repository.UserRepository, ssoRepo repository.SSORepository, sessionRepo repository.SessionRepository, apiKeyRepo repository.APIKeyRepository, mfaRepo repository.MFARepository, tokens repository.UserTokenRepository, provider oidc.Provider, mfa MFAUseCase, loginTTL time.Duration) SSOUseCase {
+	return &ssoUseCase{
+		userRepo:    userRepo,
+		ssoRepo:     ssoRepo,
+		credentials: userCredentials{sessions: sessionRepo, apiKeys: apiKeyRepo, mfa: mfaRepo, tokens: tokens},
+		provider:    provider,
+		mfa:         mfa,
+		loginTTL:    loginTTL,
+		now:         time.Now,
+	}
+}
+
+// Begin starts a sign-in and returns the provider page to send the user
+// to, along with the state the provider will send them back with.
+// Callers should keep the state in the user's browser and only
+// complete sign-ins that come back to the same browser, so that nobody
+// can sign a victim in to the attacker's account.
+func (uc *ssoUseCase) Begin(ctx context.Context) (string, string, error) {
+	now := uc.now()
+	if err := uc.ssoRepo.DeleteExpiredLogins(ctx, now); err != nil {
+		return "", "", err
+	}
+	state, stateHash, err := newToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, _, err := newToken()
+	if err != nil {
+		return "", "", err
+	}
+	// A token is 43 unreserved characters, a valid PKCE verifier.
+	verifier, _, err := newToken()
+	if err != nil {
+		return "", "", err
+	}
+	err = uc.ssoRepo.CreateLogin(ctx, &domain.SSOLogin{
+		StateHash:    stateHash,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    now.Add(uc.loginTTL),
+		CreatedAt:    now,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	authURL, err := uc.provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", "", err
+	}
+	return authURL, state, nil
+}
+
+// Complete finishes the sign-in started by Begin with the state and
+// code the provider sent the user back with.  It returns
+// domain.ErrInvalidSSOState for unknown, used or expired states and
+// domain.ErrSSOFailed if the provider does not vouch for the user.
+// The user is found by their provider account, or else linked or
+// provisioned by email address, which the provider must have verified.
+func (uc *ssoUseCase) Complete(ctx context.Context, state, code string) (*domain.LoginResult, error) {
+	login, err := uc.ssoRepo.ConsumeLogin(ctx, hashToken(state), uc.now())
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrInvalidSSOState
+	} else if err != nil {
+		return nil, err
+	}
+	identity, err := uc.provider.Exchange(ctx, code, login.CodeVerifier, login.Nonce)
+	if err != nil {
+		return nil, domain.ErrSSOFailed.WithCause(err)
+	}
+	user, err := uc.resolve(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+	if user.Suspended() {
+		return nil, domain.ErrAccountSuspended
+	}
+	enabled, err := uc.mfa.Enabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.LoginResult{User: user, MFARequired: enabled}, nil
+}
+
+// resolve returns the user identity signs in as, linking the provider
+// account to the user with the same email address or creating a new
+// member on first sign-in.
+func (uc *ssoUseCase) resolve(ctx context.Context, identity *oidc.Identity) (*domain.User, error) {
+	linked, err := uc.ssoRepo.GetIdentity(ctx, identity.Issuer, identity.Subject)
+	if err == nil {
+		return uc.userRepo.GetByID(ctx, linked.UserID)
+	} else if !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, domain.ErrSSOEmailNotVerified
+	}
+	now := uc.now()
+	email := domain.NormalizeEmail(identity.Email)
+	user, err := uc.userRepo.GetByEmail(ctx, email)
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		// Provisioned users have no password until they reset one.
+		user = &domain.User{
+			Email:           email,
+			Name:            identity.Name,
+			Role:            domain.RoleMember,
+			EmailVerifiedAt: &now,
+		}
+		if err := uc.userRepo.Create(ctx, user); errors.Is(err, domain.ErrConflict) {
+			// The address was registered since the lookup above; the
+			// user can simply sign in again.
+			return nil, domain.ErrEmailTaken.WithCause(err)
+		} else if err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	case !user.EmailVerified():
+		// Whoever registered the address never proved they own it, so
+		// nothing they set up may keep working once the owner signs in.
+		if err := uc.userRepo.UpdatePassword(ctx, user.ID, ""); err != nil {
+			return nil, err
+		}
+		if err := uc.credentials.clear(ctx, user.ID); err != nil {
+			return nil, err
+		}
+		if err := uc.userRepo.MarkEmailVerified(ctx, user.ID, now); err != nil {
+			return nil, err
+		}
+		user.PasswordHash = ""
+		user.EmailVerifiedAt = &now
+	}
+	err = uc.ssoRepo.CreateIdentity(ctx, &domain.UserIdentity{
+		UserID:    user.ID,
+		Issuer:    identity.Issuer,
+		Subject:   identity.Subject,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("linked single sign-on account", "user_id", user.ID, "issuer", identity.Issuer)
+	return user, nil
+}
+
+// clear revokes a user's sessions and API keys and removes their
+// two-factor settings and outstanding email tokens.
+func (c userCredentials) clear(ctx context.Context, userID uint) error {
+	if _, err := c.sessions.DeleteByUser(ctx, userID); err != nil {
+		return err
+	}
+	if _, err := c.apiKeys.DeleteByUser(ctx, userID); err != nil {
+		return err
+	}
+	if err := c.mfa.Delete(ctx, userID); err != nil {
+		return err
+	}
+	for _, purpose := range []string{domain.TokenPasswordReset, domain.TokenEmailVerification} {
+		if err := c.tokens.DeleteForUser(ctx, userID, purpose); err != nil {
+			return err
+		}
+	}
+	return nil
+}
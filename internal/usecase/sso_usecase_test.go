@@ -0,0 +1,214 @@
+// Unit tests for SSOUseCase against a mock OpenID Connect provider
+package usecase
+
+import (
+	"book-lending-api/internal/config"
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/oidc"
+	"book-lending-api/internal/oidc/oidctest"
+	"book-lending-api/internal/repository"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type mockSSORepo struct {
+	logins     map[string]domain.SSOLogin
+	identities []domain.UserIdentity
+}
+
+var _ repository.SSORepository = (*mockSSORepo)(nil)
+
+func (m *mockSSORepo) CreateLogin(ctx context.Context, login *domain.SSOLogin) error {
+	m.logins[login.StateHash] = *login
+	return nil
+}
+
+func (m *mockSSORepo) ConsumeLogin(ctx context.Context, stateHash string, now time.Time) (*domain.SSOLogin, error) {
+	login, ok := m.logins[stateHash]
+	if !ok || !login.ExpiresAt.After(now) {
+		return nil, domain.ErrNotFound
+	}
+	delete(m.logins, stateHash)
+	return &login, nil
+}
+
+func (m *mockSSORepo) DeleteExpiredLogins(ctx context.Context, now time.Time) error {
+	for hash, login := range m.logins {
+		if !login.ExpiresAt.After(now) {
+			delete(m.logins, hash)
+		}
+	}
+	return nil
+}
+
+func (m *mockSSORepo) GetIdentity(ctx context.Context, issuer, subject string) (*domain.UserIdentity, error) {
+	for _, identity := range m.identities {
+		if identity.Issuer == issuer && identity.Subject == subject {
+			return &identity, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (m *mockSSORepo) CreateIdentity(ctx context.Context, identity *domain.UserIdentity) error {
+	m.identities = append(m.identities, *identity)
+	return nil
+}
+
+type ssoTest struct {
+	uc       SSOUseCase
+	users    *mockUserRepo
+	sso      *mockSSORepo
+	sessions *mockSessionRepo
+	apiKeys  *mockAPIKeyRepo
+	mfa      *mockMFARepo
+	tokens   *mockTokenRepo
+	provider *oidctest.Provider
+}
+
+// newSSOTest returns a use case signing in through a mock provider.
+// The library already has alice, whose address is verified, and
+// mallory, who registered bob's university address without verifying
+// it.
+func newSSOTest(t *testing.T) *ssoTest {
+	t.Helper()
+	provider := oidctest.NewProvider("library")
+	t.Cleanup(provider.Close)
+	verifiedAt := time.Unix(1_700_000_000, 0)
+	st := &ssoTest{
+		users: &mockUserRepo{users: map[string]*domain.User{
+			"alice@uni.example": {ID: 1, Email: "alice@uni.example", PasswordHash: "alice-hash", EmailVerifiedAt: &verifiedAt},
+			"bob@uni.example":   {ID: 2, Email: "bob@uni.example", PasswordHash: "mallory-hash"},
+		}},
+		sso:      &mockSSORepo{logins: map[string]domain.SSOLogin{}},
+		sessions: &mockSessionRepo{},
+		apiKeys:  &mockAPIKeyRepo{},
+		mfa:      newMockMFARepo(),
+		tokens:   &mockTokenRepo{},
+		provider: provider,
+	}
+	client := oidc.New(config.OIDCConfig{
+		IssuerURL:   provider.URL,
+		ClientID:    "library",
+		RedirectURL: "https://api.example.com/api/v1/auth/oidc/callback",
+		Scopes:      []string{"openid", "email"},
+	}, provider.Client())
+	mfa := NewMFAUseCase(st.users, st.mfa, &mockThrottleRepo{throttles: map[string]domain.LoginThrottle{}}, domain.DefaultLoginPolicy, "Test", nil)
+	st.uc = NewSSOUseCase(st.users, st.sso, st.sessions, st.apiKeys, st.mfa, st.tokens, client, mfa, time.Minute)
+	return st
+}
+
+// signIn runs a whole sign-in as user.
+func (st *ssoTest) signIn(t *testing.T, user oidctest.User) (*domain.LoginResult, error) {
+	t.Helper()
+	st.provider.SetUser(user)
+	authURL, _, err := st.uc.Begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state, err := st.provider.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st.uc.Complete(context.Background(), state, code)
+}
+
+func TestSSOLinksProvisionsAndRemembers(t *testing.T) {
+	st := newSSOTest(t)
+
+	// Addresses match whatever their case.
+	result, err := st.signIn(t, oidctest.User{Subject: "a", Email: "Alice@Uni.Example", EmailVerified: true})
+	if err != nil || result.User.ID != 1 || result.MFARequired {
+		t.Fatalf("expected alice to be linked, got %+v err=%v", result, err)
+	}
+	// The provider account stays linked when its address changes.
+	result, err = st.signIn(t, oidctest.User{Subject: "a", Email: "alice.liddell@uni.example", EmailVerified: true})
+	if err != nil || result.User.ID != 1 {
+		t.Fatalf("expected alice by her provider account, got %+v err=%v", result, err)
+	}
+
+	result, err = st.signIn(t, oidctest.User{Subject: "c", Email: "Carol@Uni.Example", EmailVerified: true, Name: "Carol"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	carol := result.User
+	if carol.ID == 0 || carol.Email != "carol@uni.example" || carol.Name != "Carol" || carol.Role != domain.RoleMember || !carol.EmailVerified() {
+		t.Fatalf("expected carol to be provisioned as a verified member, got %+v", carol)
+	}
+	if len(st.sso.identities) != 2 {
+		t.Fatalf("expected two linked accounts, got %+v", st.sso.identities)
+	}
+}
+
+func TestSSOTakesOverUnverifiedAccount(t *testing.T) {
+	st := newSSOTest(t)
+	ctx := context.Background()
+	// Everything mallory set up on bob's address, and one of alice's
+	// sessions that must survive.
+	_ = st.sessions.Create(ctx, &domain.Session{UserID: 2, ExpiresAt: time.Now().Add(time.Hour)})
+	_ = st.sessions.Create(ctx, &domain.Session{UserID: 1, ExpiresAt: time.Now().Add(time.Hour)})
+	_ = st.apiKeys.Create(ctx, &domain.APIKey{UserID: 2, KeyHash: "mallory-key"})
+	enabledAt := time.Now()
+	_ = st.mfa.Save(ctx, &domain.UserMFA{UserID: 2, Secret: "MALLORY", EnabledAt: &enabledAt})
+	_ = st.mfa.ReplaceRecoveryCodes(ctx, 2, []string{"mallory-code"})
+	_ = st.tokens.Create(ctx, &domain.UserToken{UserID: 2, Purpose: domain.TokenPasswordReset, TokenHash: "reset", ExpiresAt: time.Now().Add(time.Hour)})
+	_ = st.tokens.Create(ctx, &domain.UserToken{UserID: 2, Purpose: domain.TokenEmailVerification, TokenHash: "verify", ExpiresAt: time.Now().Add(time.Hour)})
+
+	result, err := st.signIn(t, oidctest.User{Subject: "b", Email: "bob@uni.example", EmailVerified: true})
+	if err != nil || result.User.ID != 2 {
+		t.Fatalf("expected bob's address to be linked, got %+v err=%v", result, err)
+	}
+	bob := st.users.users["bob@uni.example"]
+	if bob.PasswordHash != "" || !bob.EmailVerified() {
+		t.Fatalf("expected the unverified password to be dropped, got %+v", bob)
+	}
+	if result.MFARequired {
+		t.Fatal("expected mallory's two-factor secret not to apply")
+	}
+	if len(st.sessions.sessions) != 1 || st.sessions.sessions[0].UserID != 1 {
+		t.Fatalf("expected only mallory's sessions to be revoked, got %+v", st.sessions.sessions)
+	}
+	if len(st.apiKeys.keys) != 0 {
+		t.Fatalf("expected mallory's API keys to be revoked, got %+v", st.apiKeys.keys)
+	}
+	if _, ok := st.mfa.secrets[2]; ok || len(st.mfa.codes[2]) != 0 {
+		t.Fatal("expected mallory's two-factor secret and recovery codes to be removed")
+	}
+	if len(st.tokens.tokens) != 0 {
+		t.Fatalf("expected mallory's tokens to be removed, got %+v", st.tokens.tokens)
+	}
+}
+
+func TestSSORefusals(t *testing.T) {
+	st := newSSOTest(t)
+	ctx := context.Background()
+
+	if _, err := st.signIn(t, oidctest.User{Subject: "d", Email: "dave@uni.example"}); !errors.Is(err, domain.ErrSSOEmailNotVerified) {
+		t.Fatalf("expected an unverified address to be refused, got %v", err)
+	}
+
+	st.provider.SetUser(oidctest.User{Subject: "a", Email: "alice@uni.example", EmailVerified: true})
+	authURL, begun, _ := st.uc.Begin(ctx)
+	code, state, _ := st.provider.Authorize(authURL)
+	if state != begun {
+		t.Fatalf("expected the provider to return state %q, got %q", begun, state)
+	}
+	if _, err := st.uc.Complete(ctx, state+"x", code); !errors.Is(err, domain.ErrInvalidSSOState) {
+		t.Fatalf("expected an unknown state to be refused, got %v", err)
+	}
+	if _, err := st.uc.Complete(ctx, state, "forged"); !errors.Is(err, domain.ErrSSOFailed) {
+		t.Fatalf("expected a forged code to be refused, got %v", err)
+	}
+	// The failed attempt used the state up.
+	if _, err := st.uc.Complete(ctx, state, code); !errors.Is(err, domain.ErrInvalidSSOState) {
+		t.Fatalf("expected a state to be usable once, got %v", err)
+	}
+
+	now := time.Now()
+	st.users.users["alice@uni.example"].SuspendedAt = &now
+	if _, err := st.signIn(t, oidctest.User{Subject: "a", Email: "alice@uni.example", EmailVerified: true}); !errors.Is(err, domain.ErrAccountSuspended) {
+		t.Fatalf("expected a suspended user to be refused, got %v", err)
+	}
+}
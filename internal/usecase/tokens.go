@@ -0,0 +1,58 @@
+package usecase
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/repository"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// newToken returns a random token to hand to a user and the hash under
+// which it is stored.  Only the hash is kept, so a leaked database does
+// not reveal usable tokens.
+func newToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+// hashToken returns the stored form of token.  Tokens carry enough
+// entropy that a fast, unsalted hash is sufficient.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueToken replaces any tokens of the given purpose held by a user
+// with a new one valid for ttl, and returns the new token.
+func issueToken(ctx context.Context, tokens repository.UserTokenRepository, userID uint, purpose string, ttl time.Duration, now time.Time) (string, error) {
+	if err := tokens.DeleteForUser(ctx, userID, purpose); err != nil {
+		return "", err
+	}
+	token, hash, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	err = tokens.Create(ctx, &domain.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	})
+	return token, err
+}
+
+// tokenLink fills a link template with token.  It returns "" for an
+// empty template.
+func tokenLink(template, token string) string {
+	return strings.ReplaceAll(template, "{token}", token)
+}
@@ -0,0 +1,126 @@
+// Unit tests for UserAdminUseCase
+package usecase
+
+import (
+	"book-lending-api/internal/domain"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var (
+	admin     = domain.Actor{ID: 1, Role: domain.RoleAdmin}
+	librarian = domain.Actor{ID: 2, Role: domain.RoleLibrarian}
+)
+
+// newUserAdminTest returns a use case over an admin, a librarian and
+// two members, carol and dave.
+func newUserAdminTest() (*userAdminUseCase, *mockUserRepo) {
+	users := &mockUserRepo{users: map[string]*domain.User{
+		"admin@example.com":     {ID: 1, Email: "admin@example.com", Role: domain.RoleAdmin},
+		"librarian@example.com": {ID: 2, Email: "librarian@example.com", Role: domain.RoleLibrarian},
+		"carol@example.com":     {ID: 3, Email: "carol@example.com", Name: "Carol Danvers", Role: domain.RoleMember},
+		"dave@example.com":      {ID: 4, Email: "dave@example.com", Name: "Dave Lister", Role: domain.RoleMember},
+	}}
+	uc := NewUserAdminUseCase(users, &mockSessionRepo{}, &mockAPIKeyRepo{}).(*userAdminUseCase)
+	uc.now = func() time.Time { return time.Unix(1_700_000_000, 0) }
+	return uc, users
+}
+
+func TestListUsersFiltersAndPaginates(t *testing.T) {
+	uc, _ := newUserAdminTest()
+	ctx := context.Background()
+
+	resp, err := uc.ListUsers(ctx, domain.UserFilter{Role: domain.RoleMember}, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := resp.Data.([]domain.User)
+	if resp.Total != 2 || resp.TotalPages != 2 || len(users) != 1 || users[0].ID != 3 {
+		t.Fatalf("unexpected first page %+v", resp)
+	}
+	resp, _ = uc.ListUsers(ctx, domain.UserFilter{Query: "LISTER"}, 1, 10)
+	if users := resp.Data.([]domain.User); len(users) != 1 || users[0].ID != 4 {
+		t.Fatalf("expected a name search to find dave, got %+v", resp.Data)
+	}
+}
+
+func TestUserAdminRoleChecks(t *testing.T) {
+	uc, _ := newUserAdminTest()
+	ctx := context.Background()
+
+	if _, err := uc.Suspend(ctx, admin, admin.ID); !errors.Is(err, domain.ErrCannotModifySelf) {
+		t.Fatalf("expected self-suspension to be refused, got %v", err)
+	}
+	if _, err := uc.ChangeRole(ctx, admin, admin.ID, domain.RoleMember); !errors.Is(err, domain.ErrCannotModifySelf) {
+		t.Fatalf("expected self-demotion to be refused, got %v", err)
+	}
+	if _, err := uc.Suspend(ctx, librarian, admin.ID); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected a librarian to be refused on staff, got %v", err)
+	}
+	if _, err := uc.Suspend(ctx, admin, librarian.ID); err != nil {
+		t.Fatalf("expected an admin to suspend a librarian, got %v", err)
+	}
+	if _, err := uc.ResetBorrowLimit(ctx, librarian, 42); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Fatalf("expected user not found, got %v", err)
+	}
+
+	user, err := uc.ChangeRole(ctx, admin, 3, domain.RoleLibrarian)
+	if err != nil || user.Role != domain.RoleLibrarian {
+		t.Fatalf("expected carol to become a librarian, got %+v err=%v", user, err)
+	}
+}
+
+func TestSuspendAndReactivate(t *testing.T) {
+	uc, users := newUserAdminTest()
+	ctx := context.Background()
+
+	if _, err := uc.Reactivate(ctx, librarian, 3); !errors.Is(err, domain.ErrNotSuspended) {
+		t.Fatalf("expected not suspended, got %v", err)
+	}
+	user, err := uc.Suspend(ctx, librarian, 3)
+	if err != nil || !user.Suspended() || !users.users["carol@example.com"].Suspended() {
+		t.Fatalf("expected carol to be suspended, got %+v err=%v", user, err)
+	}
+	if _, err := uc.Suspend(ctx, librarian, 3); !errors.Is(err, domain.ErrAlreadySuspended) {
+		t.Fatalf("expected already suspended, got %v", err)
+	}
+	if user, err := uc.Reactivate(ctx, librarian, 3); err != nil || user.Suspended() {
+		t.Fatalf("expected carol to be reactivated, got %+v err=%v", user, err)
+	}
+}
+
+func TestSuspendAndChangeRoleRevokeCredentials(t *testing.T) {
+	uc, users := newUserAdminTest()
+	ctx := context.Background()
+	sessions := NewSessionUseCase(users, uc.sessionRepo, time.Hour)
+	keys := NewAPIKeyUseCase(users, uc.apiKeyRepo)
+
+	for _, tc := range []struct {
+		name   string
+		userID uint
+		act    func() error
+	}{
+		{"suspend", 3, func() error { _, err := uc.Suspend(ctx, librarian, 3); return err }},
+		{"change role", 4, func() error { _, err := uc.ChangeRole(ctx, admin, 4, domain.RoleLibrarian); return err }},
+	} {
+		session, err := sessions.Create(ctx, tc.userID, "192.0.2.1", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := keys.Create(ctx, tc.userID, false, domain.CreateAPIKeyRequest{Name: "backup", Scopes: []string{domain.ScopeLendingRead}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tc.act(); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if _, err := sessions.Validate(ctx, tc.userID, session.ID, "192.0.2.1"); !errors.Is(err, domain.ErrSessionRevoked) {
+			t.Errorf("%s: expected the old session to be revoked, got %v", tc.name, err)
+		}
+		if _, _, err := keys.Authenticate(ctx, key.Key); !errors.Is(err, domain.ErrInvalidAPIKey) {
+			t.Errorf("%s: expected the old key to be revoked, got %v", tc.name, err)
+		}
+	}
+}
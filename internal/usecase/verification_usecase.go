@@ -0,0 +1,120 @@
+package usecase
+
+import (
+	"book-lending-api/internal/domain"
+	"book-lending-api/internal/logging"
+	"book-lending-api/internal/mail"
+	"book-lending-api/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// VerificationUseCase defines the operations for confirming that users
+// own their email addresses.
+type VerificationUseCase interface {
+	SendVerification(ctx context.Context, user *domain.User) error
+	ResendVerification(ctx context.Context, userID uint) error
+	VerifyEmail(ctx context.Context, token string) (*domain.User, error)
+}
+
+type verificationUseCase struct {
+	userRepo  repository.UserRepository
+	tokens    repository.UserTokenRepository
+	mailer    mail.Mailer
+	ttl       time.Duration
+	verifyURL string
+	now       func() time.Time
+}
+
+// NewVerificationUseCase constructs a new email verification use case.
+// Verification tokens are valid for ttl.  verifyURL, if not empty, is a
+// link template in which {token} is replaced and which is included in
+// verification emails.
+func NewVerificationUseCase(userRepo repository.UserRepository, tokens repository.UserTokenRepository, mailer mail.Mailer, ttl time.Duration, verifyURL string) VerificationUseCase {
+	return &verificationUseCase{
+		userRepo:  userRepo,
+		tokens:    tokens,
+		mailer:    mailer,
+		ttl:       ttl,
+		verifyURL: verifyURL,
+		now:       time.Now,
+	}
+}
+
+// SendVerification emails a verification token to user, replacing any
+// token sent earlier.  A failure to send is logged rather than
+// returned; the user can ask for another email.
+func (uc *verificationUseCase) SendVerification(ctx context.Context, user *domain.User) error {
+	token, err := issueToken(ctx, uc.tokens, user.ID, domain.TokenEmailVerification, uc.ttl, uc.now())
+	if err != nil {
+		return err
+	}
+	if err := uc.mailer.Send(ctx, uc.verificationMessage(user.Email, token)); err != nil {
+		logging.FromContext(ctx).Error("sending verification email failed", "user_id", user.ID, "error", err)
+	}
+	return nil
+}
+
+// ResendVerification sends a fresh verification email to a user who
+// has not verified their address yet, and returns
+// domain.ErrAlreadyVerified for one who has.
+func (uc *verificationUseCase) ResendVerification(ctx context.Context, userID uint) error {
+	user, err := uc.userRepo.GetByID(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified() {
+		return domain.ErrAlreadyVerified
+	}
+	return uc.SendVerification(ctx, user)
+}
+
+func (uc *verificationUseCase) verificationMessage(to, token string) mail.Message {
+	var b strings.Builder
+	b.WriteString("Welcome!  Please confirm that this is your email address.\n\n")
+	if uc.verifyURL != "" {
+		fmt.Fprintf(&b, "To verify it, open this link:\n\n%s\n\n", tokenLink(uc.verifyURL, token))
+	} else {
+		fmt.Fprintf(&b, "To verify it, use this verification token:\n\n%s\n\n", token)
+	}
+	fmt.Fprintf(&b, "It expires in %s.  If you did not create an account, you can ignore this email.\n", uc.ttl)
+	return mail.Message{To: to, Subject: "Verify your email address", Body: b.String()}
+}
+
+// VerifyEmail marks the address of the user a verification token was
+// issued to as verified and returns the user.  It returns
+// domain.ErrInvalidVerifyToken for tokens that are unknown, expired or
+// already used.
+func (uc *verificationUseCase) VerifyEmail(ctx context.Context, token string) (*domain.User, error) {
+	now := uc.now()
+	consumed, err := uc.tokens.Consume(ctx, domain.TokenEmailVerification, hashToken(token), now)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrInvalidVerifyToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	user, err := uc.userRepo.GetByID(ctx, consumed.UserID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrInvalidVerifyToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !user.EmailVerified() {
+		if err := uc.userRepo.MarkEmailVerified(ctx, user.ID, now); err != nil {
+			return nil, err
+		}
+		user.EmailVerifiedAt = &now
+	}
+	if err := uc.tokens.DeleteForUser(ctx, user.ID, domain.TokenEmailVerification); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,126 @@
+// Unit tests for VerificationUseCase
+package usecase
+
+import (
+	"book-lending-api/internal/domain"
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var verifyTokenPattern = regexp.MustCompile(`verify\?token=([A-Za-z0-9_-]{43})`)
+
+// newVerificationTest returns an auth use case that sends verification
+// email through the returned verification use case and mailer.
+func newVerificationTest(t *testing.T) (AuthUseCase, *verificationUseCase, *recordingMailer) {
+	t.Helper()
+	users := &mockUserRepo{users: map[string]*domain.User{}}
+	mailer := &recordingMailer{}
+	verification := NewVerificationUseCase(users, &mockTokenRepo{}, mailer, time.Hour, "https://library.example.com/verify?token={token}").(*verificationUseCase)
+	throttles := &mockThrottleRepo{throttles: map[string]domain.LoginThrottle{}}
+	mfa := NewMFAUseCase(users, newMockMFARepo(), throttles, domain.DefaultLoginPolicy, "Test", nil)
+	auth := NewAuthUseCase(users, throttles, verification, mfa, domain.DefaultLoginPolicy)
+	return auth, verification, mailer
+}
+
+func lastVerifyToken(t *testing.T, mailer *recordingMailer) string {
+	t.Helper()
+	if len(mailer.sent) == 0 {
+		t.Fatal("no email was sent")
+	}
+	m := verifyTokenPattern.FindStringSubmatch(mailer.sent[len(mailer.sent)-1].Body)
+	if m == nil {
+		t.Fatalf("no token in %q", mailer.sent[len(mailer.sent)-1].Body)
+	}
+	return m[1]
+}
+
+// staleUserRepo misses users on lookup, as if they had been created
+// just after it.
+type staleUserRepo struct{ *mockUserRepo }
+
+func (staleUserRepo) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	return nil, domain.ErrNotFound
+}
+
+func TestRegisterReportsEmailTakenConcurrently(t *testing.T) {
+	users := staleUserRepo{&mockUserRepo{users: map[string]*domain.User{"alice@example.com": {ID: 1, Email: "alice@example.com"}}}}
+	throttles := &mockThrottleRepo{throttles: map[string]domain.LoginThrottle{}}
+	verification := NewVerificationUseCase(users, &mockTokenRepo{}, &recordingMailer{}, time.Hour, "")
+	mfa := NewMFAUseCase(users, newMockMFARepo(), throttles, domain.DefaultLoginPolicy, "Test", nil)
+	auth := NewAuthUseCase(users, throttles, verification, mfa, domain.DefaultLoginPolicy)
+
+	_, err := auth.Register(context.Background(), domain.RegisterRequest{Email: "alice@example.com", Password: "secret123"})
+	if !errors.Is(err, domain.ErrEmailTaken) {
+		t.Fatalf("expected ErrEmailTaken, got %v", err)
+	}
+}
+
+func TestRegisterSendsVerification(t *testing.T) {
+	ctx := context.Background()
+	auth, verification, mailer := newVerificationTest(t)
+
+	user, err := auth.Register(ctx, domain.RegisterRequest{Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.EmailVerified() {
+		t.Fatal("new users should start unverified")
+	}
+	if mailer.sent[0].To != "alice@example.com" {
+		t.Fatalf("unexpected recipient %q", mailer.sent[0].To)
+	}
+	token := lastVerifyToken(t, mailer)
+
+	verified, err := verification.VerifyEmail(ctx, token)
+	if err != nil || !verified.EmailVerified() || verified.ID != user.ID {
+		t.Fatalf("unexpected result %+v err=%v", verified, err)
+	}
+	if _, err := verification.VerifyEmail(ctx, token); !errors.Is(err, domain.ErrInvalidVerifyToken) {
+		t.Fatalf("expected a used token to be refused, got %v", err)
+	}
+	if err := verification.ResendVerification(ctx, user.ID); !errors.Is(err, domain.ErrAlreadyVerified) {
+		t.Fatalf("expected verified users to be refused a resend, got %v", err)
+	}
+}
+
+func TestResendVerificationReplacesToken(t *testing.T) {
+	ctx := context.Background()
+	auth, verification, mailer := newVerificationTest(t)
+	user, err := auth.Register(ctx, domain.RegisterRequest{Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := lastVerifyToken(t, mailer)
+	if err := verification.ResendVerification(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	second := lastVerifyToken(t, mailer)
+
+	if _, err := verification.VerifyEmail(ctx, first); !errors.Is(err, domain.ErrInvalidVerifyToken) {
+		t.Fatalf("expected the earlier token to be revoked, got %v", err)
+	}
+	if _, err := verification.VerifyEmail(ctx, second); err != nil {
+		t.Fatal(err)
+	}
+	if err := verification.ResendVerification(ctx, 42); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Fatalf("expected user not found, got %v", err)
+	}
+}
+
+func TestVerifyEmailTokenExpires(t *testing.T) {
+	ctx := context.Background()
+	auth, verification, mailer := newVerificationTest(t)
+	now := time.Now()
+	verification.now = func() time.Time { return now }
+	if _, err := auth.Register(ctx, domain.RegisterRequest{Email: "alice@example.com", Password: "secret123"}); err != nil {
+		t.Fatal(err)
+	}
+	token := lastVerifyToken(t, mailer)
+	now = now.Add(time.Hour)
+	if _, err := verification.VerifyEmail(ctx, token); !errors.Is(err, domain.ErrInvalidVerifyToken) {
+		t.Fatalf("expected an expired token to be refused, got %v", err)
+	}
+}
@@ -1 +0,0 @@
-DROP TABLE IF EXISTS books;
\ No newline at end of file
@@ -1 +0,0 @@
-DROP TABLE IF EXISTS lending_records;
\ No newline at end of file
@@ -0,0 +1,25 @@
+// Package migrations embeds the SQL migration scripts so that the
+// server binary can apply them without access to the source tree.
+// Each supported database driver has its own directory of scripts
+// named <version>_<name>.up.sql and <version>_<name>.down.sql; the
+// versions must stay in step across drivers.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+)
+
+//go:embed mysql/*.sql postgres/*.sql sqlite/*.sql
+var scripts embed.FS
+
+// For returns the migration set for the given database driver.
+func For(driver string) (fs.FS, error) {
+	switch driver {
+	case "mysql", "postgres", "sqlite":
+		return fs.Sub(scripts, driver)
+	default:
+		return nil, fmt.Errorf("no migrations for database driver %q", driver)
+	}
+}
@@ -11,4 +11,4 @@ CREATE TABLE IF NOT EXISTS books (
     INDEX idx_books_author (author),
     INDEX idx_books_category (category),
     INDEX idx_books_isbn (isbn)
-);
\ No newline at end of file
+);
@@ -12,4 +12,4 @@ CREATE TABLE IF NOT EXISTS lending_records (
     INDEX idx_lending_records_return_date (return_date),
     FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE,
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-);
\ No newline at end of file
+);
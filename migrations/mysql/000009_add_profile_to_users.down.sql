@@ -0,0 +1,4 @@
+ALTER TABLE users
+    DROP COLUMN preferences,
+    DROP COLUMN phone,
+    DROP COLUMN name;
@@ -0,0 +1,4 @@
+ALTER TABLE users
+    ADD COLUMN name VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN phone VARCHAR(32) NOT NULL DEFAULT '',
+    ADD COLUMN preferences TEXT NULL;
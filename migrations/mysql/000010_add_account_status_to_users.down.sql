@@ -0,0 +1,3 @@
+ALTER TABLE users
+    DROP COLUMN borrow_limit_reset_at,
+    DROP COLUMN suspended_at;
@@ -0,0 +1,3 @@
+ALTER TABLE users
+    ADD COLUMN suspended_at TIMESTAMP NULL,
+    ADD COLUMN borrow_limit_reset_at TIMESTAMP NULL;
@@ -0,0 +1,3 @@
+-- The original case of the addresses is not kept, so there is nothing
+-- to undo.
+SELECT 1;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS lending_records (
+    id BIGSERIAL PRIMARY KEY,
+    book_id BIGINT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    borrow_date TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    return_date TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_lending_records_book_id ON lending_records (book_id);
+CREATE INDEX IF NOT EXISTS idx_lending_records_user_id ON lending_records (user_id);
+CREATE INDEX IF NOT EXISTS idx_lending_records_borrow_date ON lending_records (borrow_date);
+CREATE INDEX IF NOT EXISTS idx_lending_records_return_date ON lending_records (return_date);
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN preferences;
+ALTER TABLE users DROP COLUMN phone;
+ALTER TABLE users DROP COLUMN name;
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN borrow_limit_reset_at;
+ALTER TABLE users DROP COLUMN suspended_at;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS sso_logins;
+DROP TABLE IF EXISTS user_identities;
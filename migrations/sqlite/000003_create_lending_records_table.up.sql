@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS lending_records (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    borrow_date DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    return_date DATETIME NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_lending_records_book_id ON lending_records (book_id);
+CREATE INDEX IF NOT EXISTS idx_lending_records_user_id ON lending_records (user_id);
+CREATE INDEX IF NOT EXISTS idx_lending_records_borrow_date ON lending_records (borrow_date);
+CREATE INDEX IF NOT EXISTS idx_lending_records_return_date ON lending_records (return_date);
@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN email_verified_at DATETIME NULL;
+-- Accounts created before verification existed are trusted as they are.
+UPDATE users SET email_verified_at = created_at;
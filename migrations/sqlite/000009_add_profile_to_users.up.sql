@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN name VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN phone VARCHAR(32) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN preferences TEXT NULL;
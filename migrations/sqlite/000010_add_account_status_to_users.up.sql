@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN suspended_at DATETIME NULL;
+ALTER TABLE users ADD COLUMN borrow_limit_reset_at DATETIME NULL;
@@ -0,0 +1,4 @@
+-- Emails are stored trimmed and in lower case from now on.  This fails
+-- if two accounts differ only in the case of their email, which must
+-- then be merged or renamed by hand first.
+UPDATE users SET email = LOWER(TRIM(email)) WHERE email <> LOWER(TRIM(email));
@@ -0,0 +1,65 @@
+package pkg
+
+import (
+	"book-lending-api/internal/domain"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// CursorCodec turns keyset positions into opaque, tamper‑proof strings
+// that can be handed to clients.  Cursors are signed with HMAC‑SHA256
+// so that clients cannot forge positions they were never given.
+type CursorCodec struct {
+	secret []byte
+}
+
+// NewCursorCodec returns a codec that signs cursors with the given
+// secret.
+func NewCursorCodec(secret string) *CursorCodec {
+	return &CursorCodec{secret: []byte(secret)}
+}
+
+// Encode serialises and signs the cursor for the listing named by
+// scope.  Its timestamp is truncated to domain.TimestampPrecision.
+func (c *CursorCodec) Encode(scope string, cursor domain.Cursor) (string, error) {
+	cursor.Scope = scope
+	cursor.Timestamp = cursor.Timestamp.Truncate(domain.TimestampPrecision)
+	payload, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	return body + "." + base64.RawURLEncoding.EncodeToString(c.sign(body)), nil
+}
+
+// Decode verifies the signature and returns the cursor it carries.  Any
+// malformed or forged cursor, or one issued for a listing other than
+// scope, yields domain.ErrInvalidCursor.
+func (c *CursorCodec) Decode(scope, token string) (*domain.Cursor, error) {
+	body, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, domain.ErrInvalidCursor
+	}
+	given, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(given, c.sign(body)) {
+		return nil, domain.ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return nil, domain.ErrInvalidCursor
+	}
+	var cursor domain.Cursor
+	if err := json.Unmarshal(payload, &cursor); err != nil || cursor.Scope != scope {
+		return nil, domain.ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+func (c *CursorCodec) sign(body string) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte("cursor:" + body))
+	return mac.Sum(nil)
+}
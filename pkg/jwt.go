@@ -14,9 +14,30 @@ import (
 type JWTClaims struct {
 	UserID uint   `json:"user_id"`
 	Email  string `json:"email"`
+	Role   string `json:"role,omitempty"`
+	// MFA is set when the user entered a second factor to log in.
+	MFA bool `json:"mfa,omitempty"`
+	// SessionID names the session the token belongs to, which must
+	// still exist for the token to be accepted.
+	SessionID uint `json:"sid,omitempty"`
+	// Purpose marks tokens that only grant one narrow step, such as
+	// mfaChallenge tokens.  Access tokens have none.
+	Purpose string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// AccessTokenTTL is how long access tokens, and the sessions they
+// belong to, last.
+const AccessTokenTTL = 24 * time.Hour
+
+// mfaChallenge is the purpose of tokens that prove a user entered the
+// right password and may now enter a two-factor code.
+const mfaChallenge = "mfa_challenge"
+
+// errWrongPurpose rejects a token presented for something it was not
+// issued for.
+var errWrongPurpose = errors.New("token issued for another purpose")
+
 // JWTUtil encapsulates the secret used to sign and validate tokens.
 type JWTUtil struct {
 	secret string
@@ -27,24 +48,63 @@ func NewJWTUtil(secret string) *JWTUtil {
 	return &JWTUtil{secret: secret}
 }
 
-// GenerateToken creates a signed JWT for the provided user.  Tokens
-// expire after 24 hours.
-func (j *JWTUtil) GenerateToken(user *domain.User) (string, error) {
-	claims := JWTClaims{
-		UserID: user.ID,
-		Email:  user.Email,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+// GenerateToken creates a signed JWT for the provided user in session
+// sessionID.  mfa records whether the user logged in with a second
+// factor.  Tokens expire after AccessTokenTTL.
+func (j *JWTUtil) GenerateToken(user *domain.User, mfa bool, sessionID uint) (string, error) {
+	return j.sign(JWTClaims{
+		UserID:    user.ID,
+		Email:     user.Email,
+		Role:      user.Role,
+		MFA:       mfa,
+		SessionID: sessionID,
+	}, AccessTokenTTL)
+}
+
+// GenerateMFAChallenge creates a token, valid for ttl, that lets user
+// complete a login with a two-factor code.  It is not accepted as an
+// access token.
+func (j *JWTUtil) GenerateMFAChallenge(user *domain.User, ttl time.Duration) (string, error) {
+	return j.sign(JWTClaims{UserID: user.ID, Purpose: mfaChallenge}, ttl)
+}
+
+func (j *JWTUtil) sign(claims JWTClaims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(now),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(j.secret))
 }
 
-// ValidateToken parses and validates the given token string.  It
-// returns the claims if valid or an error otherwise.
+// ValidateToken parses and validates the given access token string.
+// It returns the claims if valid or an error otherwise.
 func (j *JWTUtil) ValidateToken(tokenString string) (*JWTClaims, error) {
+	claims, err := j.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, errWrongPurpose
+	}
+	return claims, nil
+}
+
+// ValidateMFAChallenge validates a token from GenerateMFAChallenge and
+// returns the user ID it was issued for.
+func (j *JWTUtil) ValidateMFAChallenge(tokenString string) (uint, error) {
+	claims, err := j.parse(tokenString)
+	if err != nil {
+		return 0, err
+	}
+	if claims.Purpose != mfaChallenge {
+		return 0, errWrongPurpose
+	}
+	return claims.UserID, nil
+}
+
+func (j *JWTUtil) parse(tokenString string) (*JWTClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("invalid signing method")